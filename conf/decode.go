@@ -0,0 +1,288 @@
+// Copyright 2020 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conf
+
+import (
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// Unmarshal parses the configuration and stores the values in the struct or
+// map pointed to by v.
+//
+// Keys are matched to the struct fields named in the `conf` tag, or to the
+// field names otherwise, preferring an exact match but accepting a case
+// insensitive one. Keys without a matching field are ignored.
+//
+// Integer fields accept sizes, such as 1MB, either unquoted or as strings.
+// Durations accept strings, such as "2s", or numbers of seconds. Fields
+// implementing encoding.TextUnmarshaler accept strings.
+//
+// Like Parse, Unmarshal reads secret files, expands references and inlines
+// includes, so the values hold secrets in clear and no longer tell where they
+// came from. Marshal would write them as they are, so to edit a
+// configuration, parse it with ParseFileWithChecks instead.
+func Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("conf: can not unmarshal into %v, expected a non-nil pointer", reflect.TypeOf(v))
+	}
+	m, err := Parse(string(data))
+	if err != nil {
+		return err
+	}
+	d := &decoder{}
+	return d.decode(m, rv.Elem())
+}
+
+type decoder struct {
+	// The keys leading to the value being decoded.
+	path []string
+}
+
+func (d *decoder) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("conf: can not unmarshal %q: %s", strings.Join(d.path, "."), fmt.Sprintf(format, args...))
+}
+
+func (d *decoder) typeError(src interface{}, dst reflect.Value) error {
+	return d.errorf("expected %v, got %s", dst.Type(), describe(src))
+}
+
+// Returns the kind of a parsed value, as named by the format.
+func describe(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return fmt.Sprintf("string %q", v)
+	case int64:
+		return fmt.Sprintf("integer %d", v)
+	case float64:
+		return fmt.Sprintf("float %v", v)
+	case bool:
+		return fmt.Sprintf("boolean %v", v)
+	case time.Time:
+		return "datetime"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "map"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func (d *decoder) decode(src interface{}, dst reflect.Value) error {
+	if tk, ok := src.(*token); ok {
+		src = tk.Value()
+	}
+	if dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return d.decode(src, dst.Elem())
+	}
+
+	switch dst.Type() {
+	case durationType:
+		return d.decodeDuration(src, dst)
+	case timeType:
+		t, ok := src.(time.Time)
+		if !ok {
+			return d.typeError(src, dst)
+		}
+		dst.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if s, ok := src.(string); ok && dst.CanAddr() && dst.Addr().Type().Implements(textUnmarshalerType) {
+		if err := dst.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return d.errorf("%v", err)
+		}
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Interface:
+		if dst.NumMethod() != 0 {
+			return d.errorf("%v values are not supported", dst.Type())
+		}
+		if src != nil {
+			dst.Set(reflect.ValueOf(src))
+		}
+	case reflect.String:
+		s, ok := src.(string)
+		if !ok {
+			return d.typeError(src, dst)
+		}
+		dst.SetString(s)
+	case reflect.Bool:
+		b, ok := src.(bool)
+		if !ok {
+			return d.typeError(src, dst)
+		}
+		dst.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := d.integer(src, dst)
+		if err != nil {
+			return err
+		}
+		if dst.OverflowInt(n) {
+			return d.errorf("integer %d overflows %v", n, dst.Type())
+		}
+		dst.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := d.integer(src, dst)
+		if err != nil {
+			return err
+		}
+		if n < 0 || dst.OverflowUint(uint64(n)) {
+			return d.errorf("integer %d overflows %v", n, dst.Type())
+		}
+		dst.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch v := src.(type) {
+		case float64:
+			f = v
+		case int64:
+			f = float64(v)
+		default:
+			return d.typeError(src, dst)
+		}
+		if dst.OverflowFloat(f) {
+			return d.errorf("float %v overflows %v", f, dst.Type())
+		}
+		dst.SetFloat(f)
+	case reflect.Slice:
+		a, ok := src.([]interface{})
+		if !ok {
+			return d.typeError(src, dst)
+		}
+		s := reflect.MakeSlice(dst.Type(), len(a), len(a))
+		for i, v := range a {
+			d.path = append(d.path, strconv.Itoa(i))
+			if err := d.decode(v, s.Index(i)); err != nil {
+				return err
+			}
+			d.path = d.path[:len(d.path)-1]
+		}
+		dst.Set(s)
+	case reflect.Map:
+		m, ok := src.(map[string]interface{})
+		if !ok {
+			return d.typeError(src, dst)
+		}
+		if dst.Type().Key().Kind() != reflect.String {
+			return d.errorf("map keys have to be strings, got %v", dst.Type().Key())
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMap(dst.Type()))
+		}
+		for k, v := range m {
+			d.path = append(d.path, k)
+			ev := reflect.New(dst.Type().Elem()).Elem()
+			if err := d.decode(v, ev); err != nil {
+				return err
+			}
+			dst.SetMapIndex(reflect.ValueOf(k).Convert(dst.Type().Key()), ev)
+			d.path = d.path[:len(d.path)-1]
+		}
+	case reflect.Struct:
+		m, ok := src.(map[string]interface{})
+		if !ok {
+			return d.typeError(src, dst)
+		}
+		for k, v := range m {
+			fv, ok := lookupField(dst, k)
+			if !ok {
+				continue
+			}
+			d.path = append(d.path, k)
+			if err := d.decode(v, fv); err != nil {
+				return err
+			}
+			d.path = d.path[:len(d.path)-1]
+		}
+	default:
+		return d.errorf("%v values are not supported", dst.Type())
+	}
+	return nil
+}
+
+// Returns an integer, which can also be given as a string with a size suffix.
+func (d *decoder) integer(src interface{}, dst reflect.Value) (int64, error) {
+	switch v := src.(type) {
+	case int64:
+		return v, nil
+	case string:
+		n, err := parseInteger(strings.TrimSpace(v))
+		if err != nil {
+			return 0, d.errorf("%v", err)
+		}
+		return n, nil
+	}
+	return 0, d.typeError(src, dst)
+}
+
+// Decodes a duration, given as a string or as a number of seconds.
+func (d *decoder) decodeDuration(src interface{}, dst reflect.Value) error {
+	var dur time.Duration
+	switch v := src.(type) {
+	case string:
+		var err error
+		if dur, err = time.ParseDuration(v); err != nil {
+			return d.errorf("%v", err)
+		}
+	case int64:
+		if v > math.MaxInt64/int64(time.Second) || v < math.MinInt64/int64(time.Second) {
+			return d.errorf("duration of %d seconds is out of the range", v)
+		}
+		dur = time.Duration(v) * time.Second
+	case float64:
+		if math.Abs(v) > float64(math.MaxInt64)/float64(time.Second) {
+			return d.errorf("duration of %v seconds is out of the range", v)
+		}
+		dur = time.Duration(v * float64(time.Second))
+	default:
+		return d.typeError(src, dst)
+	}
+	dst.SetInt(int64(dur))
+	return nil
+}
+
+// Returns the field of the struct for the key, preferring exact matches.
+func lookupField(v reflect.Value, key string) (reflect.Value, bool) {
+	t := v.Type()
+	match := -1
+	for i := 0; i < t.NumField(); i++ {
+		name, _, ok := fieldTag(t.Field(i))
+		if !ok {
+			continue
+		}
+		if name == key {
+			return v.Field(i), true
+		}
+		if match < 0 && strings.EqualFold(name, key) {
+			match = i
+		}
+	}
+	if match < 0 {
+		return reflect.Value{}, false
+	}
+	return v.Field(match), true
+}
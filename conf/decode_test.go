@@ -0,0 +1,168 @@
+// Copyright 2020 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conf
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testUser struct {
+	User        string            `conf:"user"`
+	Password    string            `conf:"password"`
+	Permissions map[string]string `conf:"permissions"`
+}
+
+type testConfig struct {
+	Host          string        `conf:"host"`
+	Port          int           `conf:"port"`
+	MaxPayload    int32         `conf:"max_payload"`
+	MaxPending    uint64        `conf:"max_pending"`
+	PingInterval  time.Duration `conf:"ping_interval"`
+	WriteDeadline time.Duration `conf:"write_deadline"`
+	Timeout       time.Duration `conf:"timeout"`
+	Ratio         float32       `conf:"ratio"`
+	Debug         bool
+	Routes        []string    `conf:"routes"`
+	Users         []*testUser `conf:"users"`
+	Cluster       struct {
+		Port int `conf:"port"`
+	} `conf:"cluster"`
+	Meta    map[string]interface{} `conf:"meta"`
+	Since   time.Time              `conf:"since"`
+	IP      net.IP                 `conf:"ip"`
+	Ignored string                 `conf:"-"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	var c testConfig
+	c.Ignored = "kept"
+	err := Unmarshal([]byte(`
+		host: 127.0.0.1
+		port: 4222
+		max_payload: 1MB
+		max_pending: "64MB"
+		ping_interval: "2m"
+		write_deadline: 2
+		timeout: 0.5
+		ratio: 1
+		DEBUG: true
+		routes: ["nats://a:6222", "nats://b:6222"]
+		users: [
+			{user: alice, password: secret, permissions: {publish: "foo.>"}}
+		]
+		cluster { port: 6222 }
+		meta { a: 1, b: [x] }
+		since: 2016-05-04T18:53:41Z
+		ip: "10.0.0.1"
+		ignored: changed
+		unknown: 1
+	`), &c)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	since, _ := time.Parse("2006-01-02T15:04:05Z", "2016-05-04T18:53:41Z")
+	expected := testConfig{
+		Host:          "127.0.0.1",
+		Port:          4222,
+		MaxPayload:    1024 * 1024,
+		MaxPending:    64 * 1024 * 1024,
+		PingInterval:  2 * time.Minute,
+		WriteDeadline: 2 * time.Second,
+		Timeout:       500 * time.Millisecond,
+		Ratio:         1,
+		Debug:         true,
+		Routes:        []string{"nats://a:6222", "nats://b:6222"},
+		Users: []*testUser{{
+			User:        "alice",
+			Password:    "secret",
+			Permissions: map[string]string{"publish": "foo.>"},
+		}},
+		Meta:    map[string]interface{}{"a": int64(1), "b": []interface{}{"x"}},
+		Since:   since,
+		IP:      net.ParseIP("10.0.0.1"),
+		Ignored: "kept",
+	}
+	expected.Cluster.Port = 6222
+	if !reflect.DeepEqual(c, expected) {
+		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", c, expected)
+	}
+
+	// Round trip through Marshal.
+	b, err := Marshal(&c)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var c2 testConfig
+	c2.Ignored = "kept"
+	if err := Unmarshal(b, &c2); err != nil {
+		t.Fatalf("Unexpected error: %v\n%s", err, b)
+	}
+	if !reflect.DeepEqual(c2, expected) {
+		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", c2, expected)
+	}
+}
+
+func TestUnmarshalMap(t *testing.T) {
+	var m map[string]int
+	if err := Unmarshal([]byte("a: 1k\nb: 2"), &m); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(m, map[string]int{"a": 1000, "b": 2}) {
+		t.Fatalf("Unexpected map %v", m)
+	}
+
+	// Values have their references resolved.
+	var refs map[string]interface{}
+	if err := Unmarshal([]byte(`url: "nats://$(__UNIQ_HOST__:-localhost):4222"`), &refs); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if refs["url"] != "nats://localhost:4222" {
+		t.Fatalf("Unexpected map %v", refs)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		conf string
+		err  string
+	}{
+		{"type", `port: "abc"`, `conf: can not unmarshal "port": expected integer, but got 'abc'`},
+		{"string", `host: 1`, `conf: can not unmarshal "host": expected string, got integer 1`},
+		{"overflow", `max_payload: 4GB`, `conf: can not unmarshal "max_payload": integer 4294967296 overflows int32`},
+		{"negative", `max_pending: -1`, `conf: can not unmarshal "max_pending": integer -1 overflows uint64`},
+		{"duration", `ping_interval: "2 minutes"`, `conf: can not unmarshal "ping_interval": time: unknown unit`},
+		{"nested", `users: [{user: a}, {user: [b]}]`, `conf: can not unmarshal "users.1.user": expected string, got array`},
+		{"struct", `cluster: 1`, `conf: can not unmarshal "cluster": expected struct { Port int "conf:\"port\"" }, got integer 1`},
+		{"text", `ip: "not an ip"`, `conf: can not unmarshal "ip": invalid IP address: not an ip`},
+		{"parse", `host: "abc`, `Parse error on line 1`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var c testConfig
+			err := Unmarshal([]byte(test.conf), &c)
+			if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+				t.Fatalf("Expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+
+	var c testConfig
+	if err := Unmarshal([]byte("port: 1"), c); err == nil {
+		t.Fatal("Expected an error unmarshaling into a non pointer")
+	}
+}
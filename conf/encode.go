@@ -0,0 +1,496 @@
+// Copyright 2020 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conf
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const indentation = "  "
+
+var (
+	durationType      = reflect.TypeOf(time.Duration(0))
+	timeType          = reflect.TypeOf(time.Time{})
+	tokenType         = reflect.TypeOf(&token{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Marshal returns the configuration format of v, which has to be a map with
+// string keys, such as the ones returned by ParseFileWithChecks, or a struct.
+// Keys are written in sorted order, maps as blocks and strings always quoted,
+// so that the output can be parsed back into the same values.
+//
+// Struct fields are written with the name given in the `conf` tag, or the
+// field name otherwise. The tag "-" skips the field and the "omitempty"
+// option skips zero values. Durations are written as strings, such as "1m0s".
+//
+// A configuration is written back from the values of ParseFileWithChecks,
+// which keep where they came from. Values from included files are written
+// as the include that contained them, while secret file references and
+// environment variable references are written as they were in the file.
+// An included value that was removed or changed in place is an error, as
+// the include would bring it back, and values replaced in the map are
+// written after the include. The output is meant to be written next to the
+// parsed file, so that the relative paths of these references still resolve.
+//
+// Other values are written as they are. Values parsed with Parse or
+// ParseFile have their references resolved and their includes inlined, so
+// secrets read from files are written in clear. A secret parsed with
+// checks whose file reference is not known, such as a variable set to one,
+// is an error.
+func Marshal(v interface{}) ([]byte, error) {
+	e := &encoder{}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		rv = rv.Elem()
+	}
+	if k := rv.Kind(); k != reflect.Map && k != reflect.Struct {
+		return nil, fmt.Errorf("conf: can not marshal %v at the top level, expected a map or a struct", rv.Type())
+	}
+	if err := e.writeFields(rv); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+type encoder struct {
+	buf   bytes.Buffer
+	depth int
+	// The keys leading to the value being written.
+	path []string
+}
+
+// A field of a map or struct to write.
+type field struct {
+	key   string
+	value reflect.Value
+}
+
+func (e *encoder) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("conf: can not marshal %q: %s", strings.Join(e.path, "."), fmt.Sprintf(format, args...))
+}
+
+func (e *encoder) indent() {
+	for i := 0; i < e.depth; i++ {
+		e.buf.WriteString(indentation)
+	}
+}
+
+// Writes the includes and the fields of a map or struct, one per line.
+func (e *encoder) writeFields(v reflect.Value) error {
+	var (
+		fields   []field
+		includes []*include
+	)
+	if v.Kind() == reflect.Struct {
+		fields = structFields(v)
+	} else {
+		if v.Type().Key().Kind() != reflect.String {
+			return e.errorf("map keys have to be strings, got %v", v.Type().Key())
+		}
+		seen := make(map[*include]bool)
+		for _, k := range v.MapKeys() {
+			fv := v.MapIndex(k)
+			if tk := asToken(fv); tk != nil && tk.include != nil {
+				if !seen[tk.include] {
+					seen[tk.include] = true
+					includes = append(includes, tk.include)
+				}
+				continue
+			}
+			fields = append(fields, field{k.String(), fv})
+		}
+		sort.Slice(includes, func(i, j int) bool { return includes[i].path < includes[j].path })
+		sort.Slice(fields, func(i, j int) bool { return fields[i].key < fields[j].key })
+	}
+
+	for _, inc := range includes {
+		if err := e.checkInclude(v, inc); err != nil {
+			return err
+		}
+		e.indent()
+		e.buf.WriteString("include ")
+		if err := e.writeQuoted(inc.path); err != nil {
+			return err
+		}
+		e.buf.WriteByte('\n')
+	}
+	for _, f := range fields {
+		e.path = append(e.path, f.key)
+		e.indent()
+		if err := e.writeKey(f.key); err != nil {
+			return err
+		}
+		if isBlock(f.value) {
+			e.buf.WriteByte(' ')
+		} else {
+			e.buf.WriteString(": ")
+		}
+		if err := e.writeValue(f.value); err != nil {
+			return err
+		}
+		e.buf.WriteByte('\n')
+		e.path = e.path[:len(e.path)-1]
+	}
+	return nil
+}
+
+// Returns an error if a value from the include was removed from the map or
+// changed in place, since writing the include would bring the parsed value
+// back. Values replaced in the map are written after the include instead.
+func (e *encoder) checkInclude(v reflect.Value, inc *include) error {
+	keys := make([]string, 0, len(inc.values))
+	for k := range inc.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		e.path = append(e.path, k)
+		fv := v.MapIndex(reflect.ValueOf(k).Convert(v.Type().Key()))
+		if !fv.IsValid() {
+			return e.errorf("the value from include %q was removed", inc.path)
+		}
+		tk := asToken(fv)
+		if tk != nil && tk.include == inc && !reflect.DeepEqual(plainValue(tk.Value()), inc.values[k]) {
+			return e.errorf("the value from include %q was changed", inc.path)
+		}
+		e.path = e.path[:len(e.path)-1]
+	}
+	return nil
+}
+
+// Returns the fields of a struct, in the order they are declared.
+func structFields(v reflect.Value) []field {
+	var fields []field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, omitEmpty, ok := fieldTag(sf)
+		if !ok {
+			continue
+		}
+		fv := v.Field(i)
+		if omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		fields = append(fields, field{name, fv})
+	}
+	return fields
+}
+
+// Returns the key of an exported struct field and whether the zero value
+// has to be skipped, or false if the field is not part of the configuration.
+func fieldTag(sf reflect.StructField) (string, bool, bool) {
+	if sf.PkgPath != "" {
+		return "", false, false
+	}
+	tag := sf.Tag.Get("conf")
+	if tag == "-" {
+		return "", false, false
+	}
+	name, opts := tag, ""
+	if i := strings.IndexByte(tag, ','); i >= 0 {
+		name, opts = tag[:i], tag[i+1:]
+	}
+	if name == "" {
+		name = sf.Name
+	}
+	return name, opts == "omitempty", true
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return v.Interface().(time.Time).IsZero()
+		}
+	}
+	return false
+}
+
+// Returns the token held by the value, if any.
+func asToken(v reflect.Value) *token {
+	for v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.IsValid() && v.Type() == tokenType && !v.IsNil() {
+		return v.Interface().(*token)
+	}
+	return nil
+}
+
+// Returns the value held by interfaces, pointers and tokens.
+func indirect(v reflect.Value) reflect.Value {
+	for {
+		if tk := asToken(v); tk != nil {
+			v = reflect.ValueOf(tk.Value())
+			continue
+		}
+		if (v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr) && !v.IsNil() {
+			v = v.Elem()
+			continue
+		}
+		return v
+	}
+}
+
+// Returns whether the value is written as a block.
+func isBlock(v reflect.Value) bool {
+	v = indirect(v)
+	switch v.Kind() {
+	case reflect.Map:
+		return true
+	case reflect.Struct:
+		_, ok := textMarshaler(v)
+		return !ok && v.Type() != timeType
+	}
+	return false
+}
+
+// Returns the value as a text marshaler, if it implements the interface.
+func textMarshaler(v reflect.Value) (encoding.TextMarshaler, bool) {
+	if v.Type().Implements(textMarshalerType) {
+		return v.Interface().(encoding.TextMarshaler), true
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(textMarshalerType) {
+		return v.Addr().Interface().(encoding.TextMarshaler), true
+	}
+	return nil, false
+}
+
+// Returns whether the value is written on a single line.
+func isScalar(v reflect.Value) bool {
+	v = indirect(v)
+	switch v.Kind() {
+	case reflect.Array, reflect.Slice:
+		_, ok := textMarshaler(v)
+		return ok || v.Len() == 0
+	}
+	return !isBlock(v)
+}
+
+func (e *encoder) writeValue(v reflect.Value) error {
+	if tk := asToken(v); tk != nil {
+		switch {
+		case tk.item.typ == itemFile:
+			e.buf.WriteString("$file(")
+			if err := e.writeQuoted(tk.item.val); err != nil {
+				return err
+			}
+			e.buf.WriteByte(')')
+			return nil
+		case tk.secret:
+			return e.errorf("the secret file of the value is unknown")
+		case tk.item.typ == itemInterpolation:
+			return e.writeString(tk.item.val, true)
+		}
+	}
+	v = indirect(v)
+	if !v.IsValid() || ((v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil()) {
+		return e.errorf("nil values are not supported")
+	}
+
+	switch v.Type() {
+	case durationType:
+		return e.writeString(time.Duration(v.Int()).String(), false)
+	case timeType:
+		e.buf.WriteString(v.Interface().(time.Time).UTC().Format("2006-01-02T15:04:05Z"))
+		return nil
+	}
+	if m, ok := textMarshaler(v); ok {
+		text, err := m.MarshalText()
+		if err != nil {
+			return e.errorf("%v", err)
+		}
+		return e.writeString(string(text), false)
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return e.writeString(v.String(), false)
+	case reflect.Bool:
+		e.buf.WriteString(strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.buf.WriteString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return e.errorf("integer %d is out of the range", v.Uint())
+		}
+		e.buf.WriteString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return e.errorf("float %v is not supported", f)
+		}
+		// Floats always need digits after the '.' to be parsed as such.
+		s := strconv.FormatFloat(f, 'f', -1, v.Type().Bits())
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		e.buf.WriteString(s)
+	case reflect.Array, reflect.Slice:
+		return e.writeArray(v)
+	case reflect.Map, reflect.Struct:
+		e.buf.WriteString("{\n")
+		e.depth++
+		if err := e.writeFields(v); err != nil {
+			return err
+		}
+		e.depth--
+		e.indent()
+		e.buf.WriteByte('}')
+	default:
+		return e.errorf("%v values are not supported", v.Type())
+	}
+	return nil
+}
+
+// Writes arrays of scalars on a single line, and others with a value per line.
+func (e *encoder) writeArray(v reflect.Value) error {
+	inline := true
+	for i := 0; i < v.Len(); i++ {
+		if !isScalar(v.Index(i)) {
+			inline = false
+			break
+		}
+	}
+	e.buf.WriteByte('[')
+	if !inline {
+		e.depth++
+	}
+	for i := 0; i < v.Len(); i++ {
+		e.path = append(e.path, strconv.Itoa(i))
+		if inline {
+			if i > 0 {
+				e.buf.WriteString(", ")
+			}
+		} else {
+			e.buf.WriteByte('\n')
+			e.indent()
+		}
+		if err := e.writeValue(v.Index(i)); err != nil {
+			return err
+		}
+		e.path = e.path[:len(e.path)-1]
+	}
+	if !inline {
+		e.depth--
+		e.buf.WriteByte('\n')
+		e.indent()
+	}
+	e.buf.WriteByte(']')
+	return nil
+}
+
+// Returns whether a key can be written without quotes.
+func isBareKey(key string) bool {
+	if key == "" || strings.EqualFold(key, "include") {
+		return false
+	}
+	for _, r := range key {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '_' || r == '-' || r == '.' || r == '$':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (e *encoder) writeKey(key string) error {
+	if isBareKey(key) {
+		e.buf.WriteString(key)
+		return nil
+	}
+	return e.writeQuoted(key)
+}
+
+// Writes keys and paths, which do not support escape sequences, in quotes.
+func (e *encoder) writeQuoted(s string) error {
+	if strings.ContainsAny(s, "\r\n") {
+		return e.errorf("new lines can not be quoted in %q", s)
+	}
+	switch {
+	case !strings.ContainsRune(s, dqStringEnd):
+		e.buf.WriteString(`"` + s + `"`)
+	case !strings.ContainsRune(s, sqStringEnd):
+		e.buf.WriteString(`'` + s + `'`)
+	default:
+		return e.errorf("both kinds of quotes can not be quoted in %q", s)
+	}
+	return nil
+}
+
+// Writes a double-quoted string. The references of an interpolation are
+// written as they are, otherwise '$' followed by '(' is escaped so that it
+// is not read as a reference. In an interpolation, other '$' are doubled.
+func (e *encoder) writeString(s string, interpolation bool) error {
+	e.buf.WriteByte('"')
+	for i := 0; i < len(s); {
+		r, width := utf8.DecodeRuneInString(s[i:])
+		if interpolation && r == referenceStart {
+			if strings.HasPrefix(s[i:], "$$") {
+				// A literal '$', written below.
+				i++
+			} else if end := strings.IndexByte(s[i:], referenceClose); end > 0 {
+				e.buf.WriteString(s[i : i+end+1])
+				i += end + 1
+				continue
+			}
+		}
+		switch {
+		case r == '"':
+			e.buf.WriteString(`\"`)
+		case r == '\\':
+			e.buf.WriteString(`\\`)
+		case r == '\n':
+			e.buf.WriteString(`\n`)
+		case r == '\r':
+			e.buf.WriteString(`\r`)
+		case r == '\t':
+			e.buf.WriteString(`\t`)
+		case r == referenceStart && strings.HasPrefix(s[i+1:], string(referenceOpen)):
+			e.buf.WriteString(`\x24`)
+		case r < 0x20 || r == 0x7f || (r == utf8.RuneError && width == 1):
+			fmt.Fprintf(&e.buf, `\x%02x`, s[i])
+		default:
+			e.buf.WriteString(s[i : i+width])
+		}
+		i += width
+	}
+	e.buf.WriteByte('"')
+	return nil
+}
@@ -0,0 +1,279 @@
+// Copyright 2020 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conf
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarshal(t *testing.T) {
+	dt, _ := time.Parse("2006-01-02T15:04:05Z", "2016-05-04T18:53:41Z")
+	m := map[string]interface{}{
+		"listen":  "127.0.0.1:4222",
+		"debug":   true,
+		"max":     int64(-2),
+		"ratio":   float64(2),
+		"now":     dt,
+		"include": "a key, not an include",
+		"my key":  "it's \"quoted\"",
+		"escapes": "tab\there\nnew line \\ $(NOT_A_REF) $$ \x01",
+		"authorization": map[string]interface{}{
+			"users": []interface{}{
+				map[string]interface{}{"user": "alice", "password": "$2a$11$ooo"},
+			},
+			"timeout": 0.5,
+		},
+		"routes": []interface{}{"nats://a:6222", "nats://b:6222"},
+		"empty":  []interface{}{},
+		"nested": []interface{}{[]interface{}{int64(1), int64(2)}},
+	}
+	b, err := Marshal(m)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `authorization {
+  timeout: 0.5
+  users: [
+    {
+      password: "$2a$11$ooo"
+      user: "alice"
+    }
+  ]
+}
+debug: true
+empty: []
+escapes: "tab\there\nnew line \\ \x24(NOT_A_REF) $$ \x01"
+"include": "a key, not an include"
+listen: "127.0.0.1:4222"
+max: -2
+"my key": "it's \"quoted\""
+nested: [
+  [1, 2]
+]
+now: 2016-05-04T18:53:41Z
+ratio: 2.0
+routes: ["nats://a:6222", "nats://b:6222"]
+`
+	if string(b) != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, b)
+	}
+	test(t, string(b), m)
+
+	// Maps built by tools are written as they are, with values of any type.
+	if b, err = Marshal(map[string]interface{}{"port": 4222}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(b) != "port: 4222\n" {
+		t.Fatalf("Unexpected output %q", b)
+	}
+}
+
+func TestMarshalStruct(t *testing.T) {
+	type user struct {
+		User     string `conf:"user"`
+		Password string `conf:"password,omitempty"`
+	}
+	type config struct {
+		Port       int           `conf:"port"`
+		MaxPayload uint32        `conf:"max_payload"`
+		PingEvery  time.Duration `conf:"ping_interval"`
+		Users      []*user       `conf:"users"`
+		Tags       []string      `conf:"tags,omitempty"`
+		Ignored    string        `conf:"-"`
+		Plain      bool
+		hidden     bool
+	}
+	b, err := Marshal(&config{
+		Port:       4222,
+		MaxPayload: 1024,
+		PingEvery:  2 * time.Minute,
+		Users:      []*user{{User: "alice"}},
+		Ignored:    "ignored",
+		hidden:     true,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `port: 4222
+max_payload: 1024
+ping_interval: "2m0s"
+users: [
+  {
+    user: "alice"
+  }
+]
+Plain: false
+`
+	if string(b) != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, b)
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		v    interface{}
+		err  string
+	}{
+		{"top level", []interface{}{1}, "conf: can not marshal []interface {} at the top level, expected a map or a struct"},
+		{"nil", map[string]interface{}{"a": map[string]interface{}{"b": nil}}, `conf: can not marshal "a.b": nil values are not supported`},
+		{"nan", map[string]interface{}{"a": []interface{}{math.NaN()}}, `conf: can not marshal "a.0": float NaN is not supported`},
+		{"quotes", map[string]interface{}{`"'`: 1}, `both kinds of quotes can not be quoted`},
+		{"keys", map[string]interface{}{"a": map[int]int{1: 1}}, `conf: can not marshal "a": map keys have to be strings, got int`},
+		{"func", map[string]interface{}{"a": func() {}}, `conf: can not marshal "a": func() values are not supported`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Marshal(test.v)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("Expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestMarshalIncludes(t *testing.T) {
+	m, err := ParseFileWithChecks("simple.conf")
+	if err != nil {
+		t.Fatalf("Received err: %v\n", err)
+	}
+	b, err := Marshal(m)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `authorization {
+  include "includes/users.conf"
+  timeout: 0.5
+}
+listen: "127.0.0.1:4222"
+`
+	if string(b) != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, b)
+	}
+
+	// Parsing the output next to the original gives the same values.
+	fp := filepath.Join(filepath.Dir("simple.conf"), "marshaled.conf")
+	if err := ioutil.WriteFile(fp, b, 0600); err != nil {
+		t.Fatalf("Error writing config: %v", err)
+	}
+	defer os.Remove(fp)
+	m1, err := ParseFile("simple.conf")
+	if err != nil {
+		t.Fatalf("Received err: %v\n", err)
+	}
+	m2, err := ParseFile(fp)
+	if err != nil {
+		t.Fatalf("Received err: %v\n", err)
+	}
+	if !reflect.DeepEqual(m1, m2) {
+		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", m2, m1)
+	}
+}
+
+func TestMarshalIncludeChanges(t *testing.T) {
+	authorization := func(m map[string]interface{}) map[string]interface{} {
+		return m["authorization"].(*token).Value().(map[string]interface{})
+	}
+
+	// A replaced value is written after the include, which it overrides.
+	m, err := ParseFileWithChecks("simple.conf")
+	if err != nil {
+		t.Fatalf("Received err: %v\n", err)
+	}
+	authorization(m)["ALICE_PASS"] = "changed"
+	b, err := Marshal(m)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `authorization {
+  include "includes/users.conf"
+  ALICE_PASS: "changed"
+  timeout: 0.5
+}
+listen: "127.0.0.1:4222"
+`
+	if string(b) != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, b)
+	}
+
+	// Removing an included value or changing it in place can not be
+	// written, as the include would bring the parsed value back.
+	delete(authorization(m), "BOB_PASS")
+	_, err = Marshal(m)
+	if err == nil || err.Error() != `conf: can not marshal "authorization.BOB_PASS": the value from include "includes/users.conf" was removed` {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if m, err = ParseFileWithChecks("simple.conf"); err != nil {
+		t.Fatalf("Received err: %v\n", err)
+	}
+	users := authorization(m)["users"].(*token).Value().([]interface{})
+	users[1] = map[string]interface{}{"user": "carol"}
+	_, err = Marshal(m)
+	if err == nil || err.Error() != `conf: can not marshal "authorization.users": the value from include "includes/users.conf" was changed` {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestMarshalReferences(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conf")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "pw"), []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatalf("Error writing secret: %v", err)
+	}
+	fp := filepath.Join(dir, "refs.conf")
+	if err := ioutil.WriteFile(fp, []byte(`
+		password: $file("pw")
+		url: "nats://$(__UNIQ_HOST__:-localhost):4222"
+		mixed: "$$$(__UNIQ_HOST__:-localhost) $(bad"
+	`), 0600); err != nil {
+		t.Fatalf("Error writing config: %v", err)
+	}
+	m, err := ParseFileWithChecks(fp)
+	if err != nil {
+		t.Fatalf("Received err: %v\n", err)
+	}
+	b, err := Marshal(m)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `mixed: "$$$(__UNIQ_HOST__:-localhost) \x24(bad"
+password: $file("pw")
+url: "nats://$(__UNIQ_HOST__:-localhost):4222"
+`
+	if string(b) != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, b)
+	}
+
+	// A secret referenced by a variable can not be written back.
+	if err := ioutil.WriteFile(fp, []byte("pw: $file(\"pw\")\nauth { password: $pw }\n"), 0600); err != nil {
+		t.Fatalf("Error writing config: %v", err)
+	}
+	if m, err = ParseFileWithChecks(fp); err != nil {
+		t.Fatalf("Received err: %v\n", err)
+	}
+	_, err = Marshal(m)
+	if err == nil || strings.Contains(err.Error(), "s3cr3t") ||
+		err.Error() != `conf: can not marshal "auth.password": the secret file of the value is unknown` {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2020 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// positions maps offsets in a document to lines and columns.
+type positions []int
+
+func newPositions(data string) positions {
+	lines := positions{0}
+	for i := 0; i < len(data); i++ {
+		if data[i] == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return lines
+}
+
+// Returns the line and column, starting at 1, of the offset.
+func (ps positions) at(offset int) (int, int) {
+	i := sort.SearchInts(ps, offset+1) - 1
+	return i + 1, offset - ps[i] + 1
+}
+
+type jsonParser struct {
+	data     string
+	dec      *json.Decoder
+	lines    positions
+	fp       string
+	pedantic bool
+}
+
+// parseJSON parses a JSON document, whose top level value has to be an
+// object, into the same mapping as the configuration format.
+func parseJSON(data, fp string, pedantic bool) (map[string]interface{}, error) {
+	p := &jsonParser{
+		data:     data,
+		dec:      json.NewDecoder(strings.NewReader(data)),
+		lines:    newPositions(data),
+		fp:       fp,
+		pedantic: pedantic,
+	}
+	p.dec.UseNumber()
+	tok, _, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return nil, p.errorf(0, "expected a JSON object at the top level")
+	}
+	m, err := p.parseObject()
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := p.next(); err != io.EOF {
+		return nil, p.errorf(int(p.dec.InputOffset()), "unexpected data after the top level object")
+	}
+	return m, nil
+}
+
+func (p *jsonParser) errorf(offset int, format string, args ...interface{}) error {
+	line, _ := p.lines.at(offset)
+	return fmt.Errorf("Parse error on line %d: '%s'", line, fmt.Sprintf(format, args...))
+}
+
+// Returns the next token and its offset.
+func (p *jsonParser) next() (json.Token, int, error) {
+	// Skip what separates the previous token from this one.
+	offset := int(p.dec.InputOffset())
+	for offset < len(p.data) && strings.IndexByte(" \t\r\n:,", p.data[offset]) >= 0 {
+		offset++
+	}
+	tok, err := p.dec.Token()
+	if err != nil {
+		if err == io.EOF {
+			return nil, offset, err
+		}
+		if se, ok := err.(*json.SyntaxError); ok {
+			offset = int(se.Offset)
+		}
+		return nil, offset, p.errorf(offset, "%v", err)
+	}
+	return tok, offset, nil
+}
+
+// Returns the value of the token, wrapped with its position in pedantic mode.
+func (p *jsonParser) value(v interface{}, offset int) interface{} {
+	if !p.pedantic {
+		return v
+	}
+	line, pos := p.lines.at(offset)
+	return &token{item{itemString, fmt.Sprint(v), line, pos}, v, false, p.fp, false, nil}
+}
+
+func (p *jsonParser) parseObject() (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	for {
+		tok, offset, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := tok.(json.Delim); ok {
+			// Only the end of the object is expected here.
+			return m, nil
+		}
+		key := tok.(string)
+		tok, voffset, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		v, err := p.parseValue(tok, voffset)
+		if err != nil {
+			return nil, err
+		}
+		// Report errors on the value at the position of the key.
+		m[key] = p.value(v, offset)
+	}
+}
+
+func (p *jsonParser) parseArray() ([]interface{}, error) {
+	a := make([]interface{}, 0)
+	for {
+		tok, offset, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if d, ok := tok.(json.Delim); ok && d == ']' {
+			return a, nil
+		}
+		v, err := p.parseValue(tok, offset)
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, p.value(v, offset))
+	}
+}
+
+func (p *jsonParser) parseValue(tok json.Token, offset int) (interface{}, error) {
+	switch v := tok.(type) {
+	case json.Delim:
+		if v == '{' {
+			return p.parseObject()
+		}
+		return p.parseArray()
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return i, nil
+		}
+		f, err := strconv.ParseFloat(string(v), 64)
+		if err != nil {
+			return nil, p.errorf(offset, "number '%s' is out of the range", v)
+		}
+		return f, nil
+	case nil:
+		return nil, p.errorf(offset, "null values are not supported")
+	}
+	// Strings and booleans.
+	return tok, nil
+}
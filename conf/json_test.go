@@ -0,0 +1,73 @@
+package conf
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var formatsJSON = `{
+  "port": 4222,
+  "max_payload": 1048576,
+  "debug": true,
+  "ping_interval": 1.5,
+  "cluster": {
+    "listen": "0.0.0.0:6222",
+    "routes": ["nats://a:6222", "nats://b:6222"]
+  },
+  "accounts": {
+    "A": {
+      "users": [{"user": "a", "password": "p#1"}, {"user": "b", "password": "pwd"}]
+    }
+  }
+}`
+
+func TestJSONSameAsConf(t *testing.T) {
+	ex, err := Parse(formatsConf)
+	if err != nil {
+		t.Fatalf("Received err: %v\n", err)
+	}
+	// JSON has no datetime values.
+	delete(ex, "expires")
+	m, err := parseAs(t, ".json", formatsJSON, false)
+	if err != nil {
+		t.Fatalf("Received err: %v\n", err)
+	}
+	if !reflect.DeepEqual(m, ex) {
+		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", m, ex)
+	}
+}
+
+func TestJSONLineNumbers(t *testing.T) {
+	m, err := parseAs(t, ".json", formatsJSON, true)
+	if err != nil {
+		t.Fatalf("Received err: %v\n", err)
+	}
+	tk := m["cluster"].(*token)
+	if tk.Line() != 6 || tk.Position() != 3 {
+		t.Fatalf("Expected 6:3, got %d:%d", tk.Line(), tk.Position())
+	}
+	routes := tk.Value().(map[string]interface{})["routes"].(*token).Value().([]interface{})
+	if tk := routes[1].(*token); tk.Line() != 8 || tk.Position() != 33 || tk.Value() != "nats://b:6222" {
+		t.Fatalf("Unexpected token %d:%d %v", tk.Line(), tk.Position(), tk.Value())
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	for _, test := range []struct {
+		data string
+		err  string
+	}{
+		{`["a"]`, "line 1: 'expected a JSON object at the top level'"},
+		{"{\n\"a\": 1,\n\"b\": }", "line 3:"},
+		{`{"a": 1} {}`, "line 1: 'unexpected data after the top level object'"},
+		{`{"a": 1e400}`, "out of the range"},
+		{"{\n\"a\": null}", "line 2: 'null values are not supported'"},
+		{`{"a": [1, null]}`, "line 1: 'null values are not supported'"},
+	} {
+		_, err := parseAs(t, ".json", test.data, false)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Fatalf("Expected error %q for %q, got %v", test.err, test.data, err)
+		}
+	}
+}
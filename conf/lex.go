@@ -54,6 +54,8 @@ const (
 	itemCommentStart
 	itemVariable
 	itemInclude
+	itemInterpolation
+	itemFile
 )
 
 const (
@@ -78,6 +80,11 @@ const (
 	topOptTerm        = '}'
 	blockStart        = '('
 	blockEnd          = ')'
+	referenceStart    = '$'
+	referenceOpen     = '('
+	referenceClose    = ')'
+	fileStart         = "$file("
+	fileEnd           = ')'
 )
 
 type stateFn func(lx *lexer) stateFn
@@ -102,6 +109,11 @@ type lexer struct {
 	stringParts   []string
 	stringStateFn stateFn
 
+	// Whether the current string has environment variable references, and
+	// the indexes of the string parts holding them.
+	interpolate bool
+	refs        []int
+
 	// lstart is the start position of the current line.
 	lstart int
 
@@ -167,13 +179,22 @@ func (lx *lexer) emitString() {
 	var finalString string
 	if len(lx.stringParts) > 0 {
 		finalString = strings.Join(lx.stringParts, "") + lx.input[lx.start:lx.pos]
-		lx.stringParts = []string{}
 	} else {
 		finalString = lx.input[lx.start:lx.pos]
 	}
 	// Position of string in line where it started.
 	pos := lx.pos - lx.ilstart - len(finalString)
-	lx.items <- item{itemString, finalString, lx.line, pos}
+	typ := itemString
+	if lx.interpolate {
+		typ = itemInterpolation
+		finalString = lx.interpolation()
+		lx.interpolate = false
+		lx.refs = nil
+	}
+	if len(lx.stringParts) > 0 {
+		lx.stringParts = []string{}
+	}
+	lx.items <- item{typ, finalString, lx.line, pos}
 	lx.start = lx.pos
 	lx.ilstart = lx.lstart
 }
@@ -193,6 +214,35 @@ func (lx *lexer) hasEscapedParts() bool {
 	return len(lx.stringParts) > 0
 }
 
+// addReference adds the environment variable reference of n bytes that
+// follows the '$' that was just consumed as its own string part.
+func (lx *lexer) addReference(n int) stateFn {
+	lx.addCurrentStringPart(1)
+	lx.refs = append(lx.refs, len(lx.stringParts))
+	lx.stringParts = append(lx.stringParts, lx.input[lx.pos-1:lx.pos+n])
+	lx.pos += n
+	lx.start = lx.pos
+	lx.interpolate = true
+	return lx.stringStateFn
+}
+
+// interpolation returns the current string with its references, and every
+// other '$' doubled so that it can't be mistaken for the start of one.
+func (lx *lexer) interpolation() string {
+	var b strings.Builder
+	r := 0
+	for i, part := range lx.stringParts {
+		if r < len(lx.refs) && lx.refs[r] == i {
+			b.WriteString(part)
+			r++
+			continue
+		}
+		b.WriteString(strings.Replace(part, "$", "$$", -1))
+	}
+	b.WriteString(strings.Replace(lx.input[lx.start:lx.pos], "$", "$$", -1))
+	return b.String()
+}
+
 func (lx *lexer) next() (r rune) {
 	if lx.pos >= len(lx.input) {
 		lx.width = 0
@@ -545,6 +595,12 @@ func lexValue(lx *lexer) stateFn {
 	case r == blockStart:
 		lx.ignore()
 		return lexBlock
+	case r == referenceStart && strings.HasPrefix(lx.input[lx.pos:], fileStart[1:]):
+		for i := 1; i < len(fileStart); i++ {
+			lx.next()
+		}
+		lx.ignore()
+		return lexFileStart
 	case unicode.IsDigit(r):
 		lx.backup() // avoid an extra state and use the same as above
 		return lexNumberOrDateOrStringOrIPStart
@@ -821,6 +877,10 @@ func lexDubQuotedString(lx *lexer) stateFn {
 	case r == '\\':
 		lx.addCurrentStringPart(1)
 		return lexStringEscape
+	case r == referenceStart:
+		if n := referenceLen(lx.input[lx.pos:], true); n > 0 {
+			return lx.addReference(n)
+		}
 	case r == dqStringEnd:
 		lx.backup()
 		lx.emitString()
@@ -844,13 +904,15 @@ func lexString(lx *lexer) stateFn {
 	case r == '\\':
 		lx.addCurrentStringPart(1)
 		return lexStringEscape
+	case r == referenceStart && referenceLen(lx.input[lx.pos:], false) > 0:
+		return lx.addReference(referenceLen(lx.input[lx.pos:], false))
 	// Termination of non-quoted strings
 	case isNL(r) || r == eof || r == optValTerm ||
 		r == arrayValTerm || r == arrayEnd || r == mapEnd ||
 		isWhitespace(r):
 
 		lx.backup()
-		if lx.hasEscapedParts() {
+		if lx.hasEscapedParts() || lx.interpolate {
 			lx.emitString()
 		} else if lx.isBool() {
 			lx.emit(itemBool)
@@ -870,6 +932,88 @@ func lexString(lx *lexer) stateFn {
 	return lexString
 }
 
+// referenceLen returns the length of the environment variable reference,
+// either `(NAME)` or `(NAME:-default)`, found after a '$' at the start of s,
+// or 0 if there is none. A reference can't contain anything that would end
+// the string, or escapes. Anything else, such as `$(` in a password, is part
+// of the string.
+func referenceLen(s string, quoted bool) int {
+	if len(s) < 3 || s[0] != referenceOpen || !isReferenceNameStart(rune(s[1])) {
+		return 0
+	}
+	i := 2
+	for i < len(s) && isReferenceNameChar(rune(s[i])) {
+		i++
+	}
+	if i < len(s) && s[i] == referenceClose {
+		return i + 1
+	}
+	if !strings.HasPrefix(s[i:], ":-") {
+		return 0
+	}
+	for i += 2; i < len(s); i++ {
+		switch c := rune(s[i]); {
+		case c == referenceClose:
+			return i + 1
+		case isNL(c) || c == '\\' || c == dqStringEnd || c == sqStringEnd:
+			return 0
+		case !quoted && (isWhitespace(c) || c == optValTerm || c == arrayValTerm || c == arrayEnd || c == mapEnd):
+			return 0
+		}
+	}
+	return 0
+}
+
+// lexFileStart consumes the quoted path of a secret file reference such as
+// `$file("/run/secrets/password")`. It assumes that '$file(' has already
+// been consumed and ignored.
+func lexFileStart(lx *lexer) stateFn {
+	r := lx.next()
+	switch {
+	case isWhitespace(r):
+		return lexSkip(lx, lexFileStart)
+	case r == dqStringStart || r == sqStringStart:
+		lx.ignore()
+		return lexFilePath(r)
+	case isNL(r) || r == eof:
+		return lx.errorf("Unterminated secret file reference.")
+	}
+	return lx.errorf("Expected a quoted file path in secret file reference, but got '%v'.", r)
+}
+
+// lexFilePath consumes the file path up to the closing quote, and then the
+// closing ')'. The path is only emitted once the reference is complete.
+func lexFilePath(quote rune) stateFn {
+	var end int
+	var lexPath, lexEnd stateFn
+	lexPath = func(lx *lexer) stateFn {
+		r := lx.next()
+		switch {
+		case r == quote:
+			end = lx.pos - lx.width
+			return lexEnd
+		case isNL(r) || r == eof:
+			return lx.errorf("Unterminated secret file reference.")
+		}
+		return lexPath
+	}
+	lexEnd = func(lx *lexer) stateFn {
+		r := lx.next()
+		switch {
+		case isWhitespace(r):
+			return lexEnd
+		case r == fileEnd:
+			lx.items <- item{itemFile, lx.input[lx.start:end], lx.line, lx.start - lx.ilstart}
+			lx.ignore()
+			return lx.pop()
+		case isNL(r) || r == eof:
+			return lx.errorf("Unterminated secret file reference.")
+		}
+		return lx.errorf("Expected ')' after secret file path, but got '%v'.", r)
+	}
+	return lexPath
+}
+
 // lexBlock consumes the inner contents as a string. It assumes that the
 // beginning '(' has already been consumed and ignored. It will continue
 // processing until it finds a ')' on a new line by itself.
@@ -981,9 +1125,12 @@ func lexNumberOrDateOrStringOrIP(lx *lexer) stateFn {
 		return lexFloatStart
 	case isNumberSuffix(r):
 		return lexConvenientNumber
-	case !(isNL(r) || r == eof || r == mapEnd || r == optValTerm || r == mapValTerm || isWhitespace(r) || unicode.IsDigit(r)):
+	// An array terminator ends the number like a map terminator does, so
+	// that the last element in `[1, 2]` is not read as the string "2]".
+	case !(isNL(r) || r == eof || r == mapEnd || r == arrayEnd || r == optValTerm || r == mapValTerm || isWhitespace(r) || unicode.IsDigit(r)):
 		// Treat it as a string value once we get a rune that
 		// is not a number.
+		lx.stringStateFn = lexString
 		return lexString
 	}
 	lx.backup()
@@ -1130,6 +1277,16 @@ func lexSkip(lx *lexer, nextState stateFn) stateFn {
 	}
 }
 
+// Tests for the first character of an environment variable name
+func isReferenceNameStart(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// Tests for the characters of an environment variable name
+func isReferenceNameChar(r rune) bool {
+	return isReferenceNameStart(r) || (r >= '0' && r <= '9')
+}
+
 // Tests to see if we have a number suffix
 func isNumberSuffix(r rune) bool {
 	return r == 'k' || r == 'K' || r == 'm' || r == 'M' || r == 'g' || r == 'G'
@@ -1186,6 +1343,10 @@ func (itype itemType) String() string {
 		return "Variable"
 	case itemInclude:
 		return "Include"
+	case itemInterpolation:
+		return "Interpolation"
+	case itemFile:
+		return "File"
 	}
 	panic(fmt.Sprintf("BUG: Unknown type '%s'.", itype.String()))
 }
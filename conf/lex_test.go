@@ -499,6 +499,87 @@ func TestVariableValues(t *testing.T) {
 	expect(t, lx, expectedItems)
 }
 
+func TestInterpolatedValues(t *testing.T) {
+	expectedItems := []item{
+		{itemKey, "url", 1, 0},
+		{itemInterpolation, "nats://$(HOST:-localhost):4222", 1, 7},
+		{itemEOF, "", 1, 0},
+	}
+	lx := lex(`url = "nats://$(HOST:-localhost):4222"`)
+	expect(t, lx, expectedItems)
+
+	expectedItems = []item{
+		{itemKey, "host", 1, 0},
+		{itemInterpolation, "$(HOST)", 1, 7},
+		{itemEOF, "", 1, 0},
+	}
+	lx = lex(`host = $(HOST)`)
+	expect(t, lx, expectedItems)
+
+	// Other '$' are doubled in interpolations.
+	expectedItems = []item{
+		{itemKey, "foo", 1, 0},
+		{itemInterpolation, "a$$$(B) $$c $$(", 1, 7},
+		{itemEOF, "", 1, 0},
+	}
+	lx = lex(`foo = "a$$(B) $c $("`)
+	expect(t, lx, expectedItems)
+
+	// Anything that is not a valid reference is part of the string.
+	for _, test := range []struct{ input, value string }{
+		{`foo = "$(A-B)"`, "$(A-B)"},
+		{`foo = "pa$(ss"`, "pa$(ss"},
+		{`foo = "$(A:-b"`, "$(A:-b"},
+		{`foo = "$(A:-b\n)"`, "$(A:-b\n)"},
+		{`foo = pa$(A:-b,c)`, "pa$(A:-b"},
+	} {
+		lx = lex(test.input)
+		if it := lx.nextItem(); it.typ != itemKey {
+			t.Fatalf("Expected a key for %q, got %v", test.input, it)
+		}
+		if it := lx.nextItem(); it.typ != itemString || it.val != test.value {
+			t.Fatalf("Expected string %q for %q, got %v", test.value, test.input, it)
+		}
+	}
+}
+
+func TestFileValues(t *testing.T) {
+	expectedItems := []item{
+		{itemKey, "password", 1, 0},
+		{itemFile, "/run/secrets/pw", 1, 17},
+		{itemEOF, "", 1, 0},
+	}
+	lx := lex(`password: $file("/run/secrets/pw")`)
+	expect(t, lx, expectedItems)
+
+	expectedItems = []item{
+		{itemKey, "tokens", 1, 0},
+		{itemArrayStart, "", 1, 10},
+		{itemFile, "a b", 1, 18},
+		{itemFile, "c", 1, 33},
+		{itemArrayEnd, "", 1, 37},
+		{itemEOF, "", 1, 0},
+	}
+	lx = lex(`tokens = [$file( 'a b' ), $file("c")]`)
+	expect(t, lx, expectedItems)
+
+	expectedItems = []item{
+		{itemKey, "password", 1, 0},
+		{itemError, "Expected ')' after secret file path, but got ';'.", 1, 21},
+		{itemEOF, "", 1, 0},
+	}
+	lx = lex(`password: $file("pw"; foo`)
+	expect(t, lx, expectedItems)
+
+	expectedItems = []item{
+		{itemKey, "password", 1, 0},
+		{itemError, "Expected a quoted file path in secret file reference, but got 'p'.", 1, 17},
+		{itemEOF, "", 1, 0},
+	}
+	lx = lex(`password: $file(pw)`)
+	expect(t, lx, expectedItems)
+}
+
 func TestArrays(t *testing.T) {
 	expectedItems := []item{
 		{itemKey, "foo", 1, 0},
@@ -551,6 +632,19 @@ foo = [
 ]
 `
 
+func TestArrayEndingWithInteger(t *testing.T) {
+	expectedItems := []item{
+		{itemKey, "foo", 1, 0},
+		{itemArrayStart, "", 1, 7},
+		{itemInteger, "1", 1, 7},
+		{itemInteger, "2", 1, 10},
+		{itemArrayEnd, "", 1, 12},
+		{itemEOF, "", 1, 0},
+	}
+	lx := lex("foo = [1, 2]")
+	expect(t, lx, expectedItems)
+}
+
 func TestMultilineArrays(t *testing.T) {
 	expectedItems := []item{
 		{itemCommentStart, "", 2, 2},
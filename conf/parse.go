@@ -23,6 +23,11 @@ package conf
 // maps can be assigned with no key separator as well
 // semicolons as value terminators in key/value assignments are optional
 //
+// Double-quoted and raw strings expand environment variable references,
+// `$(NAME)` or `$(NAME:-default)`. References to variables that are not
+// defined, and a '$' that does not start a valid reference, are left as they
+// are. Use `\x24` for a literal '$' in front of '('.
+//
 // see parse_test.go for more examples.
 
 import (
@@ -71,17 +76,13 @@ func Parse(data string) (map[string]interface{}, error) {
 }
 
 // ParseFile is a helper to open file, etc. and parse the contents.
+// Files with a .json, .yaml or .yml extension are parsed as JSON or YAML.
 func ParseFile(fp string) (map[string]interface{}, error) {
 	data, err := ioutil.ReadFile(fp)
 	if err != nil {
 		return nil, fmt.Errorf("error opening config file: %v", err)
 	}
-
-	p, err := parse(string(data), fp, false)
-	if err != nil {
-		return nil, err
-	}
-	return p.mapping, nil
+	return parseFile(string(data), fp, false)
 }
 
 // ParseFileWithChecks is equivalent to ParseFile but runs in pedantic mode.
@@ -90,12 +91,21 @@ func ParseFileWithChecks(fp string) (map[string]interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	return parseFile(string(data), fp, true)
+}
 
-	p, err := parse(string(data), fp, true)
+// Parses the file contents according to the file extension.
+func parseFile(data, fp string, pedantic bool) (map[string]interface{}, error) {
+	switch strings.ToLower(filepath.Ext(fp)) {
+	case ".json":
+		return parseJSON(data, fp, pedantic)
+	case ".yaml", ".yml":
+		return parseYAML(data, fp, pedantic)
+	}
+	p, err := parse(data, fp, pedantic)
 	if err != nil {
 		return nil, err
 	}
-
 	return p.mapping, nil
 }
 
@@ -104,6 +114,18 @@ type token struct {
 	value        interface{}
 	usedVariable bool
 	sourceFile   string
+	secret       bool
+	// The include that the value is from.
+	include *include
+}
+
+// include is an include of a file, as seen by the values parsed from it.
+type include struct {
+	// The path, as written.
+	path string
+	// The values of the file when parsed, without tokens, to tell
+	// whether they were changed afterwards.
+	values map[string]interface{}
 }
 
 func (t *token) Value() interface{} {
@@ -126,6 +148,34 @@ func (t *token) Position() int {
 	return t.item.pos
 }
 
+// IsSecret returns whether the value was read from a secret file, in which
+// case it should not be printed.
+func (t *token) IsSecret() bool {
+	return t.secret
+}
+
+// plainValue returns a copy of the value with the values of the tokens
+// in their place.
+func plainValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *token:
+		return plainValue(v.Value())
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, mv := range v {
+			m[k] = plainValue(mv)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, av := range v {
+			a[i] = plainValue(av)
+		}
+		return a
+	}
+	return v
+}
+
 func parse(data, fp string, pedantic bool) (p *parser, err error) {
 	p = &parser{
 		mapping:  make(map[string]interface{}),
@@ -202,7 +252,7 @@ func (p *parser) popItemKey() item {
 func (p *parser) processItem(it item, fp string) error {
 	setValue := func(it item, v interface{}) {
 		if p.pedantic {
-			p.setValue(&token{it, v, false, fp})
+			p.setValue(&token{it, v, false, fp, false, nil})
 		} else {
 			p.setValue(v)
 		}
@@ -229,41 +279,11 @@ func (p *parser) processItem(it item, fp string) error {
 		// FIXME(dlc) sanitize string?
 		setValue(it, it.val)
 	case itemInteger:
-		lastDigit := 0
-		for _, r := range it.val {
-			if !unicode.IsDigit(r) && r != '-' {
-				break
-			}
-			lastDigit++
-		}
-		numStr := it.val[:lastDigit]
-		num, err := strconv.ParseInt(numStr, 10, 64)
+		num, err := parseInteger(it.val)
 		if err != nil {
-			if e, ok := err.(*strconv.NumError); ok &&
-				e.Err == strconv.ErrRange {
-				return fmt.Errorf("integer '%s' is out of the range", it.val)
-			}
-			return fmt.Errorf("expected integer, but got '%s'", it.val)
-		}
-		// Process a suffix
-		suffix := strings.ToLower(strings.TrimSpace(it.val[lastDigit:]))
-
-		switch suffix {
-		case "":
-			setValue(it, num)
-		case "k":
-			setValue(it, num*1000)
-		case "kb":
-			setValue(it, num*1024)
-		case "m":
-			setValue(it, num*1000*1000)
-		case "mb":
-			setValue(it, num*1024*1024)
-		case "g":
-			setValue(it, num*1000*1000*1000)
-		case "gb":
-			setValue(it, num*1024*1024*1024)
+			return err
 		}
+		setValue(it, num)
 	case itemFloat:
 		num, err := strconv.ParseFloat(it.val, 64)
 		if err != nil {
@@ -275,14 +295,11 @@ func (p *parser) processItem(it item, fp string) error {
 		}
 		setValue(it, num)
 	case itemBool:
-		switch strings.ToLower(it.val) {
-		case "true", "yes", "on":
-			setValue(it, true)
-		case "false", "no", "off":
-			setValue(it, false)
-		default:
+		b, ok := parseBool(it.val)
+		if !ok {
 			return fmt.Errorf("expected boolean value, but got '%s'", it.val)
 		}
+		setValue(it, b)
 
 	case itemDatetime:
 		dt, err := time.Parse("2006-01-02T15:04:05Z", it.val)
@@ -315,14 +332,33 @@ func (p *parser) processItem(it item, fp string) error {
 				// Mark the looked up variable as used, and make
 				// the variable reference become handled as a token.
 				tk.usedVariable = true
-				p.setValue(&token{it, tk.Value(), false, fp})
+				p.setValue(&token{it, tk.Value(), false, fp, tk.secret, nil})
 			default:
 				// Special case to add position context to bcrypt references.
-				p.setValue(&token{it, value, false, fp})
+				p.setValue(&token{it, value, false, fp, false, nil})
 			}
 		} else {
 			p.setValue(value)
 		}
+	case itemInterpolation:
+		s, err := p.interpolate(it)
+		if err != nil {
+			return err
+		}
+		setValue(it, s)
+	case itemFile:
+		// The item holds the path, so that the secret does not
+		// appear in errors reported on the token.
+		secret, err := p.readSecret(it.val)
+		if err != nil {
+			return fmt.Errorf("secret file reference for '%s' on line %d could not be read: %v",
+				it.val, it.line, err)
+		}
+		if p.pedantic {
+			p.setValue(&token{it, secret, false, fp, true, nil})
+		} else {
+			p.setValue(secret)
+		}
 	case itemInclude:
 		var (
 			m   map[string]interface{}
@@ -336,12 +372,17 @@ func (p *parser) processItem(it item, fp string) error {
 		if err != nil {
 			return fmt.Errorf("error parsing include file '%s', %v", it.val, err)
 		}
+		var inc *include
+		if p.pedantic {
+			inc = &include{it.val, plainValue(m).(map[string]interface{})}
+		}
 		for k, v := range m {
 			p.pushKey(k)
 
 			if p.pedantic {
 				switch tk := v.(type) {
 				case *token:
+					tk.include = inc
 					p.pushItemKey(tk.item)
 				}
 			}
@@ -352,6 +393,58 @@ func (p *parser) processItem(it item, fp string) error {
 	return nil
 }
 
+// parseInteger parses an integer with an optional size suffix, such as 1k or 2MB.
+func parseInteger(val string) (int64, error) {
+	lastDigit := 0
+	for _, r := range val {
+		if !unicode.IsDigit(r) && r != '-' {
+			break
+		}
+		lastDigit++
+	}
+	numStr := val[:lastDigit]
+	num, err := strconv.ParseInt(numStr, 10, 64)
+	if err != nil {
+		if e, ok := err.(*strconv.NumError); ok &&
+			e.Err == strconv.ErrRange {
+			return 0, fmt.Errorf("integer '%s' is out of the range", val)
+		}
+		return 0, fmt.Errorf("expected integer, but got '%s'", val)
+	}
+	// Process a suffix
+	suffix := strings.ToLower(strings.TrimSpace(val[lastDigit:]))
+
+	switch suffix {
+	case "":
+	case "k":
+		num *= 1000
+	case "kb":
+		num *= 1024
+	case "m":
+		num *= 1000 * 1000
+	case "mb":
+		num *= 1024 * 1024
+	case "g":
+		num *= 1000 * 1000 * 1000
+	case "gb":
+		num *= 1024 * 1024 * 1024
+	default:
+		return 0, fmt.Errorf("expected integer, but got '%s'", val)
+	}
+	return num, nil
+}
+
+// parseBool parses the boolean values of the format.
+func parseBool(val string) (bool, bool) {
+	switch strings.ToLower(val) {
+	case "true", "yes", "on":
+		return true, true
+	case "false", "no", "off":
+		return false, true
+	}
+	return false, false
+}
+
 // Used to map an environment value into a temporary map to pass to secondary Parse call.
 const pkey = "pk"
 
@@ -394,6 +487,80 @@ func (p *parser) lookupVariable(varReference string) (interface{}, bool, error)
 	return nil, false, nil
 }
 
+// interpolate expands the environment variable references of a string. A
+// reference `$(NAME)` to a variable that is not defined is left as it is,
+// so that strings that happen to contain one keep their value, while the
+// default of `$(NAME:-default)` is used when the variable is not defined or
+// empty.
+// The lexer has validated the references and doubled every other '$'.
+//
+// In pedantic mode, a variable without default that is defined but empty is
+// reported as an error.
+func (p *parser) interpolate(it item) (string, error) {
+	var b strings.Builder
+	s := it.val
+	for {
+		i := strings.IndexByte(s, referenceStart)
+		if i < 0 || i == len(s)-1 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		b.WriteString(s[:i])
+		if s[i+1] == referenceStart {
+			b.WriteByte(referenceStart)
+			s = s[i+2:]
+			continue
+		}
+		end := strings.IndexByte(s[i:], referenceClose)
+		if end < 0 {
+			b.WriteString(s[i:])
+			return b.String(), nil
+		}
+		ref, raw := s[i+2:i+end], s[i:i+end+1]
+		s = s[i+end+1:]
+
+		name, def, hasDefault := ref, "", false
+		if j := strings.Index(ref, ":-"); j >= 0 {
+			name, def, hasDefault = ref[:j], ref[j+2:], true
+		}
+		v, ok := os.LookupEnv(name)
+		switch {
+		case v != "":
+			b.WriteString(v)
+		case hasDefault:
+			b.WriteString(def)
+		case !ok:
+			b.WriteString(raw)
+		case p.pedantic:
+			return "", fmt.Errorf("environment variable reference for '%s' on line %d is empty",
+				name, it.line)
+		}
+	}
+}
+
+// readSecret returns the contents of a secret file without the trailing new
+// line. Relative paths are relative to the directory of the configuration
+// file. In pedantic mode, empty secrets are reported as an error.
+//
+// Errors never include the contents of the file.
+func (p *parser) readSecret(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("empty path")
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(p.fp, path)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	secret := strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r")
+	if p.pedantic && strings.TrimSpace(secret) == "" {
+		return "", fmt.Errorf("secret is empty")
+	}
+	return secret, nil
+}
+
 func (p *parser) setValue(val interface{}) {
 	// Test to see if we are on an array or a map
 
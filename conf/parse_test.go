@@ -2,7 +2,9 @@ package conf
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -139,6 +141,165 @@ func TestBcryptVariable(t *testing.T) {
 	test(t, "password: $2a$11$ooo", ex)
 }
 
+func TestEnvInterpolation(t *testing.T) {
+	os.Setenv("__UNIQ_HOST__", "example.com")
+	defer os.Unsetenv("__UNIQ_HOST__")
+	os.Setenv("__UNIQ_EMPTY__", "")
+	defer os.Unsetenv("__UNIQ_EMPTY__")
+
+	ex := map[string]interface{}{
+		"url":     "nats://example.com:4222",
+		"default": "nats://localhost:4222",
+		"empty":   "nats://localhost",
+		"raw":     "example.com:4222",
+		"escaped": "$(__UNIQ_HOST__) costs $5 ",
+		"plain":   "a$$b",
+		"dollars": "a$$b example.com",
+		"invalid": "pa$(ss example.com $(A-B)",
+		"list":    []interface{}{"example.com", "x"},
+	}
+	test(t, `
+		url:     "nats://$(__UNIQ_HOST__):4222"
+		default: "nats://$(__UNIQ_MISSING__:-localhost):4222"
+		empty:   "nats://$(__UNIQ_EMPTY__:-localhost)"
+		raw:     $(__UNIQ_HOST__):4222
+		escaped: "\x24(__UNIQ_HOST__) costs $5 $(__UNIQ_EMPTY__)"
+		plain:   "a$$b"
+		dollars: "a$$b $(__UNIQ_HOST__)"
+		invalid: "pa$(ss $(__UNIQ_HOST__) $(A-B)"
+		list:    [ $(__UNIQ_HOST__), "$(__UNIQ_MISSING__:-x)" ]
+	`, ex)
+
+	// References to variables that are not defined are left as they are,
+	// in pedantic mode too.
+	ex = map[string]interface{}{
+		"password": "x$(__UNIQ_MISSING__)y",
+		"url":      "nats://$(__UNIQ_MISSING__):4222",
+		"mixed":    "$(__UNIQ_MISSING__) example.com",
+	}
+	test(t, `
+		password: "x$(__UNIQ_MISSING__)y"
+		url:      nats://$(__UNIQ_MISSING__):4222
+		mixed:    "$(__UNIQ_MISSING__) $(__UNIQ_HOST__)"
+	`, ex)
+	p, err := parse(`password: "x$(__UNIQ_MISSING__)y"`, "", true)
+	if err != nil {
+		t.Fatalf("Received err: %v\n", err)
+	}
+	if pw := p.mapping["password"].(*token).Value(); pw != "x$(__UNIQ_MISSING__)y" {
+		t.Fatalf("Expected the reference to be left as it is, got %v", pw)
+	}
+
+	// Empty values are only reported in pedantic mode.
+	if _, err := parse(`url: "$(__UNIQ_EMPTY__)"`, "", false); err != nil {
+		t.Fatalf("Received err: %v\n", err)
+	}
+	_, err = parse(`url: "$(__UNIQ_EMPTY__)"`, "", true)
+	if err == nil || !strings.Contains(err.Error(), "is empty") {
+		t.Fatalf("Expected an error for an empty environment variable, got %v", err)
+	}
+}
+
+func TestSecretFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conf")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	secret := "s3cr3t-value"
+	pw := filepath.Join(dir, "pw")
+	if err := ioutil.WriteFile(pw, []byte(secret+"\n"), 0600); err != nil {
+		t.Fatalf("Error writing secret: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "empty"), nil, 0600); err != nil {
+		t.Fatalf("Error writing secret: %v", err)
+	}
+	conf := filepath.Join(dir, "secrets.conf")
+	writeConf := func(content string) {
+		t.Helper()
+		if err := ioutil.WriteFile(conf, []byte(content), 0600); err != nil {
+			t.Fatalf("Error writing config: %v", err)
+		}
+	}
+
+	// Relative paths are relative to the configuration file.
+	writeConf(fmt.Sprintf(`
+		authorization {
+			password: $file(%q)
+			token: $file("pw")
+		}
+		pw: $file("pw")
+		copy: $pw
+	`, pw))
+	m, err := ParseFile(conf)
+	if err != nil {
+		t.Fatalf("Received err: %v\n", err)
+	}
+	ex := map[string]interface{}{
+		"authorization": map[string]interface{}{
+			"password": secret,
+			"token":    secret,
+		},
+		"pw":   secret,
+		"copy": secret,
+	}
+	if !reflect.DeepEqual(m, ex) {
+		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", m, ex)
+	}
+
+	m, err = ParseFileWithChecks(conf)
+	if err != nil {
+		t.Fatalf("Received err: %v\n", err)
+	}
+	for _, k := range []string{"pw", "copy"} {
+		tk := m[k].(*token)
+		if !tk.IsSecret() || tk.Value() != secret {
+			t.Fatalf("Expected %q to be a secret token, got %+v", k, tk)
+		}
+		if strings.Contains(tk.item.String(), secret) {
+			t.Fatalf("Expected the secret not to be in the item, got %s", tk.item)
+		}
+	}
+	auth := m["authorization"].(*token).Value().(map[string]interface{})
+	if tk := auth["password"].(*token); !tk.IsSecret() || tk.Line() != 3 || tk.Position() != 4 {
+		t.Fatalf("Unexpected token %+v", tk)
+	}
+
+	for _, test := range []struct {
+		name     string
+		content  string
+		pedantic bool
+		err      string
+	}{
+		{"missing", `pw: $file("missing")`, false, "secret file reference for 'missing' on line 1 could not be read"},
+		{"empty path", `pw: $file("")`, false, "secret file reference for '' on line 1 could not be read: empty path"},
+		{"empty", `pw: $file("empty")`, true, "secret file reference for 'empty' on line 1 could not be read: secret is empty"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			writeConf(test.content)
+			var err error
+			if test.pedantic {
+				_, err = ParseFileWithChecks(conf)
+			} else {
+				_, err = ParseFile(conf)
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+				t.Fatalf("Expected error %q, got %v", test.err, err)
+			}
+			if strings.Contains(err.Error(), secret) {
+				t.Fatalf("Expected the secret not to be in the error, got %v", err)
+			}
+		})
+	}
+
+	// An empty secret is accepted when not in pedantic mode.
+	writeConf(`pw: $file("empty")`)
+	if m, err = ParseFile(conf); err != nil || m["pw"] != "" {
+		t.Fatalf("Expected an empty secret, got %v, %v", m, err)
+	}
+}
+
 var easynum = `
 k = 8k
 kb = 4kb
@@ -160,6 +321,18 @@ func TestConvenientNumbers(t *testing.T) {
 	test(t, easynum, ex)
 }
 
+func TestArrayEndingWithNumber(t *testing.T) {
+	ex := map[string]interface{}{
+		"a": []interface{}{int64(1), int64(2)},
+		"b": []interface{}{float64(1.5), float64(2.5)},
+		"c": []interface{}{int64(1000), int64(2000)},
+		"d": map[string]interface{}{
+			"e": []interface{}{int64(3)},
+		},
+	}
+	test(t, "a = [1, 2]\nb = [1.5, 2.5]\nc = [1k, 2k]\nd = {e: [3]}", ex)
+}
+
 var sample1 = `
 foo  {
   host {
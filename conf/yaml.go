@@ -0,0 +1,683 @@
+// Copyright 2020 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conf
+
+// The YAML support covers what is needed to write configuration files:
+// block mappings and sequences, flow collections, plain and quoted
+// scalars, literal and folded block scalars, and comments. Anchors,
+// aliases, tags, complex keys and multiple documents are not supported.
+//
+// Plain scalars are resolved as the configuration format does, so
+// `max_payload: 1MB` is the integer 1048576 and `debug: on` is true.
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	yamlIntRe   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlSizeRe  = regexp.MustCompile(`^-?[0-9]+ ?(?i:k|kb|m|mb|g|gb)$`)
+	yamlFloatRe = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+)
+
+type yamlParser struct {
+	lines    []string
+	n        int
+	fp       string
+	pedantic bool
+}
+
+// A parsed node, with the position of its first character.
+type yamlNode struct {
+	v    interface{}
+	line int
+	pos  int
+}
+
+// parseYAML parses a YAML document, whose top level value has to be a
+// mapping, into the same mapping as the configuration format.
+func parseYAML(data, fp string, pedantic bool) (map[string]interface{}, error) {
+	p := &yamlParser{
+		lines:    strings.Split(strings.Replace(data, "\r\n", "\n", -1), "\n"),
+		fp:       fp,
+		pedantic: pedantic,
+	}
+	if err := p.stripDocumentMarkers(); err != nil {
+		return nil, err
+	}
+	if !p.skipBlank() {
+		return map[string]interface{}{}, nil
+	}
+	indent, text := p.current()
+	if indent != 0 || isSeqEntry(text) {
+		return nil, p.errorf(p.n, "expected a mapping at the top level")
+	}
+	if _, _, _, ok := splitKey(text); !ok {
+		return nil, p.errorf(p.n, "expected a mapping at the top level")
+	}
+	m, err := p.parseMap(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.skipBlank() {
+		return nil, p.errorf(p.n, "unexpected indentation")
+	}
+	return m, nil
+}
+
+func (p *yamlParser) errorf(n int, format string, args ...interface{}) error {
+	return fmt.Errorf("Parse error on line %d: '%s'", n+1, fmt.Sprintf(format, args...))
+}
+
+// Blanks the directives and the start and end of document markers.
+func (p *yamlParser) stripDocumentMarkers() error {
+	content := false
+	for i, l := range p.lines {
+		switch {
+		case l == "---" || strings.HasPrefix(l, "--- "):
+			if content {
+				return p.errorf(i, "multiple documents are not supported")
+			}
+			p.lines[i] = ""
+		case l == "..." || strings.HasPrefix(l, "... "):
+			p.lines = p.lines[:i]
+			return nil
+		case strings.HasPrefix(l, "%") && !content:
+			p.lines[i] = ""
+		default:
+			if _, text := splitIndent(stripComment(l)); text != "" {
+				content = true
+			}
+		}
+	}
+	return nil
+}
+
+// Moves to the next line with content, returning false at the end.
+func (p *yamlParser) skipBlank() bool {
+	for ; p.n < len(p.lines); p.n++ {
+		if _, text := p.current(); text != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the indentation and the content without comment of the current line.
+func (p *yamlParser) current() (int, string) {
+	return splitIndent(stripComment(p.lines[p.n]))
+}
+
+func splitIndent(l string) (int, string) {
+	text := strings.TrimLeft(l, " ")
+	return len(l) - len(text), strings.TrimRight(text, " \t")
+}
+
+// Removes a comment, which starts with a '#' at the beginning of the
+// line or after a whitespace, outside of quotes.
+func stripComment(l string) string {
+	var quote byte
+	for i := 0; i < len(l); i++ {
+		c := l[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || strings.IndexByte(" \t[{,:", l[i-1]) >= 0 {
+				quote = c
+			}
+		case c == '#':
+			if i == 0 || l[i-1] == ' ' || l[i-1] == '\t' {
+				return l[:i]
+			}
+		}
+	}
+	return l
+}
+
+func isSeqEntry(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// Splits a mapping entry into its key and value, returning the offset
+// of the value in the text.
+func splitKey(text string) (string, string, int, bool) {
+	if text == "" || strings.IndexByte("[{&*!|>?", text[0]) >= 0 {
+		return "", "", 0, false
+	}
+	var key string
+	i := 0
+	if text[0] == '"' || text[0] == '\'' {
+		end := quoteEnd(text)
+		if end < 0 {
+			return "", "", 0, false
+		}
+		k, err := unquote(text[:end+1])
+		if err != nil {
+			return "", "", 0, false
+		}
+		key, i = k, end+1
+		for i < len(text) && text[i] == ' ' {
+			i++
+		}
+		if i == len(text) || text[i] != ':' {
+			return "", "", 0, false
+		}
+	} else {
+		for ; i < len(text); i++ {
+			if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ' || text[i+1] == '\t') {
+				break
+			}
+		}
+		if i == len(text) {
+			return "", "", 0, false
+		}
+		key = strings.TrimRight(text[:i], " \t")
+	}
+	// Skip the ':' and the spaces before the value.
+	i++
+	for i < len(text) && (text[i] == ' ' || text[i] == '\t') {
+		i++
+	}
+	return key, text[i:], i, true
+}
+
+// Returns the index of the closing quote of a quoted scalar, or -1.
+func quoteEnd(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case text[i] == '\\' && quote == '"':
+			i++
+		case text[i] == quote:
+			// Two single quotes are an escaped quote.
+			if quote == '\'' && i+1 < len(text) && text[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+func unquote(s string) (string, error) {
+	if s[0] == '\'' {
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	}
+	return strconv.Unquote(s)
+}
+
+// Returns the value of a node, wrapped with its position in pedantic mode.
+func (p *yamlParser) value(nd yamlNode) interface{} {
+	if !p.pedantic {
+		return nd.v
+	}
+	return &token{item{itemString, fmt.Sprint(nd.v), nd.line, nd.pos}, nd.v, false, p.fp, false, nil}
+}
+
+func (p *yamlParser) parseMap(indent int) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	for p.skipBlank() {
+		ind, text := p.current()
+		if ind < indent {
+			break
+		}
+		if ind > indent {
+			return nil, p.errorf(p.n, "unexpected indentation")
+		}
+		if isSeqEntry(text) {
+			return nil, p.errorf(p.n, "expected a mapping entry, got a sequence entry")
+		}
+		key, rest, offset, ok := splitKey(text)
+		if !ok {
+			return nil, p.errorf(p.n, "expected a mapping entry, got '%s'", text)
+		}
+		if _, ok := m[key]; ok {
+			return nil, p.errorf(p.n, "duplicate key '%s'", key)
+		}
+		// Report errors on the value at the position of the key.
+		line, pos := p.n+1, ind+1
+		v, err := p.parseEntryValue(indent, rest, ind+offset, true)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = p.value(yamlNode{v, line, pos})
+	}
+	return m, nil
+}
+
+func (p *yamlParser) parseSeq(indent int) ([]interface{}, error) {
+	a := make([]interface{}, 0)
+	for p.skipBlank() {
+		ind, text := p.current()
+		if ind < indent || (ind == indent && !isSeqEntry(text)) {
+			break
+		}
+		if ind > indent {
+			return nil, p.errorf(p.n, "unexpected indentation")
+		}
+		line := p.n + 1
+		rest := strings.TrimLeft(text[1:], " ")
+		// The entry continues after the dash, such as `- name: a`.
+		col := ind + len(text) - len(rest)
+		var v interface{}
+		var err error
+		if _, _, _, ok := splitKey(rest); ok || isSeqEntry(rest) {
+			p.lines[p.n] = strings.Repeat(" ", col) + rest
+			if ok {
+				v, err = p.parseMap(col)
+			} else {
+				v, err = p.parseSeq(col)
+			}
+		} else {
+			v, err = p.parseEntryValue(indent, rest, col, false)
+		}
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, p.value(yamlNode{v, line, col + 1}))
+	}
+	return a, nil
+}
+
+// Parses the value of a mapping or sequence entry found on the current line,
+// at the given column, with the value possibly continuing on the next lines.
+func (p *yamlParser) parseEntryValue(indent int, rest string, col int, inMap bool) (interface{}, error) {
+	n := p.n
+	switch {
+	case rest == "":
+		// The value is a nested block, or null.
+		p.n++
+		if !p.skipBlank() {
+			return nil, p.errorf(n, "missing value")
+		}
+		ind, text := p.current()
+		if ind > indent {
+			if isSeqEntry(text) {
+				return p.parseSeq(ind)
+			}
+			if _, _, _, ok := splitKey(text); ok {
+				return p.parseMap(ind)
+			}
+			return p.parsePlain(indent, "", col)
+		}
+		// A sequence can be at the same indentation as its key.
+		if inMap && ind == indent && isSeqEntry(text) {
+			return p.parseSeq(ind)
+		}
+		return nil, p.errorf(n, "missing value")
+	case rest[0] == '|' || rest[0] == '>':
+		return p.parseBlockScalar(indent, rest)
+	case rest[0] == '[' || rest[0] == '{':
+		return p.parseFlow(rest, col)
+	case rest[0] == '&' || rest[0] == '*' || rest[0] == '!':
+		return nil, p.errorf(n, "anchors, aliases and tags are not supported")
+	case rest[0] == '"' || rest[0] == '\'':
+		end := quoteEnd(rest)
+		if end < 0 {
+			return nil, p.errorf(n, "unterminated quoted string")
+		}
+		if strings.TrimSpace(rest[end+1:]) != "" {
+			return nil, p.errorf(n, "unexpected characters after quoted string")
+		}
+		s, err := unquote(rest[:end+1])
+		if err != nil {
+			return nil, p.errorf(n, "invalid quoted string %s", rest[:end+1])
+		}
+		p.n++
+		return s, nil
+	}
+	return p.parsePlain(indent, rest, col)
+}
+
+// Parses a plain scalar, which may continue on more indented lines.
+func (p *yamlParser) parsePlain(indent int, text string, col int) (interface{}, error) {
+	n := p.n
+	// Such as `a: b: c`, which would otherwise be read as the string "b: c".
+	if _, _, _, ok := splitKey(text); ok {
+		return nil, p.errorf(n, "mapping values are not allowed here")
+	}
+	parts := []string{}
+	if text != "" {
+		parts = append(parts, text)
+		p.n++
+	}
+	for p.skipBlank() {
+		ind, t := p.current()
+		if ind <= indent {
+			break
+		}
+		if _, _, _, ok := splitKey(t); ok || isSeqEntry(t) {
+			return nil, p.errorf(p.n, "unexpected indentation")
+		}
+		parts = append(parts, t)
+		p.n++
+	}
+	return p.resolve(strings.Join(parts, " "), n)
+}
+
+// Resolves a plain scalar the way the configuration format does, which
+// has no null value.
+func (p *yamlParser) resolve(s string, n int) (interface{}, error) {
+	switch {
+	case s == "":
+		return nil, p.errorf(n, "missing value")
+	case s == "~" || s == "null" || s == "Null" || s == "NULL":
+		return nil, p.errorf(n, "null values are not supported")
+	case yamlIntRe.MatchString(s) || yamlSizeRe.MatchString(s):
+		num, err := parseInteger(strings.TrimPrefix(s, "+"))
+		if err != nil {
+			return nil, p.errorf(n, "%v", err)
+		}
+		return num, nil
+	case yamlFloatRe.MatchString(s):
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, p.errorf(n, "float '%s' is out of the range", s)
+		}
+		return f, nil
+	}
+	if b, ok := parseBool(s); ok {
+		return b, nil
+	}
+	if dt, err := time.Parse("2006-01-02T15:04:05Z", s); err == nil {
+		return dt, nil
+	}
+	return s, nil
+}
+
+// Parses a literal `|` or folded `>` block scalar, with an optional
+// chomping indicator.
+func (p *yamlParser) parseBlockScalar(indent int, header string) (interface{}, error) {
+	n := p.n
+	folded := header[0] == '>'
+	chomp := byte(0)
+	for _, c := range []byte(strings.TrimSpace(header[1:])) {
+		switch c {
+		case '-', '+':
+			chomp = c
+		default:
+			return nil, p.errorf(n, "unsupported block scalar header '%s'", header)
+		}
+	}
+	p.n++
+	// The lines of the block are the ones more indented than the entry,
+	// comments are part of the content.
+	var lines []string
+	blockIndent := -1
+	for ; p.n < len(p.lines); p.n++ {
+		l := p.lines[p.n]
+		ind, text := splitIndent(l)
+		if text == "" {
+			lines = append(lines, "")
+			continue
+		}
+		if ind <= indent {
+			break
+		}
+		if blockIndent < 0 {
+			blockIndent = ind
+		}
+		if ind < blockIndent {
+			return nil, p.errorf(p.n, "unexpected indentation in block scalar")
+		}
+		lines = append(lines, strings.TrimRight(l[blockIndent:], " \t"))
+	}
+	// Trailing blank lines belong to the chomping.
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+	var b strings.Builder
+	for i, l := range lines {
+		switch {
+		case i == 0:
+		case !folded || l == "":
+			b.WriteByte('\n')
+		case lines[i-1] != "":
+			// Folded lines are joined, the break before a blank line is dropped.
+			b.WriteByte(' ')
+		}
+		b.WriteString(l)
+	}
+	switch {
+	case len(lines) == 0:
+	case chomp == '+':
+		b.WriteString(strings.Repeat("\n", trailing+1))
+	case chomp != '-':
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+// Parses a flow collection starting with the text, which may continue on
+// the next lines until its brackets are closed.
+func (p *yamlParser) parseFlow(text string, col int) (interface{}, error) {
+	fp := &yamlFlowParser{p: p}
+	fp.addLine(text, p.n, col)
+	for !fp.closed() {
+		p.n++
+		if p.n >= len(p.lines) {
+			return nil, p.errorf(fp.segs[0].n, "unterminated flow collection")
+		}
+		ind, t := p.current()
+		fp.addLine(t, p.n, ind)
+	}
+	p.n++
+	v, err := fp.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	fp.skipSpace()
+	if fp.i < len(fp.s) {
+		return nil, fp.errorf("unexpected characters after flow collection")
+	}
+	return v, nil
+}
+
+type yamlFlowParser struct {
+	p *yamlParser
+	s string
+	i int
+	// Where the lines of the flow collection start in s.
+	segs []yamlFlowSegment
+}
+
+type yamlFlowSegment struct {
+	start int
+	n     int
+	col   int
+}
+
+func (fp *yamlFlowParser) addLine(text string, n, col int) {
+	if fp.s != "" {
+		fp.s += "\n"
+	}
+	fp.segs = append(fp.segs, yamlFlowSegment{len(fp.s), n, col})
+	fp.s += text
+}
+
+// Returns whether all brackets are closed.
+func (fp *yamlFlowParser) closed() bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(fp.s); i++ {
+		c := fp.s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			// Like for comments, a quote inside a plain scalar is literal.
+			if i == 0 || strings.IndexByte(" \t\n[{,:", fp.s[i-1]) >= 0 {
+				quote = c
+			}
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+// Returns the line index and column of the current offset.
+func (fp *yamlFlowParser) position() (int, int) {
+	seg := fp.segs[0]
+	for _, s := range fp.segs {
+		if s.start <= fp.i {
+			seg = s
+		}
+	}
+	return seg.n, seg.col + fp.i - seg.start + 1
+}
+
+func (fp *yamlFlowParser) errorf(format string, args ...interface{}) error {
+	n, _ := fp.position()
+	return fp.p.errorf(n, format, args...)
+}
+
+func (fp *yamlFlowParser) skipSpace() {
+	for fp.i < len(fp.s) && strings.IndexByte(" \t\n", fp.s[fp.i]) >= 0 {
+		fp.i++
+	}
+}
+
+func (fp *yamlFlowParser) node(v interface{}, n, col int) interface{} {
+	return fp.p.value(yamlNode{v, n + 1, col})
+}
+
+func (fp *yamlFlowParser) parseValue() (interface{}, error) {
+	fp.skipSpace()
+	if fp.i >= len(fp.s) {
+		return nil, fp.errorf("unexpected end of flow collection")
+	}
+	switch c := fp.s[fp.i]; c {
+	case '[':
+		fp.i++
+		a := make([]interface{}, 0)
+		for {
+			fp.skipSpace()
+			if fp.i < len(fp.s) && fp.s[fp.i] == ']' {
+				fp.i++
+				return a, nil
+			}
+			n, col := fp.position()
+			v, err := fp.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, fp.node(v, n, col))
+			if err := fp.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		fp.i++
+		m := make(map[string]interface{})
+		for {
+			fp.skipSpace()
+			if fp.i < len(fp.s) && fp.s[fp.i] == '}' {
+				fp.i++
+				return m, nil
+			}
+			n, col := fp.position()
+			key, err := fp.parseScalar(true)
+			if err != nil {
+				return nil, err
+			}
+			fp.skipSpace()
+			if fp.i >= len(fp.s) || fp.s[fp.i] != ':' {
+				return nil, fp.errorf("expected ':' after key '%v'", key)
+			}
+			fp.i++
+			v, err := fp.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			k := fmt.Sprint(key)
+			if _, ok := m[k]; ok {
+				return nil, fp.p.errorf(n, "duplicate key '%s'", k)
+			}
+			m[k] = fp.node(v, n, col)
+			if err := fp.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	case '&', '*', '!':
+		return nil, fp.errorf("anchors, aliases and tags are not supported")
+	}
+	return fp.parseScalar(false)
+}
+
+// Expects a ',' or the end of the collection, which is not consumed.
+func (fp *yamlFlowParser) separator(end byte) error {
+	fp.skipSpace()
+	if fp.i >= len(fp.s) {
+		return fp.errorf("unterminated flow collection")
+	}
+	switch fp.s[fp.i] {
+	case ',':
+		fp.i++
+		return nil
+	case end:
+		return nil
+	}
+	return fp.errorf("expected ',' or '%c', got '%c'", end, fp.s[fp.i])
+}
+
+func (fp *yamlFlowParser) parseScalar(key bool) (interface{}, error) {
+	n, _ := fp.position()
+	if c := fp.s[fp.i]; c == '"' || c == '\'' {
+		end := quoteEnd(fp.s[fp.i:])
+		if end < 0 {
+			return nil, fp.errorf("unterminated quoted string")
+		}
+		q := fp.s[fp.i : fp.i+end+1]
+		fp.i += end + 1
+		s, err := unquote(strings.Replace(q, "\n", " ", -1))
+		if err != nil {
+			return nil, fp.errorf("invalid quoted string %s", q)
+		}
+		return s, nil
+	}
+	start := fp.i
+	for ; fp.i < len(fp.s); fp.i++ {
+		c := fp.s[fp.i]
+		if c == ',' || c == ']' || c == '}' || c == '[' || c == '{' {
+			break
+		}
+		if c == ':' && (key || fp.i+1 == len(fp.s) || strings.IndexByte(" \t\n,]}", fp.s[fp.i+1]) >= 0) {
+			break
+		}
+	}
+	s := strings.Join(strings.Fields(fp.s[start:fp.i]), " ")
+	if key {
+		return s, nil
+	}
+	return fp.p.resolve(s, n)
+}
@@ -0,0 +1,204 @@
+package conf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Parses the data as a file with the given extension.
+func parseAs(t *testing.T, ext, data string, pedantic bool) (map[string]interface{}, error) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "conf")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	fp := filepath.Join(dir, "test"+ext)
+	if err := ioutil.WriteFile(fp, []byte(data), 0644); err != nil {
+		t.Fatalf("Error writing file: %v", err)
+	}
+	if pedantic {
+		return ParseFileWithChecks(fp)
+	}
+	return ParseFile(fp)
+}
+
+var formatsConf = `
+port: 4222
+max_payload: 1MB
+debug: on
+ping_interval: 1.5
+cluster {
+  listen: "0.0.0.0:6222"
+  routes = ["nats://a:6222", "nats://b:6222"]
+}
+accounts {
+  A {
+    users = [{user: a, password: "p#1"}, {user: b, password: pwd}]
+  }
+}
+expires: 2020-01-02T15:04:05Z
+`
+
+var formatsYAML = `
+---
+# The same configuration as formatsConf.
+port: 4222
+max_payload: 1MB
+debug: on
+ping_interval: 1.5
+cluster:
+  listen: "0.0.0.0:6222"
+  routes:
+  - nats://a:6222
+  - nats://b:6222 # comment
+accounts:
+  A:
+    users:
+      - user: a
+        password: 'p#1'
+      - {user: b, password: pwd}
+expires: 2020-01-02T15:04:05Z
+`
+
+func TestYAMLSameAsConf(t *testing.T) {
+	ex, err := Parse(formatsConf)
+	if err != nil {
+		t.Fatalf("Received err: %v\n", err)
+	}
+	for _, ext := range []string{".yaml", ".yml"} {
+		m, err := parseAs(t, ext, formatsYAML, false)
+		if err != nil {
+			t.Fatalf("Received err: %v\n", err)
+		}
+		if !reflect.DeepEqual(m, ex) {
+			t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", m, ex)
+		}
+	}
+}
+
+func TestYAMLScalars(t *testing.T) {
+	m, err := parseAs(t, ".yaml", `
+a: |
+  line 1
+  # not a comment
+  line 2
+b: >-
+  folded
+  text
+
+  new paragraph
+c: "tab\t'quoted'"
+d: 'it''s'
+e: [it's, "other"]
+f: plain text
+  continued
+g: [1, -2, 3.5, "x, y", {h: no}]
+i: {
+  j: 1k,
+  k: [a,
+      b]
+}
+l: 01:00
+`, false)
+	if err != nil {
+		t.Fatalf("Received err: %v\n", err)
+	}
+	ex := map[string]interface{}{
+		"a": "line 1\n# not a comment\nline 2\n",
+		"b": "folded text\nnew paragraph",
+		"c": "tab\t'quoted'",
+		"d": "it's",
+		"e": []interface{}{"it's", "other"},
+		"f": "plain text continued",
+		"g": []interface{}{int64(1), int64(-2), 3.5, "x, y", map[string]interface{}{"h": false}},
+		"i": map[string]interface{}{"j": int64(1000), "k": []interface{}{"a", "b"}},
+		"l": "01:00",
+	}
+	if !reflect.DeepEqual(m, ex) {
+		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", m, ex)
+	}
+}
+
+func TestYAMLLineNumbers(t *testing.T) {
+	m, err := parseAs(t, ".yaml", `
+port: 4222
+cluster:
+  routes:
+    - nats://a:6222
+    - nats://b:6222
+`, true)
+	if err != nil {
+		t.Fatalf("Received err: %v\n", err)
+	}
+	checkToken := func(v interface{}, line, pos int) *token {
+		t.Helper()
+		tk, ok := v.(*token)
+		if !ok {
+			t.Fatalf("Expected a token, got %T", v)
+		}
+		if tk.Line() != line || tk.Position() != pos {
+			t.Fatalf("Expected %d:%d, got %d:%d", line, pos, tk.Line(), tk.Position())
+		}
+		if !strings.HasSuffix(tk.SourceFile(), "test.yaml") {
+			t.Fatalf("Unexpected source file %q", tk.SourceFile())
+		}
+		return tk
+	}
+	if tk := checkToken(m["port"], 2, 1); tk.Value() != int64(4222) {
+		t.Fatalf("Unexpected value: %v", tk.Value())
+	}
+	cluster := checkToken(m["cluster"], 3, 1).Value().(map[string]interface{})
+	routes := checkToken(cluster["routes"], 4, 3).Value().([]interface{})
+	if tk := checkToken(routes[1], 6, 7); tk.Value() != "nats://b:6222" {
+		t.Fatalf("Unexpected value: %v", tk.Value())
+	}
+}
+
+func TestYAMLErrors(t *testing.T) {
+	for _, test := range []struct {
+		data string
+		err  string
+	}{
+		{"- a\n- b\n", "line 1: 'expected a mapping at the top level'"},
+		{"a: 1\n  b: 2\n", "line 2: 'unexpected indentation'"},
+		{"a: 1\na: 2\n", "line 2: 'duplicate key 'a''"},
+		{"a: &x 1\n", "line 1: 'anchors, aliases and tags are not supported'"},
+		{"a: [1, 2\n", "line 1: 'unterminated flow collection'"},
+		{"a: \"x\n", "line 1: 'unterminated quoted string'"},
+		{"a: 1\n---\nb: 2\n", "line 2: 'multiple documents are not supported'"},
+		{"a:\n  b: 1\n  - c\n", "line 3: 'expected a mapping entry, got a sequence entry'"},
+		{"a: 1\nb:\n", "line 2: 'missing value'"},
+		{"a: 1\nb: ~\n", "line 2: 'null values are not supported'"},
+		{"a: [1, null]\n", "line 1: 'null values are not supported'"},
+		{"a:\n  - null\n", "line 2: 'null values are not supported'"},
+		{"a: {x: 1,\n  x: 2}\n", "line 2: 'duplicate key 'x''"},
+		{"a: b: c\n", "line 1: 'mapping values are not allowed here'"},
+		{"a:\n  - b: c: d\n", "line 2: 'mapping values are not allowed here'"},
+		{"a: {b: c: d}\n", "line 1: 'expected ',' or '}', got ':''"},
+	} {
+		_, err := parseAs(t, ".yaml", test.data, false)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Fatalf("Expected error %q for %q, got %v", test.err, test.data, err)
+		}
+	}
+}
+
+func TestYAMLEmptyAndDocumentEnd(t *testing.T) {
+	m, err := parseAs(t, ".yaml", "# nothing\n", false)
+	if err != nil || len(m) != 0 {
+		t.Fatalf("Expected an empty map, got %v, %v", m, err)
+	}
+	m, err = parseAs(t, ".yaml", "%YAML 1.2\n---\na: 2020-01-02T15:04:05Z\n...\nignored\n", false)
+	if err != nil {
+		t.Fatalf("Received err: %v\n", err)
+	}
+	if dt, _ := time.Parse(time.RFC3339, "2020-01-02T15:04:05Z"); !reflect.DeepEqual(m, map[string]interface{}{"a": dt}) {
+		t.Fatalf("Unexpected map: %v", m)
+	}
+}
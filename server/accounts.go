@@ -14,6 +14,8 @@
 package server
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
@@ -23,6 +25,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/nats-io/jwt"
@@ -35,6 +38,8 @@ const globalAccountName = "$G"
 // Account are subject namespace definitions. By default no messages are shared between accounts.
 // You can share via Exports and Imports of Streams and Services.
 type Account struct {
+	// Here first because of use of atomics, and memory alignment.
+	stats        accountStats
 	Name         string
 	Nkey         string
 	Issuer       string
@@ -60,12 +65,116 @@ type Account struct {
 	imports      importMap
 	exports      exportMap
 	limits
-	nae         int32
-	pruning     bool
-	rmPruning   bool
-	expired     bool
-	signingKeys []string
-	srv         *Server // server this account is registered with (possibly nil)
+	nae          int32
+	pruning      bool
+	rmPruning    bool
+	expired      bool
+	signingKeys  []string
+	signingRoles map[string]*SigningKeyRole
+	lstats       map[string]*latencyStats
+	lastStats    TrafficStats
+	srcFilter    *sourceFilter
+	nameTag      string
+	tags         jwt.TagList
+	pviol        *PermViolationOpts
+	srv          *Server // server this account is registered with (possibly nil)
+}
+
+// trafficStats are the messages and bytes received and sent.
+// Make sure all are 64bits for atomic use.
+type trafficStats struct {
+	inMsgs   int64
+	inBytes  int64
+	outMsgs  int64
+	outBytes int64
+}
+
+// accountStats are the traffic statistics of an account, by kind of
+// connection the messages were received from or sent to.
+type accountStats struct {
+	clients  trafficStats
+	routes   trafficStats
+	gateways trafficStats
+	leafs    trafficStats
+}
+
+// Returns the statistics for the given kind of connection, nil for
+// internal connections which are not accounted for.
+func (a *Account) trafficStats(kind int) *trafficStats {
+	switch kind {
+	case CLIENT:
+		return &a.stats.clients
+	case ROUTER:
+		return &a.stats.routes
+	case GATEWAY:
+		return &a.stats.gateways
+	case LEAF:
+		return &a.stats.leafs
+	}
+	return nil
+}
+
+// addInbound accounts for messages received from a connection of the
+// given kind.
+func (a *Account) addInbound(kind int, msgs, bytes int64) {
+	if ts := a.trafficStats(kind); ts != nil {
+		atomic.AddInt64(&ts.inMsgs, msgs)
+		atomic.AddInt64(&ts.inBytes, bytes)
+	}
+}
+
+// addOutbound accounts for messages sent to a connection of the given
+// kind.
+func (a *Account) addOutbound(kind int, msgs, bytes int64) {
+	if ts := a.trafficStats(kind); ts != nil {
+		atomic.AddInt64(&ts.outMsgs, msgs)
+		atomic.AddInt64(&ts.outBytes, bytes)
+	}
+}
+
+// TrafficStats are the messages and bytes the server received from, and
+// sent to, connections.
+type TrafficStats struct {
+	Received DataStats `json:"received"`
+	Sent     DataStats `json:"sent"`
+}
+
+func (ts *trafficStats) load() TrafficStats {
+	return TrafficStats{
+		Received: DataStats{Msgs: atomic.LoadInt64(&ts.inMsgs), Bytes: atomic.LoadInt64(&ts.inBytes)},
+		Sent:     DataStats{Msgs: atomic.LoadInt64(&ts.outMsgs), Bytes: atomic.LoadInt64(&ts.outBytes)},
+	}
+}
+
+func (ts *TrafficStats) add(o TrafficStats) {
+	ts.Received.Msgs += o.Received.Msgs
+	ts.Received.Bytes += o.Received.Bytes
+	ts.Sent.Msgs += o.Sent.Msgs
+	ts.Sent.Bytes += o.Sent.Bytes
+}
+
+// AccountTrafficStats are the traffic statistics of an account on this
+// server, in total and by kind of connection.
+type AccountTrafficStats struct {
+	Total     TrafficStats `json:"total"`
+	Clients   TrafficStats `json:"clients"`
+	Routes    TrafficStats `json:"routes"`
+	Gateways  TrafficStats `json:"gateways"`
+	LeafNodes TrafficStats `json:"leafnodes"`
+}
+
+// TrafficStats returns the traffic statistics of the account on this server.
+func (a *Account) TrafficStats() *AccountTrafficStats {
+	ats := &AccountTrafficStats{
+		Clients:   a.stats.clients.load(),
+		Routes:    a.stats.routes.load(),
+		Gateways:  a.stats.gateways.load(),
+		LeafNodes: a.stats.leafs.load(),
+	}
+	for _, ts := range []TrafficStats{ats.Clients, ats.Routes, ats.Gateways, ats.LeafNodes} {
+		ats.Total.add(ts)
+	}
+	return ats
 }
 
 // Account based limits.
@@ -90,8 +199,13 @@ type streamImport struct {
 	acc     *Account
 	from    string
 	prefix  string
+	to      string
+	tr      *subjectTransform
 	claim   *jwt.Import
 	invalid bool
+	// Set for the imports of the account's advisories, which are
+	// added by the server and always authorized.
+	system bool
 }
 
 // Import service mapping struct
@@ -108,6 +222,8 @@ type serviceImport struct {
 	internal bool
 	invalid  bool
 	tracking bool
+	tmr      *time.Timer
+	tr       *subjectTransform
 }
 
 // This is used to record when we create a mapping for implicit service
@@ -177,6 +293,11 @@ type exportMap struct {
 type importMap struct {
 	streams  map[string]*streamImport
 	services map[string]*serviceImport // TODO(dlc) sync.Map may be better.
+	// Service imports with a subject transform, also held in services
+	// keyed by their wildcard pattern. Kept apart so that they can be
+	// matched without going through all services, in the order they
+	// were added.
+	trs []*serviceImport
 }
 
 // NewAccount creates a new unlimited account with the given name.
@@ -196,6 +317,11 @@ func (a *Account) shallowCopy() *Account {
 	na.Issuer = a.Issuer
 	na.imports = a.imports
 	na.exports = a.exports
+	na.srcFilter = a.srcFilter
+	na.nameTag = a.nameTag
+	na.tags = a.tags
+	na.pviol = a.pviol
+	na.lstats = a.lstats
 	return na
 }
 
@@ -542,6 +668,7 @@ func (a *Account) TrackServiceExportWithSampling(service, results string, sampli
 		sampling: int8(sampling),
 		subject:  results,
 	}
+	a.latencyStatsForService(service, results)
 	s := a.srv
 	a.mu.Unlock()
 
@@ -582,6 +709,7 @@ func (a *Account) UnTrackServiceExport(service string) {
 		return
 	}
 	// We have latency here.
+	delete(a.lstats, ea.latency.subject)
 	ea.latency = nil
 	s := a.srv
 	a.mu.Unlock()
@@ -660,9 +788,26 @@ func (nl *NATSLatency) TotalTime() time.Duration {
 	return nl.Requestor + nl.Responder + nl.System
 }
 
+// Outcomes of the requests reported by latency tracking.
+const (
+	// LatencyStatusOK is the status of a request that got a response.
+	LatencyStatusOK = "ok"
+	// LatencyStatusTimeout is the status of a request whose response map
+	// expired, or was pruned, before getting a response.
+	LatencyStatusTimeout = "timeout"
+	// LatencyStatusNoResponders is the status of a request that had no
+	// responders in the exporting account.
+	LatencyStatusNoResponders = "no_responders"
+	// LatencyStatusPermissionDenied is the status of a request whose
+	// responder was not allowed to publish the response.
+	LatencyStatusPermissionDenied = "permission_denied"
+)
+
 // ServiceLatency is the JSON message sent out in response to latency tracking for
-// exported services.
+// exported services. Only the total latency is known for requests that did
+// not get a response.
 type ServiceLatency struct {
+	Status         string        `json:"status"`
 	AppName        string        `json:"app,omitempty"`
 	RequestStart   time.Time     `json:"start"`
 	ServiceLatency time.Duration `json:"svc"`
@@ -731,6 +876,7 @@ func (a *Account) sendTrackingLatency(si *serviceImport, requestor, responder *c
 	// and the client RTT for the requestor.
 	reqStart := time.Unix(0, si.ts-int64(reqClientRTT))
 	sl := ServiceLatency{
+		Status:         LatencyStatusOK,
 		AppName:        appName,
 		RequestStart:   reqStart,
 		ServiceLatency: serviceRTT - respClientRTT,
@@ -754,14 +900,14 @@ func (a *Account) sendTrackingLatency(si *serviceImport, requestor, responder *c
 			m1, m2 := &sl, si.m1
 			m1.merge(m2)
 			si.acc.mu.Unlock()
-			a.srv.sendInternalAccountMsg(a, si.latency.subject, m1)
+			a.reportLatency(si.latency.subject, m1)
 			return true
 		}
 		si.m1 = &sl
 		si.acc.mu.Unlock()
 		return false
 	} else {
-		a.srv.sendInternalAccountMsg(a, si.latency.subject, &sl)
+		a.reportLatency(si.latency.subject, &sl)
 	}
 	return true
 }
@@ -783,15 +929,26 @@ func (a *Account) AddServiceImportWithClaim(destination *Account, from, to strin
 	if to == "" {
 		to = from
 	}
+	var tr *subjectTransform
 	if !IsValidLiteralSubject(from) || !IsValidLiteralSubject(to) {
-		return ErrInvalidSubject
+		// From is then a template for the subjects matching to, e.g.
+		// "partner.$1.request" for "requests.*". Every wildcard has to be
+		// referenced so that published subjects can be mapped back.
+		var err error
+		if tr, err = newSubjectTransform(to, from); err != nil {
+			return err
+		}
+		if !tr.isReversible() {
+			return ErrBadSubjectTransform
+		}
+		from = tr.pattern
 	}
 	// First check to see if the account has authorized us to route to the "to" subject.
 	if !destination.checkServiceImportAuthorized(a, to, imClaim) {
 		return ErrServiceImportAuthorization
 	}
 
-	_, err := a.addServiceImport(destination, from, to, imClaim)
+	_, err := a.addServiceImport(destination, from, to, tr, imClaim)
 	return err
 }
 
@@ -803,6 +960,197 @@ func (a *Account) AddServiceImport(destination *Account, from, to string) error
 	return a.AddServiceImportWithClaim(destination, from, to, nil)
 }
 
+// importEdge is a service import of an account, an edge of the graph
+// of the accounts walked to find import cycles.
+type importEdge struct {
+	acc *Account
+	si  *serviceImport
+}
+
+// serviceImportEdges returns the service imports of all accounts, without
+// the response mappings.
+func (s *Server) serviceImportEdges() []importEdge {
+	var accs []*Account
+	s.accounts.Range(func(k, v interface{}) bool {
+		accs = append(accs, v.(*Account))
+		return true
+	})
+	return serviceImportEdges(accs)
+}
+
+// serviceImportEdges returns the service imports of the accounts, without
+// the response mappings.
+func serviceImportEdges(accs []*Account) []importEdge {
+	var edges []importEdge
+	for _, acc := range accs {
+		acc.mu.RLock()
+		for _, si := range acc.imports.services {
+			if !si.internal {
+				edges = append(edges, importEdge{acc, si})
+			}
+		}
+		acc.mu.RUnlock()
+	}
+	// Walk the edges in a stable order.
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].acc.Name != edges[j].acc.Name {
+			return edges[i].acc.Name < edges[j].acc.Name
+		}
+		return edges[i].si.from < edges[j].si.from
+	})
+	return edges
+}
+
+// findServiceImportCycle returns a cycle of service imports, where a request
+// mapped by an import can be mapped again by the next one, up to the first
+// import. If start is not nil, only cycles through its imports are returned.
+func findServiceImportCycle(edges []importEdge, start *Account) []importEdge {
+	return newImportGraph(edges).cycle(start)
+}
+
+// importGraph links each service import to the imports of its destination
+// account that can map the requests it mapped again. The imports in a cycle
+// are found with a single pass computing the strongly connected components.
+type importGraph struct {
+	edges []importEdge
+	next  [][]int
+	// Strongly connected component of each import, and their sizes.
+	comp  []int
+	sizes []int
+}
+
+func newImportGraph(edges []importEdge) *importGraph {
+	g := &importGraph{edges: edges, next: make([][]int, len(edges))}
+	// Only the imports of the destination account can follow an import.
+	byAcc := make(map[string][]int)
+	for i, e := range edges {
+		byAcc[e.acc.Name] = append(byAcc[e.acc.Name], i)
+	}
+	for i, e := range edges {
+		for _, j := range byAcc[e.si.acc.Name] {
+			if subjectsIntersect(edges[j].si.from, e.si.to) {
+				g.next[i] = append(g.next[i], j)
+			}
+		}
+	}
+	g.components()
+	return g
+}
+
+// Computes the strongly connected components with Tarjan's algorithm.
+func (g *importGraph) components() {
+	n := len(g.edges)
+	g.comp = make([]int, n)
+	index := make([]int, n)
+	low := make([]int, n)
+	onStack := make([]bool, n)
+	var stack []int
+	counter := 0
+	for i := range index {
+		index[i] = -1
+	}
+	var visit func(v int)
+	visit = func(v int) {
+		index[v], low[v] = counter, counter
+		counter++
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range g.next[v] {
+			if index[w] < 0 {
+				visit(w)
+				if low[w] < low[v] {
+					low[v] = low[w]
+				}
+			} else if onStack[w] && index[w] < low[v] {
+				low[v] = index[w]
+			}
+		}
+		if low[v] != index[v] {
+			return
+		}
+		c, size := len(g.sizes), 0
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			g.comp[w] = c
+			size++
+			if w == v {
+				break
+			}
+		}
+		g.sizes = append(g.sizes, size)
+	}
+	for i := range g.edges {
+		if index[i] < 0 {
+			visit(i)
+		}
+	}
+}
+
+// Returns whether the import is part of a cycle.
+func (g *importGraph) inCycle(i int) bool {
+	if g.sizes[g.comp[i]] > 1 {
+		return true
+	}
+	for _, j := range g.next[i] {
+		if j == i {
+			return true
+		}
+	}
+	return false
+}
+
+// cycle returns the shortest cycle through the first import in a cycle,
+// restricted to the imports of start if not nil.
+func (g *importGraph) cycle(start *Account) []importEdge {
+	for i, e := range g.edges {
+		if (start != nil && e.acc.Name != start.Name) || !g.inCycle(i) {
+			continue
+		}
+		// Search the way back to the import within its component.
+		prev := make(map[int]int)
+		queue := []int{i}
+		for len(queue) > 0 {
+			v := queue[0]
+			queue = queue[1:]
+			for _, w := range g.next[v] {
+				if g.comp[w] != g.comp[i] {
+					continue
+				}
+				if w == i {
+					path := []importEdge{g.edges[v]}
+					for v != i {
+						v = prev[v]
+						path = append(path, g.edges[v])
+					}
+					// The path was built backwards.
+					for l, r := 0, len(path)-1; l < r; l, r = l+1, r-1 {
+						path[l], path[r] = path[r], path[l]
+					}
+					return path
+				}
+				if _, ok := prev[w]; !ok {
+					prev[w] = v
+					queue = append(queue, w)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// importCyclePath describes a cycle of service imports, such as
+// `A "req" -> B "svc.req" -> A "req"`.
+func importCyclePath(cycle []importEdge) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %q", cycle[0].acc.Name, cycle[0].si.from)
+	for _, e := range cycle {
+		fmt.Fprintf(&b, " -> %s %q", e.si.acc.Name, e.si.to)
+	}
+	return b.String()
+}
+
 // removeServiceImport will remove the route by subject.
 func (a *Account) removeServiceImport(subject string) {
 	a.mu.Lock()
@@ -810,6 +1158,12 @@ func (a *Account) removeServiceImport(subject string) {
 	if ok && si != nil && si.ae {
 		a.nae--
 	}
+	if ok && si != nil && si.tmr != nil {
+		si.tmr.Stop()
+	}
+	if ok && si != nil && si.tr != nil {
+		a.imports.removeServiceTransform(si)
+	}
 	delete(a.imports.services, subject)
 	a.mu.Unlock()
 	if a.srv != nil && a.srv.gateway.enabled {
@@ -817,6 +1171,52 @@ func (a *Account) removeServiceImport(subject string) {
 	}
 }
 
+// removeTrackedResponse will remove the response mapping of a tracked
+// request that did not get a response, and report its latency with the
+// given status. Nothing is reported if the mapping was already removed.
+func (a *Account) removeTrackedResponse(si *serviceImport, status string) {
+	a.mu.Lock()
+	if a.imports.services[si.from] != si {
+		a.mu.Unlock()
+		return
+	}
+	if si.ae {
+		a.nae--
+	}
+	if si.tmr != nil {
+		si.tmr.Stop()
+	}
+	delete(a.imports.services, si.from)
+	// A response was received, we are only missing the remote measurement.
+	pending := si.m1 != nil
+	a.mu.Unlock()
+	if a.srv != nil && a.srv.gateway.enabled {
+		a.srv.gatewayHandleServiceImport(a, []byte(si.from), nil, -1)
+	}
+	if pending || si.latency == nil {
+		return
+	}
+	now := time.Now()
+	start := time.Unix(0, si.ts)
+	a.reportLatency(si.latency.subject, &ServiceLatency{
+		Status:       status,
+		RequestStart: start,
+		TotalLatency: now.Sub(start),
+	})
+}
+
+// checkDeniedResponse is called when a client is not allowed to publish
+// on the subject, to report the latency of the tracked request whose
+// response is therefore dropped.
+func (a *Account) checkDeniedResponse(subject string) {
+	a.mu.RLock()
+	si := a.imports.services[subject]
+	a.mu.RUnlock()
+	if si != nil && si.tracking {
+		a.removeTrackedResponse(si, LatencyStatusPermissionDenied)
+	}
+}
+
 // This tracks responses to service requests mappings. This is used for cleanup.
 func (a *Account) addRespMapEntry(acc *Account, reply, from string) {
 	a.mu.Lock()
@@ -941,10 +1341,34 @@ func (a *Account) SetMaxResponseMaps(max int) {
 	a.maxnrm = int32(max)
 }
 
+// matchServiceTransform returns the first service import with a subject
+// transform whose pattern matches the literal subject.
+// Lock should be held.
+func (im *importMap) matchServiceTransform(subject string) *serviceImport {
+	for _, si := range im.trs {
+		if matchLiteral(subject, si.from) {
+			return si
+		}
+	}
+	return nil
+}
+
+// removeServiceTransform removes the service import from the ones with
+// a subject transform.
+// Lock should be held.
+func (im *importMap) removeServiceTransform(si *serviceImport) {
+	for i, tsi := range im.trs {
+		if tsi == si {
+			im.trs = append(im.trs[:i:i], im.trs[i+1:]...)
+			return
+		}
+	}
+}
+
 // Add a route to connect from an implicit route created for a response to a request.
 // This does no checks and should be only called by the msg processing code. Use
 // AddServiceImport from above if responding to user input or config changes, etc.
-func (a *Account) addServiceImport(dest *Account, from, to string, claim *jwt.Import) (*serviceImport, error) {
+func (a *Account) addServiceImport(dest *Account, from, to string, tr *subjectTransform, claim *jwt.Import) (*serviceImport, error) {
 	rt := Singleton
 	var lat *serviceLatency
 
@@ -963,8 +1387,11 @@ func (a *Account) addServiceImport(dest *Account, from, to string, claim *jwt.Im
 		return nil, fmt.Errorf("duplicate service import subject %q, previously used in import for account %q, subject %q",
 			from, dup.acc.Name, dup.to)
 	}
-	si := &serviceImport{dest, claim, from, to, 0, rt, lat, nil, false, false, false, false}
+	si := &serviceImport{dest, claim, from, to, 0, rt, lat, nil, false, false, false, false, nil, tr}
 	a.imports.services[from] = si
+	if tr != nil {
+		a.imports.trs = append(a.imports.trs, si)
+	}
 	a.mu.Unlock()
 
 	return si, nil
@@ -988,7 +1415,7 @@ func (a *Account) addRespServiceImport(dest *Account, from, to string, rt Servic
 		a.imports.services = make(map[string]*serviceImport)
 	}
 	ae := rt == Singleton
-	si := &serviceImport{dest, nil, from, to, 0, rt, nil, nil, ae, true, false, false}
+	si := &serviceImport{dest, nil, from, to, 0, rt, nil, nil, ae, true, false, false, nil, nil}
 	a.imports.services[from] = si
 	if ae {
 		a.nae++
@@ -996,6 +1423,12 @@ func (a *Account) addRespServiceImport(dest *Account, from, to string, rt Servic
 		if lat != nil {
 			si.latency = lat
 			si.tracking = true
+			// Report the request as timed out if it does not get a response.
+			if a.maxaettl > 0 {
+				si.tmr = time.AfterFunc(a.maxaettl, func() {
+					a.removeTrackedResponse(si, LatencyStatusTimeout)
+				})
+			}
 		}
 		if a.nae > a.maxnae && !a.pruning {
 			a.pruning = true
@@ -1044,7 +1477,7 @@ func (a *Account) pruneAutoExpireResponseMaps() {
 		now := time.Now().UnixNano()
 		for i, si := range sis {
 			if now-si.ts >= ttl {
-				a.removeServiceImport(si.from)
+				a.removeAutoExpireResponseMap(si)
 			} else {
 				sis = sis[i:]
 				break
@@ -1062,13 +1495,54 @@ func (a *Account) pruneAutoExpireResponseMaps() {
 		}
 		// These are in sorted order, remove at least numOver
 		for _, si := range sis[:numOver] {
-			a.removeServiceImport(si.from)
+			a.removeAutoExpireResponseMap(si)
 		}
 	}
 }
 
+// Removes the auto-expire response map, reporting the request as timed
+// out if it is tracked.
+func (a *Account) removeAutoExpireResponseMap(si *serviceImport) {
+	if si.tracking {
+		a.removeTrackedResponse(si, LatencyStatusTimeout)
+	} else {
+		a.removeServiceImport(si.from)
+	}
+}
+
 // AddStreamImportWithClaim will add in the stream import from a specific account with optional token.
 func (a *Account) AddStreamImportWithClaim(account *Account, from, prefix string, imClaim *jwt.Import) error {
+	if prefix != "" && prefix[len(prefix)-1] != btsep {
+		prefix = prefix + string(btsep)
+	}
+	return a.addStreamImport(account, from, prefix, nil, imClaim)
+}
+
+// AddStreamImport will add in the stream import from a specific account.
+func (a *Account) AddStreamImport(account *Account, from, prefix string) error {
+	return a.AddStreamImportWithClaim(account, from, prefix, nil)
+}
+
+// AddMappedStreamImportWithClaim will add in the stream import from a specific
+// account with optional token, delivering the messages on the subjects given by
+// the destination template. References `$n` in the template are replaced by the
+// token matched by the n-th `*` wildcard of from, e.g. "events.*.created"
+// imported to "partner.$1.new".
+func (a *Account) AddMappedStreamImportWithClaim(account *Account, from, to string, imClaim *jwt.Import) error {
+	tr, err := newSubjectTransform(from, to)
+	if err != nil {
+		return err
+	}
+	return a.addStreamImport(account, from, "", tr, imClaim)
+}
+
+// AddMappedStreamImport will add in the stream import from a specific account,
+// mapping its subjects into the destination template.
+func (a *Account) AddMappedStreamImport(account *Account, from, to string) error {
+	return a.AddMappedStreamImportWithClaim(account, from, to, nil)
+}
+
+func (a *Account) addStreamImport(account *Account, from, prefix string, tr *subjectTransform, imClaim *jwt.Import) error {
 	if account == nil {
 		return ErrMissingAccount
 	}
@@ -1083,17 +1557,28 @@ func (a *Account) AddStreamImportWithClaim(account *Account, from, prefix string
 	if a.imports.streams == nil {
 		a.imports.streams = make(map[string]*streamImport)
 	}
-	if prefix != "" && prefix[len(prefix)-1] != btsep {
-		prefix = prefix + string(btsep)
+	im := &streamImport{acc: account, from: from, prefix: prefix, tr: tr, claim: imClaim}
+	if tr != nil {
+		im.to = tr.dest
 	}
 	// TODO(dlc) - collisions, etc.
-	a.imports.streams[from] = &streamImport{account, from, prefix, imClaim, false}
+	a.imports.streams[from] = im
 	return nil
 }
 
-// AddStreamImport will add in the stream import from a specific account.
-func (a *Account) AddStreamImport(account *Account, from, prefix string) error {
-	return a.AddStreamImportWithClaim(account, from, prefix, nil)
+// Returns whether the subjects of the import need to be mapped on delivery.
+func (im *streamImport) isMapped() bool {
+	return im.prefix != "" || im.tr != nil
+}
+
+// appendSubject appends the subject of a message delivered through this
+// import, as seen in the importing account.
+func (im *streamImport) appendSubject(b, subject []byte) []byte {
+	if im.tr != nil {
+		return append(b, im.tr.transform(string(subject))...)
+	}
+	b = append(b, im.prefix...)
+	return append(b, subject...)
 }
 
 // IsPublicExport is a placeholder to denote a public export.
@@ -1415,7 +1900,7 @@ func (a *Account) checkStreamImportsEqual(b *Account) bool {
 		if bim == nil {
 			return false
 		}
-		if aim.acc.Name != bim.acc.Name || aim.from != bim.from || aim.prefix != bim.prefix {
+		if aim.acc.Name != bim.acc.Name || aim.from != bim.from || aim.prefix != bim.prefix || aim.to != bim.to {
 			return false
 		}
 	}
@@ -1464,7 +1949,7 @@ func (a *Account) checkServiceImportAuthorized(account *Account, subject string,
 // Check if another account is authorized to route requests to this service.
 func (a *Account) checkServiceImportAuthorizedNoLock(account *Account, subject string, imClaim *jwt.Import) bool {
 	// Find the subject in the services list.
-	if a.exports.services == nil || !IsValidLiteralSubject(subject) {
+	if a.exports.services == nil || !IsValidSubject(subject) {
 		return false
 	}
 	return a.checkServiceExportApproved(account, subject, imClaim)
@@ -1563,6 +2048,129 @@ func (a *Account) hasIssuer(issuer string) bool {
 	return false
 }
 
+// SigningKeyRole is a template of permissions and limits bound to an
+// account signing key. It applies to every user signed with that key,
+// in place of the permissions and limits of the user JWT. Roles are
+// carried in the "signing_key_roles" map of the account JWT, keyed by
+// signing key.
+type SigningKeyRole struct {
+	Role     string   `json:"role"`
+	Template jwt.User `json:"template"`
+}
+
+// Returns the role of the given signing key, if any.
+func (a *Account) signingKeyRole(key string) *SigningKeyRole {
+	if a == nil {
+		return nil
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.signingRoles[key]
+}
+
+// decodeSigningKeyRoles returns the roles of the signing keys found in
+// the raw account JWT. The jwt library does not know about them, so they
+// are read from the payload, which has been verified along with the
+// claims. Roles of keys that are not signing keys of the account are
+// ignored.
+func decodeSigningKeyRoles(claimJWT string, ac *jwt.AccountClaims) (map[string]*SigningKeyRole, error) {
+	chunks := strings.Split(claimJWT, ".")
+	if len(ac.SigningKeys) == 0 || len(chunks) != 3 {
+		return nil, nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(chunks[1])
+	if err != nil {
+		return nil, err
+	}
+	var raw struct {
+		ID   string `json:"jti"`
+		Nats struct {
+			Roles map[string]*SigningKeyRole `json:"signing_key_roles"`
+		} `json:"nats"`
+	}
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, err
+	}
+	// Make sure the token is the one the claims were decoded from.
+	if raw.ID != ac.ID {
+		return nil, nil
+	}
+	var roles map[string]*SigningKeyRole
+	for _, sk := range ac.SigningKeys {
+		role := raw.Nats.Roles[sk]
+		if role == nil {
+			continue
+		}
+		if role.Role == "" {
+			return nil, fmt.Errorf("signing key %q has a role with no name", sk)
+		}
+		vr := jwt.CreateValidationResults()
+		role.Template.Validate(vr)
+		if errs := vr.Errors(); len(errs) > 0 {
+			return nil, fmt.Errorf("role %q of signing key %q is invalid: %v", role.Role, sk, errs[0])
+		}
+		if roles == nil {
+			roles = make(map[string]*SigningKeyRole)
+		}
+		roles[sk] = role
+	}
+	return roles, nil
+}
+
+// signingKeyRoles returns the roles of the signing keys of the account
+// JWT the claims were decoded from. If they are invalid, users of scoped
+// signing keys are not allowed to do anything, since we can't enforce
+// their roles.
+func (s *Server) signingKeyRoles(ac *jwt.AccountClaims, claimJWT string) map[string]*SigningKeyRole {
+	roles, err := decodeSigningKeyRoles(claimJWT, ac)
+	if err != nil {
+		s.Errorf("Account %q has invalid signing key roles: %v", ac.Subject, err)
+		roles = make(map[string]*SigningKeyRole, len(ac.SigningKeys))
+		for _, sk := range ac.SigningKeys {
+			roles[sk] = denyAllSigningKeyRole(sk)
+		}
+	}
+	return roles
+}
+
+// Returns the current roles of the signing keys that are in the claims.
+func (a *Account) keptSigningKeyRoles(ac *jwt.AccountClaims) map[string]*SigningKeyRole {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	var roles map[string]*SigningKeyRole
+	for _, sk := range ac.SigningKeys {
+		if role := a.signingRoles[sk]; role != nil {
+			if roles == nil {
+				roles = make(map[string]*SigningKeyRole)
+			}
+			roles[sk] = role
+		}
+	}
+	return roles
+}
+
+// Returns a role that does not allow anything, used for the signing keys
+// of an account whose roles are invalid.
+func denyAllSigningKeyRole(key string) *SigningKeyRole {
+	role := &SigningKeyRole{Role: "invalid"}
+	role.Template.Pub.Deny.Add(">")
+	role.Template.Sub.Deny.Add(">")
+	return role
+}
+
+// applySigningKeyRole replaces the permissions and limits of the user
+// with the ones of the role.
+func (nu *NkeyUser) applySigningKeyRole(role *SigningKeyRole) {
+	t := &role.Template
+	nu.role = role.Role
+	nu.Permissions = permissionsFromJWT(&t.Permissions)
+	nu.AllowedCIDRs, nu.DeniedCIDRs = nil, nil
+	if t.Src != "" {
+		nu.AllowedCIDRs = strings.Split(t.Src, ",")
+	}
+	nu.msubs, nu.mpay = int32(t.Max), int32(t.Payload)
+}
+
 // Placeholder for signaling token auth required.
 var tokenAuthReq = []*Account{}
 
@@ -1594,9 +2202,21 @@ func (s *Server) UpdateAccountClaims(a *Account, ac *jwt.AccountClaims) {
 }
 
 // updateAccountClaims will update an existing account with new claims.
-// This will replace any exports or imports previously defined.
+// The claims don't carry the roles of the signing keys, so the ones of
+// the signing keys that are kept remain in effect.
 // Lock MUST NOT be held upon entry.
 func (s *Server) updateAccountClaims(a *Account, ac *jwt.AccountClaims) {
+	if a == nil {
+		return
+	}
+	s.updateAccountClaimsWithRoles(a, ac, a.keptSigningKeyRoles(ac))
+}
+
+// updateAccountClaimsWithRoles will update an existing account with new
+// claims and the roles of their signing keys.
+// This will replace any exports or imports previously defined.
+// Lock MUST NOT be held upon entry.
+func (s *Server) updateAccountClaimsWithRoles(a *Account, ac *jwt.AccountClaims, roles map[string]*SigningKeyRole) {
 	if a == nil {
 		return
 	}
@@ -1625,10 +2245,29 @@ func (s *Server) updateAccountClaims(a *Account, ac *jwt.AccountClaims) {
 		old.imports.services[k] = v
 		delete(a.imports.services, k)
 	}
+	a.imports.trs = nil
 	// Reset any notion of export revocations.
 	a.actsRevoked = nil
 
+	// The account name and tags are expanded in permission templates.
+	a.nameTag = ac.Name
+	a.tags = ac.Tags
+
+	// Update the source address restrictions, carried as tags.
+	if allowed, denied := cidrsFromTags(ac.Tags); len(allowed) > 0 || len(denied) > 0 {
+		f, err := newSourceFilter(allowed, denied)
+		if err != nil {
+			s.Errorf("Account %q has invalid source address restrictions: %v", a.Name, err)
+			// Do not let any connection through if we can't enforce them.
+			f = denyAllSourceFilter
+		}
+		a.srcFilter = f
+	} else {
+		a.srcFilter = nil
+	}
+
 	// update account signing keys
+	old.signingRoles = a.signingRoles
 	a.signingKeys = nil
 	signersChanged := false
 	if len(ac.SigningKeys) > 0 {
@@ -1646,6 +2285,8 @@ func (s *Server) updateAccountClaims(a *Account, ac *jwt.AccountClaims) {
 			}
 		}
 	}
+	a.signingRoles = roles
+	rolesChanged := !reflect.DeepEqual(roles, old.signingRoles)
 	a.mu.Unlock()
 
 	gatherClients := func() []*client {
@@ -1695,6 +2336,7 @@ func (s *Server) updateAccountClaims(a *Account, ac *jwt.AccountClaims) {
 			a.mu.Unlock()
 		}
 	}
+	a.pruneLatencyStats()
 	for _, i := range ac.Imports {
 		acc, err := s.lookupAccount(i.Account)
 		if acc == nil || err != nil {
@@ -1704,7 +2346,15 @@ func (s *Server) updateAccountClaims(a *Account, ac *jwt.AccountClaims) {
 		switch i.Type {
 		case jwt.Stream:
 			s.Debugf("Adding stream import %s:%q for %s:%q", acc.Name, i.Subject, a.Name, i.To)
-			if err := a.AddStreamImportWithClaim(acc, string(i.Subject), string(i.To), i); err != nil {
+			var err error
+			// A destination with references to the subject's wildcards is a
+			// template, otherwise it is a prefix.
+			if to := string(i.To); hasSubjectReferences(to) {
+				err = a.AddMappedStreamImportWithClaim(acc, string(i.Subject), to, i)
+			} else {
+				err = a.AddStreamImportWithClaim(acc, string(i.Subject), to, i)
+			}
+			if err != nil {
 				s.Debugf("Error adding stream import to account [%s]: %v", a.Name, err.Error())
 			}
 		case jwt.Service:
@@ -1714,6 +2364,29 @@ func (s *Server) updateAccountClaims(a *Account, ac *jwt.AccountClaims) {
 			}
 		}
 	}
+	// The imports of the account's advisories have been reset as well.
+	a.addSystemImports(s.SystemAccount())
+	// Requests would loop between the accounts of a cycle of service imports.
+	// Unlike the accounts of the configuration, which fail to load, account
+	// claims are pushed or fetched at any time and can not be rejected, so
+	// the cycle is only reported. It is also shown by the account graph of
+	// Accountz. Only the imports of this account can close a new cycle.
+	a.mu.RLock()
+	hasImports := len(a.imports.services) > 0
+	a.mu.RUnlock()
+	if hasImports {
+		// The account may not be registered yet.
+		accs := []*Account{a}
+		s.accounts.Range(func(k, v interface{}) bool {
+			if acc := v.(*Account); acc.Name != a.Name {
+				accs = append(accs, acc)
+			}
+			return true
+		})
+		if cycle := findServiceImportCycle(serviceImportEdges(accs), a); cycle != nil {
+			s.Warnf("Account %q has a service import cycle: %s", a.Name, importCyclePath(cycle))
+		}
+	}
 	// Now let's apply any needed changes from import/export changes.
 	if !a.checkStreamImportsEqual(old) {
 		awcsti := map[string]struct{}{a.Name: {}}
@@ -1732,7 +2405,7 @@ func (s *Server) updateAccountClaims(a *Account, ac *jwt.AccountClaims) {
 			for _, im := range acc.imports.streams {
 				if im != nil && im.acc.Name == a.Name {
 					// Check for if we are still authorized for an import.
-					im.invalid = !a.checkStreamImportAuthorizedNoLock(im.acc, im.from, im.claim)
+					im.invalid = !im.system && !a.checkStreamImportAuthorizedNoLock(im.acc, im.from, im.claim)
 					awcsti[acc.Name] = struct{}{}
 					for _, c := range acc.clients {
 						clients = append(clients, c)
@@ -1827,59 +2500,107 @@ func (s *Server) updateAccountClaims(a *Account, ac *jwt.AccountClaims) {
 			}
 		}
 	}
+
+	// Check if the roles of the signing keys changed, users signed with
+	// them get the new role. Users whose signing key no longer has a role
+	// are evicted, since we don't have their own permissions anymore.
+	if rolesChanged {
+		for _, c := range clients {
+			c.mu.Lock()
+			if c.user == nil || c.user.SigningKey == "" || c.flags.isSet(closeConnection) {
+				c.mu.Unlock()
+				continue
+			}
+			sk, hadRole := c.user.SigningKey, c.user.role != ""
+			role := roles[sk]
+			if role == nil {
+				c.mu.Unlock()
+				if hadRole {
+					c.closeConnection(AuthenticationViolation)
+				}
+				continue
+			}
+			if !reflect.DeepEqual(role, old.signingRoles[sk]) {
+				nu := c.user.clone()
+				nu.applySigningKeyRole(role)
+				c.user = nu
+				c.perms, c.mperms, c.darray = nil, nil, nil
+				c.setPermissions(nu.Permissions)
+				c.applyUserLimits()
+			}
+			c.mu.Unlock()
+		}
+	}
 }
 
 // Helper to build an internal account structure from a jwt.AccountClaims.
 // Lock MUST NOT be held upon entry.
-func (s *Server) buildInternalAccount(ac *jwt.AccountClaims) *Account {
+func (s *Server) buildInternalAccount(ac *jwt.AccountClaims, claimJWT string) *Account {
 	acc := NewAccount(ac.Subject)
 	acc.Issuer = ac.Issuer
+	acc.claimJWT = claimJWT
 	// We don't want to register an account that is in the process of
 	// being built, however, to solve circular import dependencies, we
 	// need to store it here.
 	s.tmpAccounts.Store(ac.Subject, acc)
-	s.updateAccountClaims(acc, ac)
+	s.updateAccountClaimsWithRoles(acc, ac, s.signingKeyRoles(ac, claimJWT))
 	return acc
 }
 
 // Helper to build internal NKeyUser.
 func buildInternalNkeyUser(uc *jwt.UserClaims, acc *Account) *NkeyUser {
-	nu := &NkeyUser{Nkey: uc.Subject, Account: acc}
+	nu := &NkeyUser{Nkey: uc.Subject, Account: acc, name: uc.Name, tags: uc.Tags}
 	if uc.IssuerAccount != "" {
 		nu.SigningKey = uc.Issuer
+		// Users signed with a scoped signing key get the permissions and
+		// limits of its role, whatever their own are.
+		if role := acc.signingKeyRole(uc.Issuer); role != nil {
+			nu.applySigningKeyRole(role)
+			return nu
+		}
 	}
 
-	// Now check for permissions.
+	nu.Permissions = permissionsFromJWT(&uc.Permissions)
+
+	// Source address restrictions, the Src limit is a list of allowed CIDRs.
+	nu.AllowedCIDRs, nu.DeniedCIDRs = cidrsFromTags(uc.Tags)
+	if uc.Src != "" {
+		nu.AllowedCIDRs = append(nu.AllowedCIDRs, strings.Split(uc.Src, ",")...)
+	}
+	return nu
+}
+
+// Helper to build internal permissions from JWT permissions.
+func permissionsFromJWT(jp *jwt.Permissions) *Permissions {
 	var p *Permissions
 
-	if len(uc.Pub.Allow) > 0 || len(uc.Pub.Deny) > 0 {
+	if len(jp.Pub.Allow) > 0 || len(jp.Pub.Deny) > 0 {
 		if p == nil {
 			p = &Permissions{}
 		}
 		p.Publish = &SubjectPermission{}
-		p.Publish.Allow = uc.Pub.Allow
-		p.Publish.Deny = uc.Pub.Deny
+		p.Publish.Allow = jp.Pub.Allow
+		p.Publish.Deny = jp.Pub.Deny
 	}
-	if len(uc.Sub.Allow) > 0 || len(uc.Sub.Deny) > 0 {
+	if len(jp.Sub.Allow) > 0 || len(jp.Sub.Deny) > 0 {
 		if p == nil {
 			p = &Permissions{}
 		}
 		p.Subscribe = &SubjectPermission{}
-		p.Subscribe.Allow = uc.Sub.Allow
-		p.Subscribe.Deny = uc.Sub.Deny
+		p.Subscribe.Allow = jp.Sub.Allow
+		p.Subscribe.Deny = jp.Sub.Deny
 	}
-	if uc.Resp != nil {
+	if jp.Resp != nil {
 		if p == nil {
 			p = &Permissions{}
 		}
 		p.Response = &ResponsePermission{
-			MaxMsgs: uc.Resp.MaxMsgs,
-			Expires: uc.Resp.Expires,
+			MaxMsgs: jp.Resp.MaxMsgs,
+			Expires: jp.Resp.Expires,
 		}
 		validateResponsePermissions(p)
 	}
-	nu.Permissions = p
-	return nu
+	return p
 }
 
 // AccountResolver interface. This is to fetch Account JWTs by public nkeys
@@ -1912,8 +2633,17 @@ func (m *MemAccResolver) Store(name, jwt string) error {
 type URLAccResolver struct {
 	url string
 	c   *http.Client
+
+	// Result of the last reachability check, see healthCheck.
+	hmu      sync.Mutex
+	hchecked time.Time
+	herr     error
 }
 
+// How long the result of the reachability check of a URL resolver is
+// reused by health checks.
+const urlResolverHealthCheckInterval = 10 * time.Second
+
 // NewURLAccResolver returns a new resolver for the given base URL.
 func NewURLAccResolver(url string) (*URLAccResolver, error) {
 	if !strings.HasSuffix(url, "/") {
@@ -1957,6 +2687,19 @@ func (ur *URLAccResolver) Fetch(name string) (string, error) {
 	return string(body), nil
 }
 
+// healthCheck returns the result of the basic check done when the resolver
+// is created. The result is reused for some time so that frequent health
+// probes don't load the resolver.
+func (ur *URLAccResolver) healthCheck() error {
+	ur.hmu.Lock()
+	defer ur.hmu.Unlock()
+	if time.Since(ur.hchecked) >= urlResolverHealthCheckInterval {
+		_, ur.herr = ur.Fetch("")
+		ur.hchecked = time.Now()
+	}
+	return ur.herr
+}
+
 // Store is not implemented for URL Resolver.
 func (ur *URLAccResolver) Store(name, jwt string) error {
 	return fmt.Errorf("Store operation not supported for URL Resolver")
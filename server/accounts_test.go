@@ -2124,3 +2124,408 @@ func BenchmarkNewRouteReply(b *testing.B) {
 		c.newServiceReply(false)
 	}
 }
+
+func TestAccountTrackLatencyOutcomes(t *testing.T) {
+	conf := createConfFile(t, []byte(`
+		listen: "127.0.0.1:-1"
+		system_account: SYS
+		accounts {
+			SYS {}
+			A {
+				users [
+					{user: a, password: pwd}
+					{user: denied, password: pwd, permissions: {publish: {deny: "_R_.>"}}}
+				]
+				exports [{service: "req", latency: {sampling: 100, subject: "lat"}}]
+			}
+			B {
+				users [{user: b, password: pwd}]
+				imports [{service: {account: A, subject: "req"}}]
+			}
+		}
+	`))
+	defer os.Remove(conf)
+	s, opts := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	url := func(user string) string {
+		return fmt.Sprintf("nats://%s:pwd@%s:%d", user, opts.Host, opts.Port)
+	}
+	nca := natsConnect(t, url("a"))
+	defer nca.Close()
+	lat := natsSubSync(t, nca, "lat")
+	natsFlush(t, nca)
+	ncb := natsConnect(t, url("b"))
+	defer ncb.Close()
+
+	checkStatus := func(status string) *ServiceLatency {
+		t.Helper()
+		sl := &ServiceLatency{}
+		if err := json.Unmarshal(natsNexMsg(t, lat, 2*time.Second).Data, sl); err != nil {
+			t.Fatalf("Error unmarshalling: %v", err)
+		}
+		if sl.Status != status || sl.RequestStart.IsZero() {
+			t.Fatalf("Expected status %q, got %+v", status, sl)
+		}
+		return sl
+	}
+
+	// No responders.
+	if _, err := ncb.Request("req", []byte("help"), 100*time.Millisecond); err == nil {
+		t.Fatal("Expected request to time out")
+	}
+	checkStatus(LatencyStatusNoResponders)
+
+	// The responder is not allowed to publish the response.
+	ncd := natsConnect(t, url("denied"))
+	sub := natsSub(t, ncd, "req", func(m *nats.Msg) { m.Respond([]byte("ok")) })
+	natsFlush(t, ncd)
+	if _, err := ncb.Request("req", []byte("help"), 100*time.Millisecond); err == nil {
+		t.Fatal("Expected request to time out")
+	}
+	checkStatus(LatencyStatusPermissionDenied)
+	sub.Unsubscribe()
+	natsFlush(t, ncd)
+	ncd.Close()
+
+	// The responder does not respond before the response map expires.
+	accA, _ := s.LookupAccount("A")
+	accA.SetAutoExpireTTL(50 * time.Millisecond)
+	natsSubSync(t, nca, "req")
+	natsFlush(t, nca)
+	if _, err := ncb.Request("req", []byte("help"), 100*time.Millisecond); err == nil {
+		t.Fatal("Expected request to time out")
+	}
+	if sl := checkStatus(LatencyStatusTimeout); sl.TotalLatency < 50*time.Millisecond {
+		t.Fatalf("Unexpected latency: %+v", sl)
+	}
+	if n := accA.numServiceRoutes(); n != 0 {
+		t.Fatalf("Expected response maps to be removed, got %d", n)
+	}
+
+	// Requests that got a response are reported as ok.
+	nca.Subscribe("req", func(m *nats.Msg) { m.Respond([]byte("ok")) })
+	natsFlush(t, nca)
+	if _, err := ncb.Request("req", []byte("help"), time.Second); err != nil {
+		t.Fatalf("Error on request: %v", err)
+	}
+	checkStatus(LatencyStatusOK)
+	if msg, err := lat.NextMsg(100 * time.Millisecond); err == nil {
+		t.Fatalf("Unexpected latency: %q", msg.Data)
+	}
+
+	az, err := s.Accountz(&AccountzOptions{Account: "A"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	st := az.Accounts[0].Exports[0].Latency.Stats
+	if st.TotalLatency.Count != 1 || st.Statuses[LatencyStatusOK] != 1 || st.Statuses[LatencyStatusTimeout] != 1 ||
+		st.Statuses[LatencyStatusNoResponders] != 1 || st.Statuses[LatencyStatusPermissionDenied] != 1 {
+		t.Fatalf("Unexpected stats: %+v", st)
+	}
+}
+
+func TestAccountMappedStreamImport(t *testing.T) {
+	s, fooAcc, barAcc := simpleAccountServer(t)
+	defer s.Shutdown()
+
+	cfoo, _, _ := newClientForServer(s)
+	defer cfoo.nc.Close()
+
+	if err := cfoo.registerWithAccount(fooAcc); err != nil {
+		t.Fatalf("Error registering client with 'foo' account: %v", err)
+	}
+	cbar, crBar, _ := newClientForServer(s)
+	defer cbar.nc.Close()
+
+	if err := cbar.registerWithAccount(barAcc); err != nil {
+		t.Fatalf("Error registering client with 'bar' account: %v", err)
+	}
+
+	if err := fooAcc.AddStreamExport("events.>", []*Account{barAcc}); err != nil {
+		t.Fatalf("Error adding stream export to client foo: %v", err)
+	}
+	if err := barAcc.AddMappedStreamImport(fooAcc, "events.*.created", "partner.$2.new"); err != ErrBadSubjectTransform {
+		t.Fatalf("Expected ErrBadSubjectTransform but received %v", err)
+	}
+	if err := barAcc.AddMappedStreamImport(fooAcc, "events.*.created", "partner.$1.new"); err != nil {
+		t.Fatalf("Error adding stream import to client bar: %v", err)
+	}
+
+	checkMsg := func(subject, sid string) {
+		t.Helper()
+		l, err := crBar.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Error reading from client 'bar': %v", err)
+		}
+		mraw := msgPat.FindAllStringSubmatch(l, -1)
+		if len(mraw) == 0 {
+			t.Fatalf("No message received")
+		}
+		matches := mraw[0]
+		if matches[SUB_INDEX] != subject {
+			t.Fatalf("Did not get correct subject: '%s'", matches[SUB_INDEX])
+		}
+		if matches[SID_INDEX] != sid {
+			t.Fatalf("Did not get correct sid: '%s'", matches[SID_INDEX])
+		}
+		checkPayload(crBar, []byte("hello\r\n"), t)
+	}
+
+	go cbar.parse([]byte("SUB partner.*.new 1\r\nSUB partner.33.new 2\r\nSUB events.> 3\r\nPING\r\n"))
+	if _, err := crBar.ReadString('\n'); err != nil {
+		t.Fatalf("Error for client 'bar' from server: %v", err)
+	}
+	// The literal subscription is mapped back to the exporter's subject.
+	if r := fooAcc.sl.Match("events.33.created"); len(r.psubs) != 2 {
+		t.Fatalf("Expected 2 shadow subscriptions, got %d", len(r.psubs))
+	}
+
+	go cfoo.parseAndFlush([]byte("PUB events.22.created 5\r\nhello\r\nPUB events.22.updated 5\r\nhello\r\nPUB events.33.created 5\r\nhello\r\n"))
+
+	checkMsg("partner.22.new", "1")
+	l, err := crBar.ReadString('\n')
+	if err != nil {
+		t.Fatalf("Error reading from client 'bar': %v", err)
+	}
+	// Both subscriptions get the last message, in any order.
+	sids := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		if i > 0 {
+			if l, err = crBar.ReadString('\n'); err != nil {
+				t.Fatalf("Error reading from client 'bar': %v", err)
+			}
+		}
+		mraw := msgPat.FindAllStringSubmatch(l, -1)
+		if len(mraw) == 0 {
+			t.Fatalf("No message received")
+		}
+		if subj := mraw[0][SUB_INDEX]; subj != "partner.33.new" {
+			t.Fatalf("Did not get correct subject: '%s'", subj)
+		}
+		sids[mraw[0][SID_INDEX]] = true
+		checkPayload(crBar, []byte("hello\r\n"), t)
+	}
+	if !sids["1"] || !sids["2"] {
+		t.Fatalf("Expected message on sids 1 and 2, got %v", sids)
+	}
+}
+
+func TestAccountMappedServiceImport(t *testing.T) {
+	s, fooAcc, barAcc := simpleAccountServer(t)
+	defer s.Shutdown()
+
+	cfoo, crFoo, _ := newClientForServer(s)
+	defer cfoo.nc.Close()
+
+	if err := cfoo.registerWithAccount(fooAcc); err != nil {
+		t.Fatalf("Error registering client with 'foo' account: %v", err)
+	}
+	cbar, _, _ := newClientForServer(s)
+	defer cbar.nc.Close()
+
+	if err := cbar.registerWithAccount(barAcc); err != nil {
+		t.Fatalf("Error registering client with 'bar' account: %v", err)
+	}
+
+	if err := fooAcc.AddServiceExport("requests.>", nil); err != nil {
+		t.Fatalf("Error adding account service export to client foo: %v", err)
+	}
+	// Every wildcard needs to be referenced to map requests back.
+	if err := barAcc.AddServiceImport(fooAcc, "partner.request", "requests.*"); err != ErrBadSubjectTransform {
+		t.Fatalf("Expected ErrBadSubjectTransform but received %v", err)
+	}
+	if err := barAcc.AddServiceImport(fooAcc, "partner.$2.$1.request", "requests.*.*"); err != nil {
+		t.Fatalf("Error adding account service import to client bar: %v", err)
+	}
+
+	cfoo.parse([]byte("SUB requests.> 1\r\n"))
+	go cbar.parseAndFlush([]byte("SUB bar 11\r\nPUB partner.b.a.request bar 4\r\nhelp\r\n"))
+
+	l, err := crFoo.ReadString('\n')
+	if err != nil {
+		t.Fatalf("Error reading from client 'foo': %v", err)
+	}
+	mraw := msgPat.FindAllStringSubmatch(l, -1)
+	if len(mraw) == 0 {
+		t.Fatalf("No message received")
+	}
+	if subj := mraw[0][SUB_INDEX]; subj != "requests.a.b" {
+		t.Fatalf("Did not get correct subject: '%s'", subj)
+	}
+	checkPayload(crFoo, []byte("help\r\n"), t)
+
+	// With overlapping patterns, the first import added is used.
+	if err := barAcc.AddServiceImport(fooAcc, "partner.$1.b.request", "requests.*.b"); err != nil {
+		t.Fatalf("Error adding account service import to client bar: %v", err)
+	}
+	go cbar.parseAndFlush([]byte("PUB partner.x.b.request bar 4\r\nhelp\r\n"))
+	l, err = crFoo.ReadString('\n')
+	if err != nil {
+		t.Fatalf("Error reading from client 'foo': %v", err)
+	}
+	mraw = msgPat.FindAllStringSubmatch(l, -1)
+	if len(mraw) == 0 {
+		t.Fatalf("No message received")
+	}
+	if subj := mraw[0][SUB_INDEX]; subj != "requests.b.x" {
+		t.Fatalf("Did not get correct subject: '%s'", subj)
+	}
+	checkPayload(crFoo, []byte("help\r\n"), t)
+
+	// Removing the imports by their pattern clears the transforms.
+	barAcc.removeServiceImport("partner.*.*.request")
+	barAcc.removeServiceImport("partner.*.b.request")
+	barAcc.mu.RLock()
+	ntr := len(barAcc.imports.trs)
+	barAcc.mu.RUnlock()
+	if ntr != 0 {
+		t.Fatalf("Expected no service import transform, got %d", ntr)
+	}
+}
+
+func TestAccountMappedImportsConfig(t *testing.T) {
+	cf := createConfFile(t, []byte(`
+    accounts {
+      A {
+        exports = [{stream: "events.>"}, {service: "requests.>"}]
+      }
+      B {
+        imports = [
+          {stream: {account: A, subject: "events.*.created"}, to: "partner.$1.new"}
+          {service: {account: A, subject: "requests.*"}, to: "partner.$1.request"}
+        ]
+      }
+    }
+    `))
+	defer os.Remove(cf)
+	opts, err := ProcessConfigFile(cf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var b *Account
+	for _, acc := range opts.Accounts {
+		if acc.Name == "B" {
+			b = acc
+		}
+	}
+	if b == nil {
+		t.Fatalf("Expected account B")
+	}
+	if si := b.imports.streams["events.*.created"]; si == nil || si.to != "partner.$1.new" || si.tr == nil {
+		t.Fatalf("Expected a mapped stream import, got %+v", si)
+	}
+	if si := b.imports.services["partner.*.request"]; si == nil || si.to != "requests.*" || si.tr == nil {
+		t.Fatalf("Expected a mapped service import, got %+v", si)
+	}
+
+	cf = createConfFile(t, []byte(`
+    accounts {
+      A {
+        exports = [{stream: "events.>"}]
+      }
+      B {
+        imports = [{stream: {account: A, subject: "events.*.created"}, prefix: "partner", to: "partner.$1.new"}]
+      }
+    }
+    `))
+	defer os.Remove(cf)
+	if _, err := ProcessConfigFile(cf); err == nil {
+		t.Fatalf("Expected an error with both a prefix and a destination")
+	}
+}
+
+func TestServiceImportCycleGraph(t *testing.T) {
+	a, b, c, d := NewAccount("A"), NewAccount("B"), NewAccount("C"), NewAccount("D")
+	edge := func(acc *Account, from string, to *Account, subject string) importEdge {
+		return importEdge{acc, &serviceImport{acc: to, from: from, to: subject}}
+	}
+	// A -> B -> C -> A is a cycle, D only leads into it.
+	edges := []importEdge{
+		edge(a, "a.req", b, "b.req"),
+		edge(a, "a.other", d, "d.req"),
+		edge(b, "b.req", c, "c.*"),
+		edge(c, "c.req", a, "a.req"),
+		edge(d, "d.req", a, "a.req"),
+	}
+	if cycle := findServiceImportCycle(edges, nil); cycle == nil ||
+		importCyclePath(cycle) != `A "a.req" -> B "b.req" -> C "c.*" -> A "a.req"` {
+		t.Fatalf("Unexpected cycle: %v", cycle)
+	}
+	if cycle := findServiceImportCycle(edges, c); cycle == nil ||
+		importCyclePath(cycle) != `C "c.req" -> A "a.req" -> B "b.req" -> C "c.*"` {
+		t.Fatalf("Unexpected cycle: %v", cycle)
+	}
+	if cycle := findServiceImportCycle(edges, d); cycle != nil {
+		t.Fatalf("Unexpected cycle through D: %v", importCyclePath(cycle))
+	}
+	// Breaking the cycle.
+	edges[2] = edge(b, "b.req", c, "c.x.y")
+	if cycle := findServiceImportCycle(edges, nil); cycle != nil {
+		t.Fatalf("Unexpected cycle: %v", importCyclePath(cycle))
+	}
+	// An import mapping requests back to itself.
+	edges = append(edges, edge(d, "d.>", d, "d.loop"))
+	if cycle := findServiceImportCycle(edges, nil); cycle == nil ||
+		importCyclePath(cycle) != `D "d.>" -> D "d.loop"` {
+		t.Fatalf("Unexpected cycle: %v", cycle)
+	}
+}
+
+func TestAccountServiceImportCycle(t *testing.T) {
+	// Requests on "a.svc" in A are sent to B, which sends them back.
+	cf := createConfFile(t, []byte(`
+    accounts {
+      A {
+        exports = [{service: "a.svc"}]
+        imports = [{service: {account: B, subject: "b.svc"}, to: "a.svc"}]
+      }
+      B {
+        exports = [{service: "b.svc"}]
+        imports = [{service: {account: A, subject: "a.svc"}, to: "b.svc"}]
+      }
+    }
+    `))
+	defer os.Remove(cf)
+	_, err := ProcessConfigFile(cf)
+	if err == nil {
+		t.Fatalf("Expected an error with a service import cycle")
+	}
+	if expected := `A "a.svc" -> B "b.svc" -> A "a.svc"`; !strings.Contains(err.Error(), expected) {
+		t.Fatalf("Expected error to contain %q, got %v", expected, err)
+	}
+
+	// Importing from each other is fine as long as requests do not loop.
+	cf = createConfFile(t, []byte(`
+    accounts {
+      A {
+        exports = [{service: "a.svc"}]
+        imports = [{service: {account: B, subject: "b.svc"}, to: "b.req"}]
+      }
+      B {
+        exports = [{service: "b.svc"}]
+        imports = [{service: {account: A, subject: "a.svc"}, to: "a.req"}]
+      }
+    }
+    `))
+	defer os.Remove(cf)
+	if _, err := ProcessConfigFile(cf); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Accounts set programmatically are validated by the server.
+	a, b := NewAccount("A"), NewAccount("B")
+	a.AddServiceExport("a.>", nil)
+	b.AddServiceExport("b.*", nil)
+	if err := a.AddServiceImport(b, "a.svc", "b.svc"); err != nil {
+		t.Fatalf("Error adding service import: %v", err)
+	}
+	if err := b.AddServiceImport(a, "b.$1", "a.*"); err != nil {
+		t.Fatalf("Error adding service import: %v", err)
+	}
+	opts := DefaultOptions()
+	opts.Accounts = []*Account{a, b}
+	if _, err := NewServer(opts); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("Expected an error with a service import cycle, got %v", err)
+	}
+}
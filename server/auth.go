@@ -15,7 +15,9 @@ package server
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
+	"fmt"
 	"net"
 	"strings"
 	"time"
@@ -45,18 +47,34 @@ type ClientAuthentication interface {
 
 // NkeyUser is for multiple nkey based users
 type NkeyUser struct {
-	Nkey        string       `json:"user"`
-	Permissions *Permissions `json:"permissions,omitempty"`
-	Account     *Account     `json:"account,omitempty"`
-	SigningKey  string       `json:"signing_key,omitempty"`
+	Nkey         string       `json:"user"`
+	Permissions  *Permissions `json:"permissions,omitempty"`
+	Account      *Account     `json:"account,omitempty"`
+	SigningKey   string       `json:"signing_key,omitempty"`
+	AllowedCIDRs []string     `json:"allowed_cidrs,omitempty"`
+	DeniedCIDRs  []string     `json:"denied_cidrs,omitempty"`
+	// Name and tags of the user JWT, used by permission templates.
+	name string
+	tags jwt.TagList
+	// Role of the signing key the user JWT was signed with, and the
+	// limits that come with it.
+	role  string
+	msubs int32
+	mpay  int32
+	// Parsed source address restrictions.
+	srcFilter *sourceFilter
 }
 
 // User is for multiple accounts/users.
 type User struct {
-	Username    string       `json:"user"`
-	Password    string       `json:"password"`
-	Permissions *Permissions `json:"permissions,omitempty"`
-	Account     *Account     `json:"account,omitempty"`
+	Username     string       `json:"user"`
+	Password     string       `json:"password"`
+	Permissions  *Permissions `json:"permissions,omitempty"`
+	Account      *Account     `json:"account,omitempty"`
+	AllowedCIDRs []string     `json:"allowed_cidrs,omitempty"`
+	DeniedCIDRs  []string     `json:"denied_cidrs,omitempty"`
+	// Parsed source address restrictions.
+	srcFilter *sourceFilter
 }
 
 // clone performs a deep copy of the User struct, returning a new clone with
@@ -68,6 +86,8 @@ func (u *User) clone() *User {
 	clone := &User{}
 	*clone = *u
 	clone.Permissions = u.Permissions.clone()
+	clone.AllowedCIDRs = copyStrings(u.AllowedCIDRs)
+	clone.DeniedCIDRs = copyStrings(u.DeniedCIDRs)
 	return clone
 }
 
@@ -80,6 +100,9 @@ func (n *NkeyUser) clone() *NkeyUser {
 	clone := &NkeyUser{}
 	*clone = *n
 	clone.Permissions = n.Permissions.clone()
+	clone.AllowedCIDRs = copyStrings(n.AllowedCIDRs)
+	clone.DeniedCIDRs = copyStrings(n.DeniedCIDRs)
+	clone.tags = copyStrings(n.tags)
 	return clone
 }
 
@@ -227,6 +250,7 @@ func (s *Server) configureAuthorization() {
 
 	// Snapshot server options.
 	opts := s.getOpts()
+	var err error
 
 	// Check for multiple users first
 	// This just checks and sets up the user map if we have multiple users.
@@ -248,6 +272,10 @@ func (s *Server) configureAuthorization() {
 				if copy.Permissions != nil {
 					validateResponsePermissions(copy.Permissions)
 				}
+				if copy.srcFilter, err = newSourceFilter(u.AllowedCIDRs, u.DeniedCIDRs); err != nil {
+					s.Errorf("User %q has invalid source address restrictions: %v", u.Nkey, err)
+					copy.srcFilter = denyAllSourceFilter
+				}
 				s.nkeys[u.Nkey] = copy
 			}
 		}
@@ -263,6 +291,10 @@ func (s *Server) configureAuthorization() {
 				if copy.Permissions != nil {
 					validateResponsePermissions(copy.Permissions)
 				}
+				if copy.srcFilter, err = newSourceFilter(u.AllowedCIDRs, u.DeniedCIDRs); err != nil {
+					s.Errorf("User %q has invalid source address restrictions: %v", u.Username, err)
+					copy.srcFilter = denyAllSourceFilter
+				}
 				s.users[u.Username] = copy
 			}
 		}
@@ -363,9 +395,9 @@ func (s *Server) processClientOrLeafAuthentication(c *client) bool {
 		// Check if we are tls verify and are mapping users from the client_certificate
 		if opts.TLSMap {
 			var euser string
-			authorized := checkClientTLSCertSubject(c, func(u string) bool {
+			authorized := checkClientTLSCertSubject(c, opts.TLSMapTypes, func(u string) bool {
 				var ok bool
-				user, ok = s.users[u]
+				user, ok = s.lookupUserByCertIdentity(u)
 				if !ok {
 					c.Debugf("User in cert [%q], not found", u)
 					return false
@@ -416,6 +448,16 @@ func (s *Server) processClientOrLeafAuthentication(c *client) bool {
 			c.Debugf("Account JWT has expired")
 			return false
 		}
+		// Check the source address before the credentials, so that they
+		// can't be tested from a network that is not allowed.
+		nkey = buildInternalNkeyUser(juc, acc)
+		if nkey.srcFilter, err = newSourceFilter(nkey.AllowedCIDRs, nkey.DeniedCIDRs); err != nil {
+			c.Errorf("Unable to check source address: %v", err)
+			nkey.srcFilter = denyAllSourceFilter
+		}
+		if s.checkSourceAddress(c, nkey.srcFilter, acc) != nil {
+			return false
+		}
 		// Verify the signature against the nonce.
 		if c.opts.Sig == "" {
 			c.Debugf("Signature missing")
@@ -444,7 +486,6 @@ func (s *Server) processClientOrLeafAuthentication(c *client) bool {
 			return false
 		}
 
-		nkey = buildInternalNkeyUser(juc, acc)
 		if err := c.RegisterNkeyUser(nkey); err != nil {
 			return false
 		}
@@ -458,6 +499,9 @@ func (s *Server) processClientOrLeafAuthentication(c *client) bool {
 	}
 
 	if nkey != nil {
+		if s.checkSourceAddress(c, nkey.srcFilter, nkey.Account) != nil {
+			return false
+		}
 		if c.opts.Sig == "" {
 			c.Debugf("Signature missing")
 			return false
@@ -487,6 +531,9 @@ func (s *Server) processClientOrLeafAuthentication(c *client) bool {
 	}
 
 	if user != nil {
+		if s.checkSourceAddress(c, user.srcFilter, user.Account) != nil {
+			return false
+		}
 		ok = comparePasswords(user.Password, c.opts.Password)
 		// If we are authorized, register the user which will properly setup any permissions
 		// for pub/sub authorizations.
@@ -518,7 +565,34 @@ func (s *Server) processClientOrLeafAuthentication(c *client) bool {
 	return false
 }
 
-func checkClientTLSCertSubject(c *client, fn func(string) bool) bool {
+// Types of identities found in a client certificate that can be
+// mapped to a user when TLS map is enabled.
+const (
+	tlsMapEmail   = "email"
+	tlsMapDNS     = "dns"
+	tlsMapURI     = "uri"
+	tlsMapIP      = "ip"
+	tlsMapSubject = "subject"
+)
+
+// defaultTLSMapTypes is the order in which the certificate identities
+// are checked when no map types have been configured.
+var defaultTLSMapTypes = []string{tlsMapEmail, tlsMapDNS, tlsMapURI, tlsMapSubject}
+
+// isValidTLSMapType returns true if the given type is a known certificate
+// identity type.
+func isValidTLSMapType(mt string) bool {
+	switch mt {
+	case tlsMapEmail, tlsMapDNS, tlsMapURI, tlsMapIP, tlsMapSubject:
+		return true
+	}
+	return false
+}
+
+// checkClientTLSCertSubject invokes fn with the identities found in the
+// peer certificate, in the order given by mapTypes (or the default order
+// if empty), until fn returns true.
+func checkClientTLSCertSubject(c *client, mapTypes []string, fn func(string) bool) bool {
 	tlsState := c.GetTLSConnectionState()
 	if tlsState == nil {
 		c.Debugf("User required in cert, no TLS connection state")
@@ -533,36 +607,118 @@ func checkClientTLSCertSubject(c *client, fn func(string) bool) bool {
 		c.Debugf("Multiple peer certificates found, selecting first")
 	}
 
-	hasSANs := len(cert.DNSNames) > 0
-	hasEmailAddresses := len(cert.EmailAddresses) > 0
-	hasSubject := len(cert.Subject.String()) > 0
-	if !hasEmailAddresses && !hasSubject {
-		c.Debugf("User required in cert, none found")
-		return false
+	if len(mapTypes) == 0 {
+		mapTypes = defaultTLSMapTypes
 	}
-
-	switch {
-	case hasEmailAddresses:
-		for _, u := range cert.EmailAddresses {
+	found := false
+	for _, mt := range mapTypes {
+		for _, u := range certIdentities(cert, mt) {
+			found = true
 			if fn(u) {
-				c.Debugf("Using email found in cert for auth [%q]", u)
+				c.Debugf("Using %s found in cert for auth [%q]", mt, u)
 				return true
 			}
 		}
-		fallthrough
-	case hasSANs:
-		for _, u := range cert.DNSNames {
-			if fn(u) {
-				c.Debugf("Using SAN found in cert for auth [%q]", u)
-				return true
+	}
+	if !found {
+		c.Debugf("User required in cert, none found")
+	}
+	return false
+}
+
+// certIdentities returns the identities of the given type found in the
+// certificate.
+func certIdentities(cert *x509.Certificate, mt string) []string {
+	var ids []string
+	switch mt {
+	case tlsMapEmail:
+		ids = cert.EmailAddresses
+	case tlsMapDNS:
+		ids = cert.DNSNames
+	case tlsMapURI:
+		for _, u := range cert.URIs {
+			ids = append(ids, u.String())
+		}
+	case tlsMapIP:
+		for _, ip := range cert.IPAddresses {
+			ids = append(ids, ip.String())
+		}
+	case tlsMapSubject:
+		if subject := cert.Subject.String(); subject != _EMPTY_ {
+			ids = append(ids, subject)
+		}
+	}
+	return ids
+}
+
+// isCertIdentityPattern returns true if the given name is a URI
+// containing wildcards in its path.
+func isCertIdentityPattern(name string) bool {
+	return strings.Contains(name, "://") && strings.ContainsAny(name, "*>")
+}
+
+// matchCertIdentity returns true if the identity found in a certificate
+// matches the given name. For URIs, such as SPIFFE IDs, the path of the
+// name can contain wildcards: `*` matches a single path segment, and `>`
+// as the last segment matches one or more segments. The scheme and host
+// must match exactly.
+func matchCertIdentity(name, id string) bool {
+	if name == id {
+		return true
+	}
+	if !isCertIdentityPattern(name) {
+		return false
+	}
+	ni := strings.Index(name, "://")
+	ii := strings.Index(id, "://")
+	if ii < 0 || !strings.EqualFold(name[:ni], id[:ii]) {
+		return false
+	}
+	ntoks := strings.Split(name[ni+3:], "/")
+	itoks := strings.Split(id[ii+3:], "/")
+	// Host can't be a wildcard.
+	if ntoks[0] != itoks[0] || ntoks[0] == "*" || ntoks[0] == ">" {
+		return false
+	}
+	for i := 1; i < len(ntoks); i++ {
+		if i >= len(itoks) {
+			return false
+		}
+		switch ntoks[i] {
+		case ">":
+			return i == len(ntoks)-1 && itoks[i] != _EMPTY_
+		case "*":
+			if itoks[i] == _EMPTY_ {
+				return false
+			}
+		default:
+			if ntoks[i] != itoks[i] {
+				return false
 			}
 		}
 	}
+	return len(ntoks) == len(itoks)
+}
 
-	// Use the subject of the certificate.
-	u := cert.Subject.String()
-	c.Debugf("Using certificate subject for auth [%q]", u)
-	return fn(u)
+// lookupUserByCertIdentity returns the user whose name is the given
+// certificate identity or, if none, the most specific user whose name
+// is a pattern matching the identity.
+// Lock is assumed held.
+func (s *Server) lookupUserByCertIdentity(id string) (*User, bool) {
+	if user, ok := s.users[id]; ok {
+		return user, true
+	}
+	var user *User
+	for name, u := range s.users {
+		if !isCertIdentityPattern(name) || !matchCertIdentity(name, id) {
+			continue
+		}
+		if user == nil || len(name) > len(user.Username) ||
+			(len(name) == len(user.Username) && name < user.Username) {
+			user = u
+		}
+	}
+	return user, user != nil
 }
 
 // checkRouterAuth checks optional router authorization which can be nil or username/password.
@@ -581,8 +737,8 @@ func (s *Server) isRouterAuthorized(c *client) bool {
 	}
 
 	if opts.Cluster.TLSMap {
-		return checkClientTLSCertSubject(c, func(user string) bool {
-			return opts.Cluster.Username == user
+		return checkClientTLSCertSubject(c, opts.Cluster.TLSMapTypes, func(user string) bool {
+			return matchCertIdentity(opts.Cluster.Username, user)
 		})
 	}
 
@@ -605,8 +761,8 @@ func (s *Server) isGatewayAuthorized(c *client) bool {
 
 	// Check whether TLS map is enabled, otherwise use single user/pass.
 	if opts.Gateway.TLSMap {
-		return checkClientTLSCertSubject(c, func(user string) bool {
-			return opts.Gateway.Username == user
+		return checkClientTLSCertSubject(c, opts.Gateway.TLSMapTypes, func(user string) bool {
+			return matchCertIdentity(opts.Gateway.Username, user)
 		})
 	}
 
@@ -647,6 +803,40 @@ func (s *Server) isLeafNodeAuthorized(c *client) bool {
 		return s.registerLeafWithAccount(c, account)
 	}
 
+	// If leafnodes config has an authorization{} stanza and TLS map is
+	// enabled, the identity in the certificate has to match the user.
+	if opts.LeafNode.TLSMap && (opts.LeafNode.Username != _EMPTY_ || len(opts.LeafNode.Users) > 0) {
+		var account string
+		authorized := checkClientTLSCertSubject(c, opts.LeafNode.TLSMapTypes, func(u string) bool {
+			if opts.LeafNode.Username != _EMPTY_ {
+				if !matchCertIdentity(opts.LeafNode.Username, u) {
+					return false
+				}
+				account = opts.LeafNode.Account
+			} else {
+				var user *User
+				for _, lu := range opts.LeafNode.Users {
+					if matchCertIdentity(lu.Username, u) {
+						user = lu
+						break
+					}
+				}
+				if user == nil {
+					return false
+				}
+				if user.Account != nil {
+					account = user.Account.Name
+				}
+			}
+			c.opts.Username = u
+			return true
+		})
+		if !authorized {
+			return false
+		}
+		return s.registerLeafWithAccount(c, account)
+	}
+
 	// If leafnodes config has an authorization{} stanza, this takes precedence.
 	// The user in CONNECT mutch match. We will bind to the account associated
 	// with that user (from the leafnode's authorization{} config).
@@ -689,3 +879,131 @@ func comparePasswords(serverPassword, clientPassword string) bool {
 	}
 	return true
 }
+
+// Prefixes of the JWT tags used to carry allowed and denied CIDRs,
+// since account claims do not have dedicated fields for those.
+const (
+	jwtTagAllowedCIDR = "allowed_cidr:"
+	jwtTagDeniedCIDR  = "denied_cidr:"
+)
+
+// sourceFilter restricts the source addresses a connection can come from.
+// A source address matching a denied network is always rejected. If there
+// are allowed networks, the source address must match one of them.
+type sourceFilter struct {
+	allowed []*net.IPNet
+	denied  []*net.IPNet
+}
+
+// denyAllSourceFilter lets no connection through. It is used in place of
+// restrictions that can't be parsed.
+var denyAllSourceFilter, _ = newSourceFilter(nil, []string{"0.0.0.0/0", "::/0"})
+
+// newSourceFilter returns a filter for the given lists, or nil if both
+// lists are empty. Bare IP addresses are accepted as single host networks.
+func newSourceFilter(allowed, denied []string) (*sourceFilter, error) {
+	if len(allowed) == 0 && len(denied) == 0 {
+		return nil, nil
+	}
+	f := &sourceFilter{}
+	var err error
+	if f.allowed, err = parseCIDRs(allowed); err != nil {
+		return nil, err
+	}
+	if f.denied, err = parseCIDRs(denied); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid CIDR %q", cidr)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", cidr)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// allows returns true if the given host is accepted by the filter.
+// A nil filter accepts everything.
+func (f *sourceFilter) allows(host string) bool {
+	if f == nil {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range f.denied {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	if len(f.allowed) == 0 {
+		return true
+	}
+	for _, n := range f.allowed {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// cidrsFromTags extracts the allowed and denied CIDRs from JWT tags.
+func cidrsFromTags(tags jwt.TagList) (allowed, denied []string) {
+	for _, t := range tags {
+		if strings.HasPrefix(t, jwtTagAllowedCIDR) {
+			allowed = append(allowed, strings.TrimPrefix(t, jwtTagAllowedCIDR))
+		} else if strings.HasPrefix(t, jwtTagDeniedCIDR) {
+			denied = append(denied, strings.TrimPrefix(t, jwtTagDeniedCIDR))
+		}
+	}
+	return allowed, denied
+}
+
+// checkSourceAddress checks the source address of the connection against
+// the restrictions of the user and of the account it binds to. If the address
+// is not allowed, the connection is closed and ErrSourceAddressNotAllowed is
+// returned.
+func (s *Server) checkSourceAddress(c *client, f *sourceFilter, acc *Account) error {
+	// Host is set on client creation and never changes.
+	host := c.host
+	ok := f.allows(host)
+	if ok && acc != nil {
+		acc.mu.RLock()
+		ok = acc.srcFilter.allows(host)
+		acc.mu.RUnlock()
+	}
+	if ok {
+		return nil
+	}
+	c.sourceAddressNotAllowed()
+	return ErrSourceAddressNotAllowed
+}
+
+func copyStrings(src []string) []string {
+	if src == nil {
+		return nil
+	}
+	dst := make([]string, len(src))
+	copy(dst, src)
+	return dst
+}
@@ -14,8 +14,13 @@
 package server
 
 import (
+	"fmt"
+	"os"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/nats-io/nats.go"
 )
 
 func TestUserCloneNilPermissions(t *testing.T) {
@@ -101,3 +106,195 @@ func TestUserCloneNil(t *testing.T) {
 		t.Fatalf("Expected nil, got: %+v", clone)
 	}
 }
+
+func TestMatchCertIdentity(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		id       string
+		expected bool
+	}{
+		{"app.nats.dev", "app.nats.dev", true},
+		{"app.nats.dev", "app.nats.prod", false},
+		{"*", "app.nats.dev", false},
+		{"spiffe://example.org/ns/prod/sa/billing", "spiffe://example.org/ns/prod/sa/billing", true},
+		{"spiffe://example.org/ns/*/sa/billing", "spiffe://example.org/ns/prod/sa/billing", true},
+		{"spiffe://example.org/ns/*/sa/billing", "spiffe://example.org/ns/prod/sa/payroll", false},
+		{"spiffe://example.org/ns/*/sa/billing", "spiffe://example.org/ns/prod/sa/billing/extra", false},
+		{"spiffe://example.org/ns/*", "spiffe://example.org/ns/", false},
+		{"spiffe://example.org/ns/>", "spiffe://example.org/ns/prod/sa/billing", true},
+		{"spiffe://example.org/ns/>", "spiffe://example.org/ns", false},
+		{"spiffe://example.org/>", "spiffe://other.org/ns/prod", false},
+		{"spiffe://*/ns/prod", "spiffe://example.org/ns/prod", false},
+		{"SPIFFE://example.org/*", "spiffe://example.org/ns", true},
+		{"spiffe://example.org/*", "https://example.org/ns", false},
+	} {
+		t.Run("", func(t *testing.T) {
+			if res := matchCertIdentity(test.name, test.id); res != test.expected {
+				t.Fatalf("Expected %q matching %q to be %v, got %v", test.name, test.id, test.expected, res)
+			}
+		})
+	}
+}
+
+func TestLookupUserByCertIdentity(t *testing.T) {
+	s := &Server{users: map[string]*User{
+		"spiffe://example.org/ns/prod/sa/billing": {Username: "spiffe://example.org/ns/prod/sa/billing"},
+		"spiffe://example.org/ns/*/sa/billing":    {Username: "spiffe://example.org/ns/*/sa/billing"},
+		"spiffe://example.org/>":                  {Username: "spiffe://example.org/>"},
+	}}
+	for _, test := range []struct {
+		id       string
+		expected string
+	}{
+		{"spiffe://example.org/ns/prod/sa/billing", "spiffe://example.org/ns/prod/sa/billing"},
+		{"spiffe://example.org/ns/dev/sa/billing", "spiffe://example.org/ns/*/sa/billing"},
+		{"spiffe://example.org/ns/dev/sa/payroll", "spiffe://example.org/>"},
+		{"spiffe://other.org/ns/dev/sa/billing", ""},
+	} {
+		user, ok := s.lookupUserByCertIdentity(test.id)
+		if test.expected == "" {
+			if ok {
+				t.Fatalf("Expected no user for %q, got %q", test.id, user.Username)
+			}
+			continue
+		}
+		if !ok || user.Username != test.expected {
+			t.Fatalf("Expected user %q for %q, got %+v", test.expected, test.id, user)
+		}
+	}
+}
+
+func TestSourceFilter(t *testing.T) {
+	f, err := newSourceFilter([]string{"10.0.0.0/8", "192.168.1.1", "2001:db8::/32"}, []string{"10.1.0.0/16"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, test := range []struct {
+		host    string
+		allowed bool
+	}{
+		{"10.2.3.4", true},
+		{"10.1.2.3", false},
+		{"192.168.1.1", true},
+		{"192.168.1.2", false},
+		{"2001:db8::1", true},
+		{"2001:db9::1", false},
+		{"127.0.0.1", false},
+		{"", false},
+	} {
+		if ok := f.allows(test.host); ok != test.allowed {
+			t.Fatalf("Expected allows(%q) to be %v, got %v", test.host, test.allowed, ok)
+		}
+	}
+
+	// Only denied networks.
+	f, _ = newSourceFilter(nil, []string{"127.0.0.0/8"})
+	if f.allows("127.0.0.1") || !f.allows("10.0.0.1") {
+		t.Fatal("Unexpected result with only denied networks")
+	}
+
+	// No lists means no filter, which allows everything.
+	if f, _ = newSourceFilter(nil, nil); f != nil || !f.allows("127.0.0.1") {
+		t.Fatal("Expected no filter")
+	}
+
+	if _, err := newSourceFilter([]string{"10.0.0.0/33"}, nil); err == nil {
+		t.Fatal("Expected error for invalid CIDR")
+	}
+	if _, err := newSourceFilter(nil, []string{"localhost"}); err == nil {
+		t.Fatal("Expected error for invalid address")
+	}
+}
+
+func TestUserAndAccountSourceCIDRs(t *testing.T) {
+	conf := createConfFile(t, []byte(`
+		listen: "127.0.0.1:-1"
+		accounts {
+			A {
+				users [
+					{user: vpn, password: pwd, allowed_cidrs: ["10.0.0.0/8", "172.16.0.0/12"]}
+					{user: local, password: pwd, allowed_cidrs: "127.0.0.0/8"}
+					{user: banned, password: pwd, denied_cidrs: ["127.0.0.1"]}
+				]
+			}
+			B {
+				denied_cidrs: "127.0.0.0/8"
+				users [
+					{user: other, password: pwd}
+				]
+			}
+		}
+	`))
+	defer os.Remove(conf)
+	s, opts := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	// Connections from a denied address get the same error whether
+	// their password is right or not.
+	for _, test := range []struct {
+		name     string
+		user     string
+		password string
+		err      string
+	}{
+		{"vpn", "vpn", "pwd", "source address not allowed"},
+		{"local", "local", "pwd", ""},
+		{"local wrong password", "local", "bad", "authorization violation"},
+		{"banned", "banned", "pwd", "source address not allowed"},
+		{"banned wrong password", "banned", "bad", "source address not allowed"},
+		{"other", "other", "pwd", "source address not allowed"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			url := fmt.Sprintf("nats://%s:%s@%s:%d", test.user, test.password, opts.Host, opts.Port)
+			nc, err := nats.Connect(url)
+			if test.err == "" {
+				if err != nil {
+					t.Fatalf("Error on connect: %v", err)
+				}
+				nc.Close()
+				return
+			}
+			if err == nil {
+				nc.Close()
+				t.Fatal("Expected connection to fail")
+			}
+			if !strings.Contains(strings.ToLower(err.Error()), test.err) {
+				t.Fatalf("Expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+
+	v, _ := s.Varz(nil)
+	if v.SourceRejects != 4 {
+		t.Fatalf("Expected 4 rejections, got %v", v.SourceRejects)
+	}
+	c, _ := s.Connz(&ConnzOptions{State: ConnClosed})
+	rejected := 0
+	for _, ci := range c.Conns {
+		if ci.Reason == SourceAddressNotAllowed.String() {
+			rejected++
+		}
+	}
+	if rejected != 4 {
+		t.Fatalf("Expected 4 closed connections rejected by source address, got %+v", c.Conns)
+	}
+}
+
+func TestSourceCIDRsConfigErrors(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		conf string
+	}{
+		{"user", `users [{user: a, password: pwd, allowed_cidrs: ["10.0.0.0/33"]}]`},
+		{"account", `accounts { A { denied_cidrs: "not an address" } }`},
+		{"type", `users [{user: a, password: pwd, denied_cidrs: 10}]`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			conf := createConfFile(t, []byte(test.conf))
+			defer os.Remove(conf)
+			if _, err := ProcessConfigFile(conf); err == nil {
+				t.Fatal("Expected error")
+			}
+		})
+	}
+}
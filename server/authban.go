@@ -0,0 +1,259 @@
+// Copyright 2019 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Type of the keys authentication failures are tracked for.
+const (
+	authBanIP   = "ip"
+	authBanUser = "user"
+)
+
+type authFailKey struct {
+	kind string
+	name string
+}
+
+// authFailRecord holds the authentication failures and bans of a
+// source address or a user.
+type authFailRecord struct {
+	// Times of the failures within the window, oldest first.
+	failures []time.Time
+	// Number of bans so far, used to escalate the ban time.
+	bans int
+	// End of the current or last ban.
+	until time.Time
+}
+
+// authFailTracker tracks authentication failures. The zero value is
+// ready to use.
+type authFailTracker struct {
+	sync.Mutex
+	records map[authFailKey]*authFailRecord
+	sweep   time.Time
+}
+
+// authFailureOpts returns the auth failure options with the defaults
+// applied, and whether the protection is enabled at all.
+func authFailureOpts(o *Options) (AuthFailureOpts, bool) {
+	af := o.AuthFailures
+	if af.MaxFailures <= 0 && af.Delay <= 0 {
+		return af, false
+	}
+	if af.Window <= 0 {
+		af.Window = DEFAULT_AUTH_FAILURE_WINDOW
+	}
+	if af.MaxDelay <= 0 {
+		af.MaxDelay = DEFAULT_AUTH_FAILURE_MAX_DELAY
+	}
+	if af.BanTime <= 0 {
+		af.BanTime = DEFAULT_AUTH_BAN_TIME
+	}
+	if af.MaxBanTime <= 0 {
+		af.MaxBanTime = DEFAULT_AUTH_MAX_BAN_TIME
+	}
+	if af.MaxBanTime < af.BanTime {
+		af.MaxBanTime = af.BanTime
+	}
+	return af, true
+}
+
+// Returns the time the given source address or user is banned until,
+// and true if there is such a ban in effect.
+func (s *Server) isAuthBanned(kind, name string) (time.Time, bool) {
+	t := &s.authFails
+	t.Lock()
+	defer t.Unlock()
+	if r := t.records[authFailKey{kind, name}]; r != nil && time.Now().Before(r.until) {
+		return r.until, true
+	}
+	return time.Time{}, false
+}
+
+// Returns the name under which failures for this client's user are
+// tracked, which is empty if the client did not provide one.
+// Lock should be held.
+func (c *client) authFailUserName() string {
+	if c.opts.Username != _EMPTY_ {
+		return c.opts.Username
+	}
+	return c.opts.Nkey
+}
+
+// recordAuthFailure records an authentication failure for the source
+// address and user of the client, banning them if they have reached the
+// maximum number of failures within the window. It returns the delay
+// to apply before reporting the failure to the client.
+func (s *Server) recordAuthFailure(c *client) time.Duration {
+	af, enabled := authFailureOpts(s.getOpts())
+	if !enabled {
+		return 0
+	}
+	c.mu.Lock()
+	keys := make([]authFailKey, 0, 2)
+	if c.host != _EMPTY_ {
+		keys = append(keys, authFailKey{authBanIP, c.host})
+	}
+	if user := c.authFailUserName(); user != _EMPTY_ {
+		keys = append(keys, authFailKey{authBanUser, user})
+	}
+	c.mu.Unlock()
+
+	var (
+		now      = time.Now()
+		failures int
+		events   []*AuthBanEventMsg
+		t        = &s.authFails
+	)
+	t.Lock()
+	if t.records == nil {
+		t.records = make(map[authFailKey]*authFailRecord)
+	}
+	if now.Sub(t.sweep) > af.Window {
+		t.sweep = now
+		t.removeStale(now, af)
+	}
+	for _, k := range keys {
+		r := t.records[k]
+		if r == nil {
+			r = &authFailRecord{}
+			t.records[k] = r
+		}
+		r.failures = append(pruneTimes(r.failures, now, af.Window), now)
+		if n := len(r.failures); n > failures {
+			failures = n
+		}
+		if af.MaxFailures <= 0 || len(r.failures) < af.MaxFailures || now.Before(r.until) {
+			continue
+		}
+		// Start a ban, doubling the time for each previous ban.
+		ban := af.BanTime
+		for i := 0; i < r.bans && ban < af.MaxBanTime; i++ {
+			ban *= 2
+		}
+		if ban > af.MaxBanTime {
+			ban = af.MaxBanTime
+		}
+		r.bans++
+		r.until = now.Add(ban)
+		events = append(events, &AuthBanEventMsg{
+			Type:     k.kind,
+			Name:     k.name,
+			Failures: len(r.failures),
+			Bans:     r.bans,
+			Start:    now,
+			Expires:  r.until,
+		})
+		r.failures = nil
+	}
+	t.Unlock()
+
+	for _, e := range events {
+		s.Warnf("Banning %s %q for %v after %d authentication failures",
+			e.Type, e.Name, e.Expires.Sub(e.Start), e.Failures)
+		s.sendAuthBanEvent(e)
+	}
+
+	if af.Delay <= 0 || failures == 0 {
+		return 0
+	}
+	// Double the delay for each previous failure within the window.
+	delay := af.Delay
+	for i := 1; i < failures && delay < af.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > af.MaxDelay {
+		delay = af.MaxDelay
+	}
+	return delay
+}
+
+// Removes the times, sorted oldest first, that are outside of the window.
+func pruneTimes(times []time.Time, now time.Time, window time.Duration) []time.Time {
+	i := 0
+	for ; i < len(times); i++ {
+		if now.Sub(times[i]) < window {
+			break
+		}
+	}
+	return times[i:]
+}
+
+// removeStale removes the records that have no failure within the window
+// and are not banned. Records of previous bans are kept for the maximum
+// ban time so that a new ban is escalated.
+// Lock should be held.
+func (t *authFailTracker) removeStale(now time.Time, af AuthFailureOpts) {
+	for k, r := range t.records {
+		r.failures = pruneTimes(r.failures, now, af.Window)
+		if len(r.failures) > 0 || now.Before(r.until) {
+			continue
+		}
+		if r.bans > 0 && now.Sub(r.until) < af.MaxBanTime {
+			continue
+		}
+		delete(t.records, k)
+	}
+}
+
+// authBans returns the bans currently in effect, sorted by expiration.
+func (s *Server) authBans() []*BanInfo {
+	t := &s.authFails
+	now := time.Now()
+	var bans []*BanInfo
+	t.Lock()
+	for k, r := range t.records {
+		if !now.Before(r.until) {
+			continue
+		}
+		bans = append(bans, &BanInfo{
+			Type:    k.kind,
+			Name:    k.name,
+			Bans:    r.bans,
+			Expires: r.until,
+		})
+	}
+	t.Unlock()
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Expires.Before(bans[j].Expires) })
+	return bans
+}
+
+// clearAuthBans removes the failures and bans of the given source address
+// and/or user, or of all of them. It returns the number of bans that
+// were lifted.
+func (s *Server) clearAuthBans(ip, user string, all bool) int {
+	t := &s.authFails
+	now := time.Now()
+	cleared := 0
+	t.Lock()
+	for k, r := range t.records {
+		if !all && !(k.kind == authBanIP && k.name == ip) && !(k.kind == authBanUser && k.name == user) {
+			continue
+		}
+		if now.Before(r.until) {
+			cleared++
+		}
+		delete(t.records, k)
+	}
+	t.Unlock()
+	if cleared > 0 {
+		s.Noticef("Lifted %d authentication ban(s)", cleared)
+	}
+	return cleared
+}
@@ -0,0 +1,248 @@
+// Copyright 2019 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestAuthFailuresDelay(t *testing.T) {
+	opts := DefaultOptions()
+	opts.AuthFailures = AuthFailureOpts{Delay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	s := RunServer(opts)
+	defer s.Shutdown()
+
+	c := &client{srv: s, host: "10.0.0.1"}
+	for _, expected := range []time.Duration{10, 20, 40, 50, 50} {
+		if d := s.recordAuthFailure(c); d != expected*time.Millisecond {
+			t.Fatalf("Expected delay of %v, got %v", expected*time.Millisecond, d)
+		}
+	}
+	// Delay is not enough to ban.
+	if bans := s.authBans(); len(bans) != 0 {
+		t.Fatalf("Expected no ban, got %+v", bans)
+	}
+	// Disabled by default.
+	s2 := RunServer(DefaultOptions())
+	defer s2.Shutdown()
+	c.srv = s2
+	if d := s2.recordAuthFailure(c); d != 0 {
+		t.Fatalf("Expected no delay, got %v", d)
+	}
+}
+
+func TestAuthFailuresBanEscalation(t *testing.T) {
+	opts := DefaultOptions()
+	opts.AuthFailures = AuthFailureOpts{MaxFailures: 2, BanTime: time.Second, MaxBanTime: 3 * time.Second}
+	s := RunServer(opts)
+	defer s.Shutdown()
+
+	c := &client{srv: s, host: "10.0.0.1"}
+	for i, expected := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		s.recordAuthFailure(c)
+		if _, banned := s.isAuthBanned(authBanIP, c.host); banned {
+			t.Fatalf("Iteration %d: should not be banned after a single failure", i)
+		}
+		start := time.Now()
+		s.recordAuthFailure(c)
+		until, banned := s.isAuthBanned(authBanIP, c.host)
+		if !banned {
+			t.Fatalf("Iteration %d: expected to be banned", i)
+		}
+		if d := until.Sub(start); d < expected || d > expected+time.Second/2 {
+			t.Fatalf("Iteration %d: expected ban of %v, got %v", i, expected, d)
+		}
+		// Simulate the end of the ban.
+		s.authFails.Lock()
+		s.authFails.records[authFailKey{authBanIP, c.host}].until = time.Now()
+		s.authFails.Unlock()
+	}
+}
+
+func TestAuthFailuresBan(t *testing.T) {
+	conf := createConfFile(t, []byte(`
+		listen: "127.0.0.1:-1"
+		http: "127.0.0.1:-1"
+		auth_failures {
+			max_failures: 3
+			window: "1m"
+			ban_time: "1m"
+		}
+		system_account: SYS
+		accounts {
+			SYS { users [{user: sys, password: pwd}] }
+			A { users [{user: alice, password: pwd}, {user: bob, password: pwd}] }
+		}
+	`))
+	defer os.Remove(conf)
+	s, opts := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	url := func(user, pass string) string {
+		return fmt.Sprintf("nats://%s:%s@%s:%d", user, pass, opts.Host, opts.Port)
+	}
+
+	sys, err := nats.Connect(url("sys", "pwd"))
+	if err != nil {
+		t.Fatalf("Error on connect: %v", err)
+	}
+	defer sys.Close()
+	advisories, _ := sys.SubscribeSync(fmt.Sprintf(authBanEventSubj, s.ID()))
+	sys.Flush()
+
+	for i := 0; i < 3; i++ {
+		if nc, err := nats.Connect(url("bob", "wrong")); err == nil {
+			nc.Close()
+			t.Fatal("Expected connection to fail")
+		}
+	}
+
+	// Both the source address and the user are banned.
+	for i := 0; i < 2; i++ {
+		msg, err := advisories.NextMsg(time.Second)
+		if err != nil {
+			t.Fatalf("Expected advisory: %v", err)
+		}
+		e := AuthBanEventMsg{}
+		if err := json.Unmarshal(msg.Data, &e); err != nil {
+			t.Fatalf("Error unmarshalling advisory: %v", err)
+		}
+		if (e.Type != authBanIP || e.Name != "127.0.0.1") && (e.Type != authBanUser || e.Name != "bob") {
+			t.Fatalf("Unexpected advisory: %+v", e)
+		}
+		if e.Failures != 3 || e.Bans != 1 || e.Server.ID != s.ID() {
+			t.Fatalf("Unexpected advisory: %+v", e)
+		}
+	}
+	banz, _ := s.Banz(nil)
+	if len(banz.Bans) != 2 {
+		t.Fatalf("Expected 2 bans, got %+v", banz.Bans)
+	}
+
+	// Even good credentials are rejected from the banned source address.
+	if nc, err := nats.Connect(url("alice", "pwd")); err == nil {
+		nc.Close()
+		t.Fatal("Expected connection to fail")
+	}
+	checkFor(t, time.Second, 15*time.Millisecond, func() error {
+		c, _ := s.Connz(&ConnzOptions{State: ConnClosed})
+		for _, ci := range c.Conns {
+			if ci.Reason == AuthenticationBanned.String() {
+				return nil
+			}
+		}
+		return fmt.Errorf("No connection closed because of a ban")
+	})
+
+	// The monitoring endpoint is read-only and can't lift bans.
+	resp := readBody(t, fmt.Sprintf("http://127.0.0.1:%d%s?clear_ip=127.0.0.1&clear_all=true", s.MonitorAddr().Port, BanzPath))
+	banz = &Banz{}
+	if err := json.Unmarshal(resp, banz); err != nil {
+		t.Fatalf("Error unmarshalling banz: %v", err)
+	}
+	if banz.Cleared != 0 || len(banz.Bans) != 2 {
+		t.Fatalf("Unexpected banz: %+v", banz)
+	}
+
+	// Lift the source address ban through a system request.
+	req, _ := json.Marshal(&BanzOptions{ClearIP: "127.0.0.1"})
+	msg, err := sys.Request(fmt.Sprintf(serverBanzReqSubj, s.ID()), req, time.Second)
+	if err != nil {
+		t.Fatalf("Error on request: %v", err)
+	}
+	banz = &Banz{}
+	if err := json.Unmarshal(msg.Data, banz); err != nil {
+		t.Fatalf("Error unmarshalling banz: %v", err)
+	}
+	if banz.Cleared != 1 || len(banz.Bans) != 1 || banz.Bans[0].Type != authBanUser || banz.Bans[0].Name != "bob" {
+		t.Fatalf("Unexpected banz: %+v", banz)
+	}
+	nc, err := nats.Connect(url("alice", "pwd"))
+	if err != nil {
+		t.Fatalf("Error on connect: %v", err)
+	}
+	nc.Close()
+	// Bob is still banned.
+	if nc, err := nats.Connect(url("bob", "pwd")); err == nil {
+		nc.Close()
+		t.Fatal("Expected connection to fail")
+	}
+
+	// Lift the user ban through a system request.
+	req, _ = json.Marshal(&BanzOptions{ClearUser: "bob"})
+	msg, err = sys.Request(fmt.Sprintf(serverBanzReqSubj, s.ID()), req, time.Second)
+	if err != nil {
+		t.Fatalf("Error on request: %v", err)
+	}
+	banz = &Banz{}
+	if err := json.Unmarshal(msg.Data, banz); err != nil {
+		t.Fatalf("Error unmarshalling banz: %v", err)
+	}
+	if banz.Cleared != 1 || len(banz.Bans) != 0 {
+		t.Fatalf("Unexpected banz: %+v", banz)
+	}
+	nc, err = nats.Connect(url("bob", "pwd"))
+	if err != nil {
+		t.Fatalf("Error on connect: %v", err)
+	}
+	nc.Close()
+}
+
+func TestAuthFailuresConfig(t *testing.T) {
+	conf := createConfFile(t, []byte(`
+		auth_failures {
+			max_failures: 5
+			window: "30s"
+			delay: "100ms"
+			max_delay: "2s"
+			ban_time: "10m"
+			max_ban_time: "24h"
+		}
+	`))
+	defer os.Remove(conf)
+	opts, err := ProcessConfigFile(conf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := AuthFailureOpts{
+		MaxFailures: 5,
+		Window:      30 * time.Second,
+		Delay:       100 * time.Millisecond,
+		MaxDelay:    2 * time.Second,
+		BanTime:     10 * time.Minute,
+		MaxBanTime:  24 * time.Hour,
+	}
+	if opts.AuthFailures != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, opts.AuthFailures)
+	}
+
+	for _, test := range []string{
+		`auth_failures { max_failures: -1 }`,
+		`auth_failures { window: "abc" }`,
+		`auth_failures { ban_time: 10 }`,
+		`auth_failures { unknown: 10 }`,
+	} {
+		conf := createConfFile(t, []byte(test))
+		if _, err := ProcessConfigFile(conf); err == nil {
+			t.Fatalf("Expected error for %q", test)
+		}
+		os.Remove(conf)
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright 2019 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// certWatch tracks the certificate, key and CA files backing the TLS
+// configuration of a listener. When the files change on disk, the new
+// certificate and CA pool are swapped in for new connections without
+// the need for a full configuration reload.
+type certWatch struct {
+	sync.RWMutex
+	name     string
+	certFile string
+	keyFile  string
+	caFile   string
+	mods     []time.Time
+	base     *tls.Config
+	cfg      *tls.Config
+	cert     *tls.Certificate
+}
+
+// newCertWatch creates a watch for the given files and installs the hooks
+// in the TLS configuration so that handshakes will use the certificates
+// currently held by the watch. Since tls.Config.Clone() copies the hooks,
+// clones made after this call (for solicited connections or the HTTPS
+// listener) will also pick up the changes.
+// This must be called before the configuration is used.
+func newCertWatch(name string, config *tls.Config, tc *TLSConfigOpts) *certWatch {
+	if config == nil || tc == nil || tc.CertFile == _EMPTY_ || tc.KeyFile == _EMPTY_ {
+		return nil
+	}
+	cw := &certWatch{
+		name:     name,
+		certFile: tc.CertFile,
+		keyFile:  tc.KeyFile,
+		caFile:   tc.CaFile,
+		base:     config.Clone(),
+	}
+	cw.cfg = cw.base
+	cw.mods = cw.modTimes()
+	if len(config.Certificates) > 0 {
+		cw.cert = &config.Certificates[0]
+	}
+	config.GetConfigForClient = cw.getConfigForClient
+	config.GetClientCertificate = cw.getClientCertificate
+	return cw
+}
+
+// withoutCertWatchHooks returns a copy of the options whose TLS
+// configurations don't have the hooks installed by the certificate
+// watches, so that they can be compared with newly parsed options.
+func withoutCertWatchHooks(opts *Options) *Options {
+	strip := func(config *tls.Config) *tls.Config {
+		if config == nil || config.GetConfigForClient == nil {
+			return config
+		}
+		config = config.Clone()
+		config.GetConfigForClient = nil
+		config.GetClientCertificate = nil
+		return config
+	}
+	o := *opts
+	o.TLSConfig = strip(o.TLSConfig)
+	o.Cluster.TLSConfig = strip(o.Cluster.TLSConfig)
+	o.Gateway.TLSConfig = strip(o.Gateway.TLSConfig)
+	o.LeafNode.TLSConfig = strip(o.LeafNode.TLSConfig)
+	return &o
+}
+
+// Invoked for every handshake of an accepted connection.
+func (cw *certWatch) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	cw.RLock()
+	cfg := cw.cfg
+	cw.RUnlock()
+	return cfg, nil
+}
+
+// Invoked for every handshake of a solicited connection when the
+// remote requests a client certificate.
+func (cw *certWatch) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	cw.RLock()
+	cert := cw.cert
+	cw.RUnlock()
+	return cert, nil
+}
+
+// Returns the modification time of each of the watched files. A zero
+// value is used for files that can't be accessed.
+func (cw *certWatch) modTimes() []time.Time {
+	files := []string{cw.certFile, cw.keyFile, cw.caFile}
+	mods := make([]time.Time, len(files))
+	for i, f := range files {
+		if f == _EMPTY_ {
+			continue
+		}
+		if fi, err := os.Stat(f); err == nil {
+			mods[i] = fi.ModTime()
+		}
+	}
+	return mods
+}
+
+// changed returns true if any of the watched files has been modified
+// since the last check, and records the new modification times.
+func (cw *certWatch) changed() bool {
+	mods := cw.modTimes()
+	changed := false
+	for i, m := range mods {
+		if !m.Equal(cw.mods[i]) {
+			changed = true
+			break
+		}
+	}
+	cw.mods = mods
+	return changed
+}
+
+// reload loads the certificate, key and CA files and, if they are valid,
+// replaces the ones currently in use. On error, the previous ones are kept.
+func (cw *certWatch) reload() error {
+	cert, err := tls.LoadX509KeyPair(cw.certFile, cw.keyFile)
+	if err != nil {
+		return fmt.Errorf("error parsing X509 certificate/key pair: %v", err)
+	}
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("error parsing certificate: %v", err)
+	}
+	cfg := cw.base.Clone()
+	cfg.Certificates = []tls.Certificate{cert}
+	if cw.caFile != _EMPTY_ {
+		rootPEM, err := ioutil.ReadFile(cw.caFile)
+		if err != nil {
+			return fmt.Errorf("error reading root ca certificate: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(rootPEM) {
+			return fmt.Errorf("failed to parse root ca certificate")
+		}
+		cfg.ClientCAs = pool
+		if cw.base.RootCAs != nil {
+			cfg.RootCAs = pool
+		}
+	}
+	cw.Lock()
+	cw.cfg = cfg
+	cw.cert = &cert
+	cw.Unlock()
+	return nil
+}
+
+// configureCertWatches installs certificate watches on the TLS
+// configurations of the client (and HTTPS), cluster, gateway and
+// leafnode listeners found in the given options.
+// This is invoked before the options are used by the server.
+func (s *Server) configureCertWatches(opts *Options) {
+	var watches []*certWatch
+	if opts.TLSWatchInterval > 0 {
+		add := func(name string, config *tls.Config, tc *TLSConfigOpts) {
+			if cw := newCertWatch(name, config, tc); cw != nil {
+				watches = append(watches, cw)
+			}
+		}
+		add("client", opts.TLSConfig, opts.tlsConfigOpts)
+		add("cluster", opts.Cluster.TLSConfig, opts.Cluster.tlsConfigOpts)
+		add("gateway", opts.Gateway.TLSConfig, opts.Gateway.tlsConfigOpts)
+		add("leafnode", opts.LeafNode.TLSConfig, opts.LeafNode.tlsConfigOpts)
+	}
+	s.certWatchMu.Lock()
+	s.certWatches = watches
+	s.certWatchMu.Unlock()
+}
+
+// startCertWatchLoop starts the go routine checking for certificate
+// changes, unless it is already running or disabled.
+func (s *Server) startCertWatchLoop() {
+	s.certWatchMu.Lock()
+	defer s.certWatchMu.Unlock()
+	if s.certWatchRunning || s.getOpts().TLSWatchInterval <= 0 {
+		return
+	}
+	s.certWatchRunning = true
+	s.startGoRoutine(s.certWatchLoop)
+}
+
+// certWatchLoop periodically checks the watched certificate files for
+// changes. The interval is read from the options on each iteration so that
+// it can be changed on config reload. The loop exits when the interval is
+// set to 0 or the server is shutdown.
+func (s *Server) certWatchLoop() {
+	defer s.grWG.Done()
+
+	for {
+		interval := s.getOpts().TLSWatchInterval
+		if interval <= 0 {
+			s.certWatchMu.Lock()
+			s.certWatchRunning = false
+			s.certWatchMu.Unlock()
+			return
+		}
+		select {
+		case <-time.After(interval):
+		case <-s.quitCh:
+			return
+		}
+		s.checkCertWatches()
+	}
+}
+
+// checkCertWatches reloads the certificates of the watches whose
+// files have been modified.
+func (s *Server) checkCertWatches() {
+	s.certWatchMu.Lock()
+	defer s.certWatchMu.Unlock()
+	for _, cw := range s.certWatches {
+		if !cw.changed() {
+			continue
+		}
+		if err := cw.reload(); err != nil {
+			s.Errorf("Unable to reload %s TLS certificate, keeping current one: %v", cw.name, err)
+			continue
+		}
+		s.Noticef("Reloaded: %s TLS certificate", cw.name)
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2019 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func copyCertFile(t *testing.T, src, dst string) {
+	t.Helper()
+	content, err := ioutil.ReadFile(src)
+	if err != nil {
+		t.Fatalf("Error reading file: %v", err)
+	}
+	if err := ioutil.WriteFile(dst, content, 0666); err != nil {
+		t.Fatalf("Error writing file: %v", err)
+	}
+}
+
+func getClientTLSPeerCert(t *testing.T, opts *Options) []byte {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", opts.Host, opts.Port), 2*time.Second)
+	if err != nil {
+		t.Fatalf("Unexpected error on dial: %v", err)
+	}
+	defer conn.Close()
+	br := bufio.NewReaderSize(conn, 100)
+	if _, err := br.ReadString('\n'); err != nil {
+		t.Fatalf("Unexpected error reading INFO: %v", err)
+	}
+	tlsConn := tls.Client(conn, &tls.Config{InsecureSkipVerify: true})
+	defer tlsConn.Close()
+	if err := tlsConn.Handshake(); err != nil {
+		t.Fatalf("Unexpected error during handshake: %v", err)
+	}
+	return tlsConn.ConnectionState().PeerCertificates[0].Raw
+}
+
+func TestTLSCertWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certwatch")
+	if err != nil {
+		t.Fatalf("Error creating dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	copyCertFile(t, "../test/configs/certs/server-cert.pem", certFile)
+	copyCertFile(t, "../test/configs/certs/server-key.pem", keyFile)
+
+	conf := createConfFile(t, []byte(fmt.Sprintf(`
+		listen: "127.0.0.1:-1"
+		tls_watch_interval: "15ms"
+		tls {
+			cert_file: %q
+			key_file: %q
+		}
+	`, certFile, keyFile)))
+	defer os.Remove(conf)
+
+	s, opts := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	orgCert := getClientTLSPeerCert(t, opts)
+
+	// Replace with a different certificate.
+	copyCertFile(t, "../test/configs/certs/server-noip.pem", certFile)
+	copyCertFile(t, "../test/configs/certs/server-key-noip.pem", keyFile)
+
+	var newCert []byte
+	checkFor(t, 2*time.Second, 15*time.Millisecond, func() error {
+		newCert = getClientTLSPeerCert(t, opts)
+		if bytes.Equal(orgCert, newCert) {
+			return fmt.Errorf("Certificate was not reloaded")
+		}
+		return nil
+	})
+
+	// Now write an invalid key, the current certificate should be kept.
+	if err := ioutil.WriteFile(keyFile, []byte("bad key"), 0666); err != nil {
+		t.Fatalf("Error writing file: %v", err)
+	}
+	s.checkCertWatches()
+	if cert := getClientTLSPeerCert(t, opts); !bytes.Equal(cert, newCert) {
+		t.Fatal("Expected certificate to be kept after invalid update")
+	}
+}
+
+func TestTLSCertWatchReloadUnchanged(t *testing.T) {
+	conf := createConfFile(t, []byte(`
+		listen: "127.0.0.1:-1"
+		tls_watch_interval: "1h"
+		tls {
+			cert_file: "../test/configs/certs/server-cert.pem"
+			key_file: "../test/configs/certs/server-key.pem"
+		}
+		cluster {
+			listen: "127.0.0.1:-1"
+			tls {
+				cert_file: "../test/configs/certs/server-cert.pem"
+				key_file: "../test/configs/certs/server-key.pem"
+			}
+		}
+	`))
+	defer os.Remove(conf)
+
+	s, _ := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	// Reload twice, so that the current options are the ones
+	// whose watches were installed on reload.
+	for i := 0; i < 2; i++ {
+		if err := s.Reload(); err != nil {
+			t.Fatalf("Error reloading config: %v", err)
+		}
+		newOpts, err := ProcessConfigFile(conf)
+		if err != nil {
+			t.Fatalf("Error processing config file: %v", err)
+		}
+		// Keep the random ports, as Reload does.
+		setBaselineOptions(newOpts)
+		newOpts.Port = s.getOpts().Port
+		newOpts.Cluster.Port = s.getOpts().Cluster.Port
+		changed, err := s.diffOptions(newOpts)
+		if err != nil {
+			t.Fatalf("Error diffing options: %v", err)
+		}
+		for _, opt := range changed {
+			switch opt.(type) {
+			case *tlsOption, *clusterOption:
+				t.Fatalf("Expected TLS to be unchanged, got %T", opt)
+			}
+		}
+	}
+}
+
+func TestTLSCertWatchDisabled(t *testing.T) {
+	opts, err := ProcessConfigFile("./configs/tls.conf")
+	if err != nil {
+		t.Fatalf("Error processing config file: %v", err)
+	}
+	opts.NoLog = true
+	opts.NoSigs = true
+	s := RunServer(opts)
+	defer s.Shutdown()
+
+	if opts.TLSConfig.GetConfigForClient != nil {
+		t.Fatal("Expected no TLS hook to be installed")
+	}
+	s.certWatchMu.Lock()
+	running, n := s.certWatchRunning, len(s.certWatches)
+	s.certWatchMu.Unlock()
+	if running || n != 0 {
+		t.Fatalf("Expected no watch, got running=%v watches=%v", running, n)
+	}
+}
+
+func TestTLSCertWatchMonitoringPort(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certwatch")
+	if err != nil {
+		t.Fatalf("Error creating dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	copyCertFile(t, "../test/configs/certs/server-cert.pem", certFile)
+	copyCertFile(t, "../test/configs/certs/server-key.pem", keyFile)
+
+	conf := createConfFile(t, []byte(fmt.Sprintf(`
+		listen: "127.0.0.1:-1"
+		https: "127.0.0.1:-1"
+		tls_watch_interval: "15ms"
+		tls {
+			cert_file: %q
+			key_file: %q
+		}
+	`, certFile, keyFile)))
+	defer os.Remove(conf)
+
+	s, _ := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	getPeerCert := func() []byte {
+		t.Helper()
+		conn, err := tls.Dial("tcp", s.MonitorAddr().String(), &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatalf("Unexpected error on dial: %v", err)
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].Raw
+	}
+	orgCert := getPeerCert()
+
+	// Replace with a different certificate.
+	copyCertFile(t, "../test/configs/certs/server-noip.pem", certFile)
+	copyCertFile(t, "../test/configs/certs/server-key-noip.pem", keyFile)
+
+	checkFor(t, 2*time.Second, 15*time.Millisecond, func() error {
+		if bytes.Equal(orgCert, getPeerCert()) {
+			return fmt.Errorf("Certificate was not reloaded")
+		}
+		return nil
+	})
+}
@@ -76,7 +76,7 @@ const (
 var readLoopReportThreshold = readLoopReport
 
 // Represent client booleans with a bitmask
-type clientFlag byte
+type clientFlag uint16
 
 // Some client state represented as flags
 const (
@@ -88,6 +88,7 @@ const (
 	flushOutbound                            // Marks client as having a flushOutbound call in progress.
 	noReconnect                              // Indicate that on close, this connection should not attempt a reconnect
 	closeConnection                          // Marks that closeConnection has already been called.
+	linkEventSent                            // Marks that the connect event of a route, gateway or leafnode has been sent.
 )
 
 // set the flag (would be equivalent to set the boolean to true)
@@ -147,6 +148,10 @@ const (
 	WrongGateway
 	MissingAccount
 	Revocation
+	MaxConnectionsPerIPExceeded
+	SourceAddressNotAllowed
+	AuthenticationBanned
+	MaxPermissionsViolationsExceeded
 )
 
 // Some flags passed to processMsgResultsEx
@@ -180,6 +185,9 @@ type client struct {
 	perms   *permissions
 	replies map[string]*resp
 	mperms  *msgDeny
+	pviol   []time.Time
+	pvev    time.Time
+	aerr    error // Reason authentication failed other than the credentials.
 	darray  []string
 	in      readCache
 	pcd     map[*client]struct{}
@@ -229,6 +237,7 @@ type outbound struct {
 	stc chan struct{} // Stall chan we create to slow down producers on overrun, e.g. fan-in.
 	lwb int32         // Last byte size of Write.
 	sgw bool          // Indicate flusher is waiting on condition wait.
+	sbj []byte        // Subject of the message being queued, for slow consumer advisories.
 }
 
 type perm struct {
@@ -298,6 +307,8 @@ type readCache struct {
 
 	rsz int32 // Read buffer size
 	srs int32 // Short reads, used for dynamic buffer resizing.
+
+	hsn uint32 // Messages since the last hot subject sample.
 }
 
 const (
@@ -552,6 +563,8 @@ func (c *client) applyAccountLimits() {
 		c.msubs = int32(opts.MaxSubs)
 	}
 
+	c.applyUserLimits()
+
 	if c.subsAtLimit() {
 		go func() {
 			c.maxSubsExceeded()
@@ -561,6 +574,21 @@ func (c *client) applyAccountLimits() {
 	}
 }
 
+// Apply the limits of the user, which can only lower the ones in place.
+// Lock is held on entry.
+func (c *client) applyUserLimits() {
+	u := c.user
+	if u == nil || (c.kind != CLIENT && c.kind != LEAF) {
+		return
+	}
+	if u.msubs > 0 && (c.msubs == jwt.NoLimit || u.msubs < c.msubs) {
+		c.msubs = u.msubs
+	}
+	if u.mpay > 0 && (c.mpay == jwt.NoLimit || u.mpay < c.mpay) {
+		c.mpay = u.mpay
+	}
+}
+
 // RegisterUser allows auth to call back into a new client
 // with the authenticated user. This is used to map
 // any permissions into the client and setup accounts.
@@ -600,6 +628,7 @@ func (c *client) RegisterNkeyUser(user *NkeyUser) error {
 
 	c.mu.Lock()
 	c.user = user
+	c.applyUserLimits()
 	// Assign permissions.
 	if user.Permissions == nil {
 		// Reset perms to nil in case client previously had them.
@@ -630,6 +659,14 @@ func (c *client) setPermissions(perms *Permissions) {
 	if perms == nil {
 		return
 	}
+	// Expand templated subjects with the values of this connection.
+	if perms.hasTemplates() {
+		var errs []error
+		perms, errs = perms.expandTemplates(c.templateValues())
+		for _, err := range errs {
+			c.Errorf("Permission template: %v", err)
+		}
+	}
 	c.perms = &permissions{}
 	c.perms.pcache = make(map[string]bool)
 
@@ -858,6 +895,11 @@ func (c *client) readLoop() {
 			atomic.AddInt64(&c.inBytes, int64(c.in.bytes))
 			atomic.AddInt64(&s.inMsgs, int64(c.in.msgs))
 			atomic.AddInt64(&s.inBytes, int64(c.in.bytes))
+			// Routes and gateways carry messages of any account, those
+			// are accounted for as they are processed.
+			if acc := c.acc; acc != nil && (c.kind == CLIENT || c.kind == LEAF) {
+				acc.addInbound(c.kind, int64(c.in.msgs), int64(c.in.bytes))
+			}
 		}
 
 		// Budget to spend in place flushing outbound data.
@@ -1047,6 +1089,7 @@ func (c *client) flushOutbound() bool {
 				atomic.AddInt64(&srv.slowConsumers, 1)
 				c.Noticef("Slow Consumer Detected: WriteDeadline of %v exceeded with %d chunks of %d total bytes.",
 					c.out.wdl, len(cnb), attempted)
+				c.slowConsumerEvent(nil, attempted, SlowConsumerWriteDeadline)
 				c.clearConnection(SlowConsumerWriteDeadline)
 			}
 		} else {
@@ -1268,8 +1311,30 @@ func (c *client) processConnect(arg []byte) error {
 			srv.mu.Unlock()
 		}
 
+		// Reject users that are banned because of repeated authentication
+		// failures, before checking their credentials.
+		if kind == CLIENT || kind == LEAF {
+			c.mu.Lock()
+			user := c.authFailUserName()
+			c.mu.Unlock()
+			if user != _EMPTY_ {
+				if _, banned := srv.isAuthBanned(authBanUser, user); banned {
+					c.authBanned()
+					return ErrAuthenticationBanned
+				}
+			}
+		}
+
 		// Check for Auth
 		if ok := srv.checkAuthentication(c); !ok {
+			// The connection may have been rejected and closed because
+			// of its source address.
+			c.mu.Lock()
+			aerr := c.aerr
+			c.mu.Unlock()
+			if aerr == ErrSourceAddressNotAllowed {
+				return aerr
+			}
 			// We may fail here because we reached max limits on an account.
 			if ujwt != "" {
 				c.mu.Lock()
@@ -1279,6 +1344,15 @@ func (c *client) processConnect(arg []byte) error {
 					return ErrTooManyAccountConnections
 				}
 			}
+			// Slow down clients that keep failing to authenticate.
+			if kind == CLIENT || kind == LEAF {
+				if delay := srv.recordAuthFailure(c); delay > 0 {
+					select {
+					case <-time.After(delay):
+					case <-srv.quitCh:
+					}
+				}
+			}
 			c.authViolation()
 			return ErrAuthentication
 		}
@@ -1406,12 +1480,36 @@ func (c *client) maxConnExceeded() {
 	c.closeConnection(MaxConnectionsExceeded)
 }
 
+func (c *client) maxConnPerIPExceeded() {
+	atomic.AddInt64(&c.srv.ipLimitRejects, 1)
+	c.sendErrAndErr(ErrTooManyConnectionsPerIP.Error())
+	c.closeConnection(MaxConnectionsPerIPExceeded)
+}
+
+func (c *client) authBanned() {
+	c.sendErrAndErr(ErrAuthenticationBanned.Error())
+	c.closeConnection(AuthenticationBanned)
+}
+
+func (c *client) sourceAddressNotAllowed() {
+	c.mu.Lock()
+	c.aerr = ErrSourceAddressNotAllowed
+	c.mu.Unlock()
+	atomic.AddInt64(&c.srv.srcAddrRejects, 1)
+	c.sendErrAndErr(ErrSourceAddressNotAllowed.Error())
+	c.closeConnection(SourceAddressNotAllowed)
+}
+
 func (c *client) maxSubsExceeded() {
 	c.sendErrAndErr(ErrTooManySubs.Error())
 }
 
 func (c *client) maxPayloadViolation(sz int, max int32) {
 	c.Errorf("%s: %d vs %d", ErrMaxPayload.Error(), sz, max)
+	if c.srv != nil {
+		m := &ViolationEventMsg{Type: violationPayload, Subject: string(c.pa.subject), Size: sz, MaxPayload: int(max)}
+		c.srv.sendViolationEvent(c, m, MaxPayloadExceeded)
+	}
 	c.sendErr("Maximum Payload Violation")
 	c.closeConnection(MaxPayloadExceeded)
 }
@@ -1436,6 +1534,7 @@ func (c *client) queueOutbound(data []byte) bool {
 	if c.out.pb > c.out.mp {
 		atomic.AddInt64(&c.srv.slowConsumers, 1)
 		c.Noticef("Slow Consumer Detected: MaxPending of %d Exceeded", c.out.mp)
+		c.slowConsumerEvent(c.out.sbj, c.out.pb, SlowConsumerPendingBytes)
 		c.clearConnection(SlowConsumerPendingBytes)
 		return referenced
 	}
@@ -1874,6 +1973,14 @@ func (c *client) addShadowSubscriptions(acc *Account, sub *subscription) error {
 			continue
 		}
 		subj := string(sub.subject)
+		// With a transform, the subscription's subject is mapped back
+		// into the exporting account, resolving what it can.
+		if im.tr != nil {
+			if _, ok := im.tr.reverse(subj); ok {
+				ims = append(ims, im)
+			}
+			continue
+		}
 		if subj == im.prefix+im.from {
 			ims = append(ims, im)
 			continue
@@ -1945,6 +2052,10 @@ func (c *client) addShadowSub(sub *subscription, im *streamImport, useFrom bool)
 	nsub.im = im
 	if useFrom {
 		nsub.subject = []byte(im.from)
+	} else if im.tr != nil {
+		// Map the subject back through the transform into the publisher account space.
+		subj, _ := im.tr.reverse(string(sub.subject))
+		nsub.subject = []byte(subj)
 	} else if im.prefix != "" {
 		// redo subject here to match subject in the publisher account space.
 		// Just remove prefix from what they gave us. That maps into other space.
@@ -2295,6 +2406,12 @@ func (c *client) deliverMsg(sub *subscription, subject, mh, msg []byte) bool {
 	atomic.AddInt64(&srv.outMsgs, 1)
 	atomic.AddInt64(&srv.outBytes, msgSize)
 
+	// Routes and gateways carry messages of any account, those are
+	// accounted for by the callers.
+	if client.acc != nil && (client.kind == CLIENT || client.kind == LEAF) {
+		client.acc.addOutbound(client.kind, 1, msgSize)
+	}
+
 	// Check for internal subscription.
 	if client.kind == SYSTEM {
 		s := client.srv
@@ -2333,8 +2450,10 @@ func (c *client) deliverMsg(sub *subscription, subject, mh, msg []byte) bool {
 	}
 
 	// Queue to outbound buffer
+	client.out.sbj = subject
 	client.queueOutbound(mh)
 	client.queueOutbound(msg)
+	client.out.sbj = nil
 
 	client.out.pm++
 
@@ -2586,6 +2705,9 @@ func (c *client) processInboundClientMsg(msg []byte) {
 	// Check pub permissions
 	if c.perms != nil && (c.perms.pub.allow != nil || c.perms.pub.deny != nil) && !c.pubAllowed(string(c.pa.subject)) {
 		c.pubPermissionViolation(c.pa.subject)
+		if c.acc != nil && isServiceReply(c.pa.subject) {
+			c.acc.checkDeniedResponse(string(c.pa.subject))
+		}
 		return
 	}
 
@@ -2604,6 +2726,10 @@ func (c *client) processInboundClientMsg(msg []byte) {
 		return
 	}
 
+	if hs := c.srv.hotSubs; hs != nil {
+		c.sampleHotSubject(hs, c.acc, c.pa.subject, c.pa.size)
+	}
+
 	// Check to see if we need to map/route to another account.
 	if c.acc.imports.services != nil {
 		c.checkForImportServices(c.acc, msg)
@@ -2695,6 +2821,9 @@ func (c *client) checkForImportServices(acc *Account, msg []byte) {
 
 	acc.mu.RLock()
 	si := acc.imports.services[string(c.pa.subject)]
+	if si == nil && len(acc.imports.trs) > 0 {
+		si = acc.imports.matchServiceTransform(string(c.pa.subject))
+	}
 	invalid := si != nil && si.invalid
 	acc.mu.RUnlock()
 
@@ -2702,6 +2831,7 @@ func (c *client) checkForImportServices(acc *Account, msg []byte) {
 	// If we have been marked invalid simply return here.
 	if si != nil && !invalid && si.acc != nil && si.acc.sl != nil {
 		var nrr []byte
+		var rsi *serviceImport
 		if c.pa.reply != nil {
 			var latency *serviceLatency
 			var tracking bool
@@ -2710,7 +2840,7 @@ func (c *client) checkForImportServices(acc *Account, msg []byte) {
 			}
 			// We want to remap this to provide anonymity.
 			nrr = c.newServiceReply(tracking)
-			si.acc.addRespServiceImport(acc, string(nrr), string(c.pa.reply), si.rt, latency)
+			rsi = si.acc.addRespServiceImport(acc, string(nrr), string(c.pa.reply), si.rt, latency)
 
 			// Track our responses for cleanup if not auto-expire.
 			if si.rt != Singleton {
@@ -2726,8 +2856,13 @@ func (c *client) checkForImportServices(acc *Account, msg []byte) {
 				c.srv.gatewayHandleServiceImport(si.acc, nrr, c, 1)
 			}
 		}
+		// Map the subject for imports with a transform.
+		to := si.to
+		if si.tr != nil {
+			to, _ = si.tr.reverse(string(c.pa.subject))
+		}
 		// FIXME(dlc) - Do L1 cache trick from above.
-		rr := si.acc.sl.Match(si.to)
+		rr := si.acc.sl.Match(to)
 
 		// Check to see if we have no results and this is an internal serviceImport. If so we
 		// need to clean that up.
@@ -2736,6 +2871,12 @@ func (c *client) checkForImportServices(acc *Account, msg []byte) {
 			si.acc.checkForRespEntry(si.to)
 		}
 
+		// Report a tracked request without responders. With gateways, the
+		// responders may be in other clusters.
+		if rsi != nil && rsi.tracking && len(rr.psubs)+len(rr.qsubs) == 0 && !c.srv.gateway.enabled {
+			si.acc.removeTrackedResponse(rsi, LatencyStatusNoResponders)
+		}
+
 		// If we are a route or gateway or leafnode and this message is flipped to a queue subscriber we
 		// need to handle that since the processMsgResults will want a queue filter.
 		if len(rr.qsubs) > 0 && c.pa.queues == nil && (c.kind == ROUTER || c.kind == GATEWAY || c.kind == LEAF) {
@@ -2745,10 +2886,10 @@ func (c *client) checkForImportServices(acc *Account, msg []byte) {
 		// If this is not a gateway connection but gateway is enabled,
 		// try to send this converted message to all gateways.
 		if c.srv.gateway.enabled && (c.kind == CLIENT || c.kind == SYSTEM || c.kind == LEAF) {
-			queues := c.processMsgResults(si.acc, rr, msg, []byte(si.to), nrr, pmrCollectQueueNames)
-			c.sendMsgToGateways(si.acc, msg, []byte(si.to), nrr, queues)
+			queues := c.processMsgResults(si.acc, rr, msg, []byte(to), nrr, pmrCollectQueueNames)
+			c.sendMsgToGateways(si.acc, msg, []byte(to), nrr, queues)
 		} else {
-			c.processMsgResults(si.acc, rr, msg, []byte(si.to), nrr, pmrNoFlag)
+			c.processMsgResults(si.acc, rr, msg, []byte(to), nrr, pmrNoFlag)
 		}
 
 		shouldRemove := si.ae
@@ -2841,11 +2982,10 @@ func (c *client) processMsgResults(acc *Account, r *SublistResult, msg, subject,
 			continue
 		}
 		// Check for stream import mapped subs. These apply to local subs only.
-		if sub.im != nil && sub.im.prefix != "" {
+		if sub.im != nil && sub.im.isMapped() {
 			// Redo the subject here on the fly.
 			msgh = c.msgb[1:msgHeadProtoLen]
-			msgh = append(msgh, sub.im.prefix...)
-			msgh = append(msgh, subject...)
+			msgh = sub.im.appendSubject(msgh, subject)
 			msgh = append(msgh, ' ')
 			si = len(msgh)
 		}
@@ -2928,11 +3068,10 @@ func (c *client) processMsgResults(acc *Account, r *SublistResult, msg, subject,
 			}
 
 			// Check for mapped subs
-			if sub.im != nil && sub.im.prefix != "" {
+			if sub.im != nil && sub.im.isMapped() {
 				// Redo the subject here on the fly.
 				msgh = c.msgb[1:msgHeadProtoLen]
-				msgh = append(msgh, sub.im.prefix...)
-				msgh = append(msgh, subject...)
+				msgh = sub.im.appendSubject(msgh, subject)
 				msgh = append(msgh, ' ')
 				si = len(msgh)
 			}
@@ -2976,15 +3115,17 @@ sendToRoutesOrLeafs:
 			mh[0] = 'R'
 			mh = append(mh, acc.Name...)
 			mh = append(mh, ' ')
+			mh = append(mh, subject...)
 		} else {
 			// Leaf nodes are LMSG
 			mh[0] = 'L'
 			// Remap subject if its a shadow subscription, treat like a normal client.
-			if rt.sub.im != nil && rt.sub.im.prefix != "" {
-				mh = append(mh, rt.sub.im.prefix...)
+			if rt.sub.im != nil && rt.sub.im.isMapped() {
+				mh = rt.sub.im.appendSubject(mh, subject)
+			} else {
+				mh = append(mh, subject...)
 			}
 		}
-		mh = append(mh, subject...)
 		mh = append(mh, ' ')
 
 		if len(rt.qs) > 0 {
@@ -3002,7 +3143,9 @@ sendToRoutesOrLeafs:
 		}
 		mh = append(mh, c.pa.szb...)
 		mh = append(mh, _CRLF_...)
-		c.deliverMsg(rt.sub, subject, mh, msg)
+		if c.deliverMsg(rt.sub, subject, mh, msg) && kind == ROUTER {
+			acc.addOutbound(ROUTER, 1, int64(len(msg)-LEN_CR_LF))
+		}
 	}
 	return queues
 }
@@ -3010,6 +3153,7 @@ sendToRoutesOrLeafs:
 func (c *client) pubPermissionViolation(subject []byte) {
 	c.sendErr(fmt.Sprintf("Permissions Violation for Publish to %q", subject))
 	c.Errorf("Publish Violation - %s, Subject %q", c.getAuthUser(), subject)
+	c.permissionViolation(violationPublish, subject)
 }
 
 func (c *client) subPermissionViolation(sub *subscription) {
@@ -3025,11 +3169,84 @@ func (c *client) subPermissionViolation(sub *subscription) {
 
 	c.sendErr(errTxt)
 	c.Errorf(logTxt)
+	c.permissionViolation(violationSubscribe, sub.subject)
 }
 
 func (c *client) replySubjectViolation(reply []byte) {
 	c.sendErr(fmt.Sprintf("Permissions Violation for Publish with Reply of %q", reply))
 	c.Errorf("Publish Violation - %s, Reply %q", c.getAuthUser(), reply)
+	c.permissionViolation(violationReply, reply)
+}
+
+// Types of permissions violations.
+const (
+	violationPublish   = "publish"
+	violationSubscribe = "subscribe"
+	violationReply     = "reply"
+	violationPayload   = "max_payload"
+)
+
+// Returns the permissions violations policy of the client's account,
+// or the server's one if the account does not have one.
+func (c *client) permViolationsPolicy() PermViolationOpts {
+	c.mu.Lock()
+	acc := c.acc
+	c.mu.Unlock()
+	if acc != nil {
+		acc.mu.RLock()
+		pv := acc.pviol
+		acc.mu.RUnlock()
+		if pv != nil {
+			return *pv
+		}
+	}
+	if c.srv == nil {
+		return PermViolationOpts{}
+	}
+	return c.srv.getOpts().PermViolations
+}
+
+// Without a policy, violations never close the connection, so at most
+// one advisory per interval is sent for a client.
+const permViolationEventInterval = time.Second
+
+// permissionViolation records a permissions violation and closes the
+// connection if the maximum number of violations within the window of
+// the policy has been reached.
+func (c *client) permissionViolation(typ string, subject []byte) {
+	pv := c.permViolationsPolicy()
+	now := time.Now()
+	if pv.Max <= 0 {
+		c.mu.Lock()
+		send := now.Sub(c.pvev) >= permViolationEventInterval
+		if send {
+			c.pvev = now
+		}
+		c.mu.Unlock()
+		if send && c.srv != nil {
+			c.srv.sendViolationEvent(c, &ViolationEventMsg{Type: typ, Subject: string(subject)}, 0)
+		}
+		return
+	}
+	c.mu.Lock()
+	if pv.Window > 0 {
+		c.pviol = pruneTimes(c.pviol, now, pv.Window)
+	}
+	c.pviol = append(c.pviol, now)
+	n := len(c.pviol)
+	c.mu.Unlock()
+	var reason ClosedState
+	if n >= pv.Max {
+		reason = MaxPermissionsViolationsExceeded
+	}
+	if c.srv != nil {
+		c.srv.sendViolationEvent(c, &ViolationEventMsg{Type: typ, Subject: string(subject), Violations: n}, reason)
+	}
+	if reason == 0 {
+		return
+	}
+	c.sendErrAndErr(ErrTooManyPermViolations.Error())
+	c.closeConnection(reason)
 }
 
 func (c *client) processPingTimer() {
@@ -3173,6 +3390,10 @@ func (c *client) clearConnection(reason ClosedState) {
 	if c.kind == CLIENT || c.kind == LEAF {
 		go srv.saveClosedClient(c, nc, reason)
 	}
+	// Announce that the route, gateway or leafnode is gone.
+	if c.flags.isSet(linkEventSent) {
+		go srv.remoteDisconnectEvent(c, nc, reason)
+	}
 }
 
 func (c *client) typeString() string {
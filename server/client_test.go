@@ -21,6 +21,7 @@ import (
 	"io"
 	"math"
 	"net"
+	"os"
 	"reflect"
 	"regexp"
 	"strings"
@@ -1632,3 +1633,165 @@ func TestResponsePermissions(t *testing.T) {
 		})
 	}
 }
+
+func TestPermissionViolationsCloseConnection(t *testing.T) {
+	conf := createConfFile(t, []byte(`
+		listen: "127.0.0.1:-1"
+		permission_violations { max: 3, window: "1m" }
+		system_account: SYS
+		accounts {
+			SYS { users [{user: sys, password: pwd}] }
+			A {
+				users [{user: a, password: pwd, permissions: {publish: "foo", subscribe: "foo"}}]
+			}
+			B {
+				permission_violations { max: 1 }
+				users [{user: b, password: pwd, permissions: {publish: "foo"}}]
+			}
+		}
+	`))
+	defer os.Remove(conf)
+	s, opts := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	url := func(user string) string {
+		return fmt.Sprintf("nats://%s:pwd@%s:%d", user, opts.Host, opts.Port)
+	}
+	sys := natsConnect(t, url("sys"))
+	defer sys.Close()
+	advisories := natsSubSync(t, sys, fmt.Sprintf(accViolationEventSubj, "*"))
+	natsFlush(t, sys)
+
+	checkClosed := func(nc *nats.Conn, user string) {
+		t.Helper()
+		checkFor(t, time.Second, 15*time.Millisecond, func() error {
+			if !nc.IsClosed() {
+				return fmt.Errorf("Connection of %q still opened", user)
+			}
+			return nil
+		})
+		c, _ := s.Connz(&ConnzOptions{State: ConnClosed, Username: true})
+		for _, ci := range c.Conns {
+			if ci.AuthorizedUser == user && ci.Reason == MaxPermissionsViolationsExceeded.String() {
+				return
+			}
+		}
+		t.Fatalf("Connection of %q not closed for violations: %+v", user, c.Conns)
+	}
+	checkAdvisory := func(account, typ, subject string, violations int, reason string) {
+		t.Helper()
+		msg := natsNexMsg(t, advisories, time.Second)
+		e := ViolationEventMsg{}
+		if err := json.Unmarshal(msg.Data, &e); err != nil {
+			t.Fatalf("Error unmarshalling advisory: %v", err)
+		}
+		if msg.Subject != fmt.Sprintf(accViolationEventSubj, account) ||
+			e.Client.Account != account || e.Type != typ || e.Subject != subject ||
+			e.Violations != violations || e.Reason != reason {
+			t.Fatalf("Unexpected advisory on %q: %+v", msg.Subject, e)
+		}
+	}
+	closed := MaxPermissionsViolationsExceeded.String()
+
+	// Server policy, violations of any kind are counted.
+	nc := natsConnect(t, url("a"), nats.NoReconnect(), nats.ErrorHandler(func(*nats.Conn, *nats.Subscription, error) {}))
+	defer nc.Close()
+	nc.Publish("bar", []byte("hello"))
+	natsSubSync(t, nc, "bar")
+	nc.Flush()
+	if nc.IsClosed() {
+		t.Fatal("Connection should not be closed yet")
+	}
+	nc.Publish("baz", []byte("hello"))
+	nc.Flush()
+	checkClosed(nc, "a")
+	checkAdvisory("A", violationPublish, "bar", 1, _EMPTY_)
+	checkAdvisory("A", violationSubscribe, "bar", 2, _EMPTY_)
+	checkAdvisory("A", violationPublish, "baz", 3, closed)
+
+	// Account policy overrides the server's one.
+	nc = natsConnect(t, url("b"), nats.NoReconnect(), nats.ErrorHandler(func(*nats.Conn, *nats.Subscription, error) {}))
+	defer nc.Close()
+	nc.Publish("bar", []byte("hello"))
+	nc.Flush()
+	checkClosed(nc, "b")
+	checkAdvisory("B", violationPublish, "bar", 1, closed)
+}
+
+func TestPermissionViolationsWithoutPolicy(t *testing.T) {
+	conf := createConfFile(t, []byte(`
+		listen: "127.0.0.1:-1"
+		system_account: SYS
+		accounts {
+			SYS { users [{user: sys, password: pwd}] }
+			A { users [{user: a, password: pwd, permissions: {publish: "foo"}}] }
+		}
+	`))
+	defer os.Remove(conf)
+	s, opts := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	sys := natsConnect(t, fmt.Sprintf("nats://sys:pwd@%s:%d", opts.Host, opts.Port))
+	defer sys.Close()
+	advisories := natsSubSync(t, sys, fmt.Sprintf(accViolationEventSubj, "*"))
+	natsFlush(t, sys)
+
+	nc := natsConnect(t, fmt.Sprintf("nats://a:pwd@%s:%d", opts.Host, opts.Port),
+		nats.ErrorHandler(func(*nats.Conn, *nats.Subscription, error) {}))
+	defer nc.Close()
+	for i := 0; i < 10; i++ {
+		nc.Publish("bar", []byte("hello"))
+	}
+	nc.Flush()
+
+	// The connection stays opened and repeated violations are only
+	// reported once.
+	msg := natsNexMsg(t, advisories, time.Second)
+	e := ViolationEventMsg{}
+	if err := json.Unmarshal(msg.Data, &e); err != nil {
+		t.Fatalf("Error unmarshalling advisory: %v", err)
+	}
+	if e.Type != violationPublish || e.Subject != "bar" || e.Reason != _EMPTY_ {
+		t.Fatalf("Unexpected advisory: %+v", e)
+	}
+	if msg, err := advisories.NextMsg(100 * time.Millisecond); err == nil {
+		t.Fatalf("Unexpected advisory: %s", msg.Data)
+	}
+	if nc.IsClosed() {
+		t.Fatal("Connection should not be closed")
+	}
+}
+
+func TestPermissionViolationsWindow(t *testing.T) {
+	opts := DefaultOptions()
+	opts.PermViolations = PermViolationOpts{Max: 2, Window: 50 * time.Millisecond}
+	s := RunServer(opts)
+	defer s.Shutdown()
+
+	c, cr, _ := newClientForServer(s)
+	defer c.closeConnection(ClientClosed)
+	go func() {
+		for {
+			if _, err := cr.ReadString('\n'); err != nil {
+				return
+			}
+		}
+	}()
+
+	isClosed := func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.flags.isSet(closeConnection)
+	}
+	c.permissionViolation(violationPublish, []byte("foo"))
+	time.Sleep(75 * time.Millisecond)
+	// The first violation is outside of the window.
+	c.permissionViolation(violationPublish, []byte("foo"))
+	if isClosed() {
+		t.Fatal("Connection should not be closed")
+	}
+	c.permissionViolation(violationPublish, []byte("foo"))
+	if !isClosed() {
+		t.Fatal("Connection should be closed")
+	}
+}
@@ -14,8 +14,13 @@
 package server
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -265,3 +270,126 @@ func TestClosedTLSHandshake(t *testing.T) {
 	}
 	checkReason(t, conns[0].Reason, TLSHandshakeError)
 }
+
+func TestClosedConnsLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "closed")
+	if err != nil {
+		t.Fatalf("Error creating dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	opts := DefaultMonitorOptions()
+	opts.MaxClosedClients = 3
+	opts.ClosedConnsLog = ClosedConnsLogOpts{File: filepath.Join(dir, "closed.log"), MaxSize: 2048, MaxFiles: 3}
+	s := RunServer(opts)
+
+	connect := func(name string) {
+		t.Helper()
+		nc, err := nats.Connect(fmt.Sprintf("nats://%s:%d", opts.Host, opts.Port), nats.Name(name))
+		if err != nil {
+			t.Fatalf("Error on connect: %v", err)
+		}
+		nc.Subscribe("foo", func(*nats.Msg) {})
+		nc.Flush()
+		nc.Close()
+	}
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		connect(fmt.Sprintf("c%d", i))
+	}
+	checkTotalClosedConns(t, s, 10, time.Second)
+	mid := time.Now()
+	for i := 10; i < 15; i++ {
+		connect(fmt.Sprintf("c%d", i))
+	}
+	checkTotalClosedConns(t, s, 15, time.Second)
+	s.Shutdown()
+
+	// Files were rotated.
+	if _, err := os.Stat(opts.ClosedConnsLog.File + ".1"); err != nil {
+		t.Fatalf("Expected log to be rotated: %v", err)
+	}
+	if _, err := os.Stat(opts.ClosedConnsLog.File + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("Expected at most 3 files, got %v", err)
+	}
+
+	// The most recent closed connections are reloaded.
+	s = RunServer(opts)
+	defer s.Shutdown()
+	ccs := s.closedClients()
+	if len(ccs) != 3 {
+		t.Fatalf("Expected 3 closed connections, got %d", len(ccs))
+	}
+	for i, cc := range ccs {
+		if name := fmt.Sprintf("c%d", 12+i); cc.Name != name || cc.Reason != ClientClosed.String() || len(cc.subs) != 1 || cc.subs[0] != "foo" {
+			t.Fatalf("Unexpected closed connection %d: %+v", i, cc)
+		}
+	}
+
+	// History is searched by time range.
+	connz, err := s.Connz(&ConnzOptions{State: ConnClosed, From: mid})
+	if err != nil {
+		t.Fatalf("Error on connz: %v", err)
+	}
+	if connz.Total != 5 {
+		t.Fatalf("Expected 5 connections, got %d", connz.Total)
+	}
+	connz, err = s.Connz(&ConnzOptions{State: ConnClosed, From: start, To: mid, Limit: 2, Offset: 1, Subscriptions: true})
+	if err != nil {
+		t.Fatalf("Error on connz: %v", err)
+	}
+	// Older connections may have been dropped by the rotation.
+	if connz.Total == 0 || connz.Total > 10 || connz.NumConns != 2 || len(connz.Conns[0].Subs) != 1 {
+		t.Fatalf("Unexpected connz: %+v", connz)
+	}
+	for _, ci := range connz.Conns {
+		if ci.Stop.Before(start) || !ci.Stop.Before(mid) {
+			t.Fatalf("Unexpected connection: %+v", ci)
+		}
+	}
+
+	url := fmt.Sprintf("http://127.0.0.1:%d/connz?state=closed&from=%s", s.MonitorAddr().Port, mid.Format(time.RFC3339Nano))
+	c := &Connz{}
+	if err := json.Unmarshal(readBody(t, url), c); err != nil {
+		t.Fatalf("Error unmarshalling: %v", err)
+	}
+	if c.Total != 5 {
+		t.Fatalf("Expected 5 connections, got %d", c.Total)
+	}
+	readBodyEx(t, url[:strings.Index(url, "?")]+"?from=bad", http.StatusBadRequest, textPlain)
+	if _, err := s.Connz(&ConnzOptions{From: mid}); err == nil {
+		t.Fatal("Expected error for time range on open connections")
+	}
+}
+
+func TestClosedConnsLogConfig(t *testing.T) {
+	conf := createConfFile(t, []byte(`
+		closed_connections_log {
+			file: "/tmp/closed.log"
+			max_size: 1MB
+			max_files: 10
+		}
+	`))
+	defer os.Remove(conf)
+	opts, err := ProcessConfigFile(conf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := ClosedConnsLogOpts{File: "/tmp/closed.log", MaxSize: 1024 * 1024, MaxFiles: 10}
+	if opts.ClosedConnsLog != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, opts.ClosedConnsLog)
+	}
+
+	for _, test := range []string{
+		`closed_connections_log: 1`,
+		`closed_connections_log { max_size: 1MB }`,
+		`closed_connections_log { file: "closed.log", max_files: -1 }`,
+		`closed_connections_log { file: "closed.log", unknown: 1 }`,
+	} {
+		conf := createConfFile(t, []byte(test))
+		if _, err := ProcessConfigFile(conf); err == nil {
+			t.Fatalf("Expected error for %q", test)
+		}
+		os.Remove(conf)
+	}
+}
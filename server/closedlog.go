@@ -0,0 +1,219 @@
+// Copyright 2019 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// closedConnsLog is an append-only log of closed connections, one JSON
+// record per line. Once the file reaches its maximum size, it is rotated
+// to file.1, file.1 to file.2 and so on, up to the maximum number of files.
+type closedConnsLog struct {
+	sync.Mutex
+	file     string
+	maxSize  int64
+	maxFiles int
+	f        *os.File
+	size     int64
+}
+
+// newClosedConnsLog opens, or creates, the log of closed connections.
+// It returns nil if no file is configured.
+func newClosedConnsLog(o *ClosedConnsLogOpts) (*closedConnsLog, error) {
+	if o.File == _EMPTY_ {
+		return nil, nil
+	}
+	cl := &closedConnsLog{
+		file:     o.File,
+		maxSize:  o.MaxSize,
+		maxFiles: o.MaxFiles,
+	}
+	if cl.maxSize <= 0 {
+		cl.maxSize = DEFAULT_CLOSED_CONNS_LOG_MAX_SIZE
+	}
+	if cl.maxFiles <= 0 {
+		cl.maxFiles = DEFAULT_CLOSED_CONNS_LOG_MAX_FILES
+	}
+	if err := cl.open(); err != nil {
+		return nil, err
+	}
+	return cl, nil
+}
+
+// Opens the current file for appending.
+// Lock should be held.
+func (cl *closedConnsLog) open() error {
+	f, err := os.OpenFile(cl.file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return fmt.Errorf("error opening closed connections log: %v", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("error opening closed connections log: %v", err)
+	}
+	cl.f, cl.size = f, fi.Size()
+	return nil
+}
+
+// Returns the name of the file with the given index, 0 being the current
+// file and higher indexes older files.
+func (cl *closedConnsLog) fileName(i int) string {
+	if i == 0 {
+		return cl.file
+	}
+	return fmt.Sprintf("%s.%d", cl.file, i)
+}
+
+// Rotates the files, dropping the oldest one.
+// Lock should be held.
+func (cl *closedConnsLog) rotate() error {
+	cl.f.Close()
+	cl.f = nil
+	os.Remove(cl.fileName(cl.maxFiles - 1))
+	for i := cl.maxFiles - 2; i >= 0; i-- {
+		if err := os.Rename(cl.fileName(i), cl.fileName(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return cl.open()
+}
+
+// Returns the record persisted for a closed connection, the optional
+// items being stored in their ConnInfo fields.
+func (cc *closedClient) record() *ConnInfo {
+	ci := cc.ConnInfo
+	ci.Subs = cc.subs
+	ci.AuthorizedUser = cc.user
+	ci.Account = cc.acc
+	return &ci
+}
+
+// Returns the closed connection of a persisted record.
+func closedClientFromRecord(ci *ConnInfo) *closedClient {
+	cc := &closedClient{ConnInfo: *ci, subs: ci.Subs, user: ci.AuthorizedUser, acc: ci.Account}
+	cc.Subs, cc.AuthorizedUser, cc.Account = nil, _EMPTY_, _EMPTY_
+	return cc
+}
+
+// append writes the closed connection to the log, rotating the files
+// if needed.
+func (cl *closedConnsLog) append(cc *closedClient) error {
+	b, err := json.Marshal(cc.record())
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	cl.Lock()
+	defer cl.Unlock()
+	if cl.f == nil {
+		return nil
+	}
+	if cl.size > 0 && cl.size+int64(len(b)) > cl.maxSize {
+		if err := cl.rotate(); err != nil {
+			return fmt.Errorf("error rotating closed connections log: %v", err)
+		}
+	}
+	n, err := cl.f.Write(b)
+	cl.size += int64(n)
+	return err
+}
+
+// read calls fn for each closed connection of the log, from the oldest
+// to the most recent one, until fn returns false. Records that can not be
+// decoded, like a last one partially written before a crash, are skipped.
+func (cl *closedConnsLog) read(fn func(cc *closedClient) bool) error {
+	cl.Lock()
+	defer cl.Unlock()
+	for i := cl.maxFiles - 1; i >= 0; i-- {
+		f, err := os.Open(cl.fileName(i))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, int(cl.maxSize)+1)
+		for scanner.Scan() {
+			ci := &ConnInfo{}
+			if json.Unmarshal(scanner.Bytes(), ci) != nil {
+				continue
+			}
+			if !fn(closedClientFromRecord(ci)) {
+				f.Close()
+				return nil
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load fills the ring buffer with the most recent closed connections of
+// the log.
+func (cl *closedConnsLog) load(rb *closedRingBuffer) error {
+	return cl.read(func(cc *closedClient) bool {
+		rb.append(cc)
+		return true
+	})
+}
+
+// closedClientsInRange returns the closed connections of the log that
+// were closed within the given time range. A zero time means no bound.
+func (cl *closedConnsLog) closedClientsInRange(from, to time.Time) ([]*closedClient, error) {
+	var ccs []*closedClient
+	err := cl.read(func(cc *closedClient) bool {
+		if stoppedInRange(&cc.ConnInfo, from, to) {
+			ccs = append(ccs, cc)
+		}
+		return true
+	})
+	return ccs, err
+}
+
+// Returns true if the connection was closed within the given time range,
+// the upper bound being exclusive. A zero time means no bound.
+func stoppedInRange(ci *ConnInfo, from, to time.Time) bool {
+	if ci.Stop == nil {
+		return false
+	}
+	if !from.IsZero() && ci.Stop.Before(from) {
+		return false
+	}
+	if !to.IsZero() && !ci.Stop.Before(to) {
+		return false
+	}
+	return true
+}
+
+// close closes the log, records appended afterwards are dropped.
+func (cl *closedConnsLog) close() {
+	cl.Lock()
+	if cl.f != nil {
+		cl.f.Close()
+		cl.f = nil
+	}
+	cl.Unlock()
+}
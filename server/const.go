@@ -164,6 +164,21 @@ const (
 	// The default is to report every attempt.
 	DEFAULT_RECONNECT_ERROR_REPORTS = 1
 
+	// DEFAULT_AUTH_FAILURE_WINDOW is the default sliding window over which
+	// authentication failures are counted.
+	DEFAULT_AUTH_FAILURE_WINDOW = time.Minute
+
+	// DEFAULT_AUTH_FAILURE_MAX_DELAY is the default maximum delay applied
+	// before reporting an authentication failure.
+	DEFAULT_AUTH_FAILURE_MAX_DELAY = 5 * time.Second
+
+	// DEFAULT_AUTH_BAN_TIME is the default duration of a first ban after
+	// too many authentication failures.
+	DEFAULT_AUTH_BAN_TIME = 5 * time.Minute
+
+	// DEFAULT_AUTH_MAX_BAN_TIME is the default maximum duration of a ban.
+	DEFAULT_AUTH_MAX_BAN_TIME = time.Hour
+
 	// DEFAULT_RTT_MEASUREMENT_INTERVAL is how often we want to measure RTT from
 	// this server to clients, routes, gateways or leafnode connections.
 	DEFAULT_RTT_MEASUREMENT_INTERVAL = time.Hour
@@ -179,4 +194,24 @@ const (
 	// DEFAULT_SERVICE_LATENCY_SAMPLING is the default sampling rate for service
 	// latency metrics
 	DEFAULT_SERVICE_LATENCY_SAMPLING = 100
+
+	// DEFAULT_HOT_SUBJECTS_SIZE is the default number of subjects tracked
+	// when hot subjects tracking is enabled.
+	DEFAULT_HOT_SUBJECTS_SIZE = 100
+
+	// DEFAULT_HOT_SUBJECTS_SAMPLING is the default sampling rate of hot
+	// subjects, one message out of this number is recorded.
+	DEFAULT_HOT_SUBJECTS_SAMPLING = 10
+
+	// DEFAULT_HOT_SUBJECTS_WINDOW is the default time window over which
+	// hot subjects rates are computed.
+	DEFAULT_HOT_SUBJECTS_WINDOW = time.Minute
+
+	// DEFAULT_CLOSED_CONNS_LOG_MAX_SIZE is the default size at which the
+	// closed connections log is rotated.
+	DEFAULT_CLOSED_CONNS_LOG_MAX_SIZE = 10 * 1024 * 1024
+
+	// DEFAULT_CLOSED_CONNS_LOG_MAX_FILES is the default number of files,
+	// including the current one, kept by the closed connections log.
+	DEFAULT_CLOSED_CONNS_LOG_MAX_FILES = 5
 )
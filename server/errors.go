@@ -16,6 +16,7 @@ package server
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 var (
@@ -54,6 +55,22 @@ var (
 	// connections.
 	ErrTooManyAccountConnections = errors.New("maximum account active connections exceeded")
 
+	// ErrTooManyConnectionsPerIP signals a client that the maximum number of connections
+	// allowed from a single source IP address has been reached.
+	ErrTooManyConnectionsPerIP = errors.New("maximum connections per source address exceeded")
+
+	// ErrSourceAddressNotAllowed signals a client that it is not allowed to connect
+	// from its source address.
+	ErrSourceAddressNotAllowed = errors.New("source address not allowed")
+
+	// ErrAuthenticationBanned signals a client that it is temporarily not allowed to
+	// connect because of repeated authentication failures.
+	ErrAuthenticationBanned = errors.New("temporarily banned after repeated authentication failures")
+
+	// ErrTooManyPermViolations signals a client that it has been disconnected because
+	// of repeated permissions violations.
+	ErrTooManyPermViolations = errors.New("maximum permissions violations exceeded")
+
 	// ErrTooManySubs signals a client that the maximum number of subscriptions per connection
 	// has been reached.
 	ErrTooManySubs = errors.New("maximum subscriptions exceeded")
@@ -109,6 +126,10 @@ var (
 	// ErrServiceImportAuthorization is returned when a service import is not authorized.
 	ErrServiceImportAuthorization = errors.New("service import not authorized")
 
+	// ErrBadSubjectTransform is returned when an import destination template is not valid
+	// for its source subject.
+	ErrBadSubjectTransform = errors.New("invalid subject transform")
+
 	// ErrClientOrRouteConnectedToGatewayPort represents an error condition when
 	// a client or route attempted to connect to the Gateway port.
 	ErrClientOrRouteConnectedToGatewayPort = errors.New("attempted to connect to gateway port")
@@ -143,11 +164,24 @@ func (e *configErr) Source() string {
 // Error reports the location and reason from a configuration error.
 func (e *configErr) Error() string {
 	if e.token != nil {
-		return fmt.Sprintf("%s: %s", e.Source(), e.reason)
+		return fmt.Sprintf("%s: %s", e.Source(), e.redactedReason())
 	}
 	return e.reason
 }
 
+// redactedReason returns the reason without the value of the token when
+// it was read from a secret file.
+func (e *configErr) redactedReason() string {
+	if e.token == nil || !e.token.IsSecret() {
+		return e.reason
+	}
+	secret, ok := e.token.Value().(string)
+	if !ok || secret == _EMPTY_ {
+		return e.reason
+	}
+	return strings.Replace(e.reason, secret, "[REDACTED]", -1)
+}
+
 // unknownConfigFieldErr is an error reported in pedantic mode.
 type unknownConfigFieldErr struct {
 	configErr
@@ -167,7 +201,7 @@ type configWarningErr struct {
 
 // Error reports a configuration warning.
 func (e *configWarningErr) Error() string {
-	return fmt.Sprintf("%s: invalid use of field %q: %s", e.Source(), e.field, e.reason)
+	return fmt.Sprintf("%s: invalid use of field %q: %s", e.Source(), e.field, e.redactedReason())
 }
 
 // processConfigErr is the result of processing the configuration from the server.
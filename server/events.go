@@ -16,9 +16,12 @@ package server
 import (
 	"bytes"
 	"crypto/sha256"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"net"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync"
@@ -29,20 +32,30 @@ import (
 )
 
 const (
-	connectEventSubj         = "$SYS.ACCOUNT.%s.CONNECT"
-	disconnectEventSubj      = "$SYS.ACCOUNT.%s.DISCONNECT"
-	accConnsReqSubj          = "$SYS.REQ.ACCOUNT.%s.CONNS"
-	accUpdateEventSubj       = "$SYS.ACCOUNT.%s.CLAIMS.UPDATE"
-	connsRespSubj            = "$SYS._INBOX_.%s"
-	accConnsEventSubj        = "$SYS.SERVER.ACCOUNT.%s.CONNS"
-	shutdownEventSubj        = "$SYS.SERVER.%s.SHUTDOWN"
-	authErrorEventSubj       = "$SYS.SERVER.%s.CLIENT.AUTH.ERR"
-	serverStatsSubj          = "$SYS.SERVER.%s.STATSZ"
-	serverStatsReqSubj       = "$SYS.REQ.SERVER.%s.STATSZ"
-	serverStatsPingReqSubj   = "$SYS.REQ.SERVER.PING"
-	leafNodeConnectEventSubj = "$SYS.ACCOUNT.%s.LEAFNODE.CONNECT"
-	remoteLatencyEventSubj   = "$SYS.LATENCY.M2.%s"
-	inboxRespSubj            = "$SYS._INBOX.%s.%s"
+	connectEventSubj          = "$SYS.ACCOUNT.%s.CONNECT"
+	disconnectEventSubj       = "$SYS.ACCOUNT.%s.DISCONNECT"
+	accConnsReqSubj           = "$SYS.REQ.ACCOUNT.%s.CONNS"
+	accUpdateEventSubj        = "$SYS.ACCOUNT.%s.CLAIMS.UPDATE"
+	connsRespSubj             = "$SYS._INBOX_.%s"
+	accConnsEventSubj         = "$SYS.SERVER.ACCOUNT.%s.CONNS"
+	accStatsEventSubj         = "$SYS.ACCOUNT.%s.STATSZ"
+	shutdownEventSubj         = "$SYS.SERVER.%s.SHUTDOWN"
+	authErrorEventSubj        = "$SYS.SERVER.%s.CLIENT.AUTH.ERR"
+	authBanEventSubj          = "$SYS.SERVER.%s.CLIENT.AUTH.BAN"
+	accViolationEventSubj     = "$SYS.ACCOUNT.%s.VIOLATION"
+	accSlowConsumerEventSubj  = "$SYS.ACCOUNT.%s.SLOW_CONSUMER"
+	serverBanzReqSubj         = "$SYS.REQ.SERVER.%s.BANZ"
+	serverStatsSubj           = "$SYS.SERVER.%s.STATSZ"
+	serverStatsReqSubj        = "$SYS.REQ.SERVER.%s.STATSZ"
+	serverStatsPingReqSubj    = "$SYS.REQ.SERVER.PING"
+	serverDirectReqSubj       = "$SYS.REQ.SERVER.%s.%s"
+	serverPingReqSubj         = "$SYS.REQ.SERVER.PING.%s"
+	leafNodeConnectEventSubj  = "$SYS.ACCOUNT.%s.LEAFNODE.CONNECT"
+	remoteLatencyEventSubj    = "$SYS.LATENCY.M2.%s"
+	remoteConnectEventSubj    = "$SYS.SERVER.%s.%s.CONNECT"
+	remoteDisconnectEventSubj = "$SYS.SERVER.%s.%s.DISCONNECT"
+	latencyStatsSubj          = "%s.STATS"
+	inboxRespSubj             = "$SYS._INBOX.%s.%s"
 
 	// FIXME(dlc) - Should account scope, even with wc for now, but later on
 	// we can then shard as needed.
@@ -69,6 +82,7 @@ type internal struct {
 	servers  map[string]*serverUpdate
 	sweeper  *time.Timer
 	stmr     *time.Timer
+	lstmr    *time.Timer
 	subs     map[string]msgHandler
 	replies  map[string]msgHandler
 	sendq    chan *pubMsg
@@ -86,20 +100,94 @@ type ServerStatsMsg struct {
 	Stats  ServerStats `json:"statsz"`
 }
 
+// AccountStatsMsg is sent periodically with the traffic statistics of an
+// account on the server.
+type AccountStatsMsg struct {
+	Server    ServerInfo          `json:"server"`
+	Account   string              `json:"acc"`
+	Conns     int                 `json:"conns"`
+	LeafNodes int                 `json:"leafnodes"`
+	Stats     AccountTrafficStats `json:"stats"`
+}
+
 // ConnectEventMsg is sent when a new connection is made that is part of an account.
 type ConnectEventMsg struct {
-	Server ServerInfo `json:"server"`
-	Client ClientInfo `json:"client"`
+	Server       ServerInfo           `json:"server"`
+	Client       ClientInfo           `json:"client"`
+	AccountStats *AccountTrafficStats `json:"account_stats,omitempty"`
 }
 
 // DisconnectEventMsg is sent when a new connection previously defined from a
 // ConnectEventMsg is closed.
 type DisconnectEventMsg struct {
+	Server       ServerInfo           `json:"server"`
+	Client       ClientInfo           `json:"client"`
+	Sent         DataStats            `json:"sent"`
+	Received     DataStats            `json:"received"`
+	Reason       string               `json:"reason"`
+	AccountStats *AccountTrafficStats `json:"account_stats,omitempty"`
+}
+
+// AuthBanEventMsg is sent when a source address or a user is temporarily
+// banned because of repeated authentication failures.
+type AuthBanEventMsg struct {
 	Server   ServerInfo `json:"server"`
-	Client   ClientInfo `json:"client"`
-	Sent     DataStats  `json:"sent"`
-	Received DataStats  `json:"received"`
-	Reason   string     `json:"reason"`
+	Type     string     `json:"type"`
+	Name     string     `json:"name"`
+	Failures int        `json:"failures"`
+	Bans     int        `json:"bans"`
+	Start    time.Time  `json:"start"`
+	Expires  time.Time  `json:"expires"`
+}
+
+// ViolationEventMsg is sent when a connection violates its permissions
+// or the maximum payload. Violations is the number of violations counted
+// by the permissions violations policy, if any. The reason is set if the
+// connection has been closed because of it.
+type ViolationEventMsg struct {
+	Server     ServerInfo `json:"server"`
+	Client     ClientInfo `json:"client"`
+	Type       string     `json:"type"`
+	Subject    string     `json:"subject"`
+	Violations int        `json:"violations"`
+	Size       int        `json:"size,omitempty"`
+	MaxPayload int        `json:"max_payload,omitempty"`
+	Reason     string     `json:"reason,omitempty"`
+}
+
+// SlowConsumerEventMsg is sent when a connection is closed because it is
+// a slow consumer. The subject is the one of the message that could not
+// be queued, if known.
+type SlowConsumerEventMsg struct {
+	Server  ServerInfo `json:"server"`
+	Client  ClientInfo `json:"client"`
+	Subject string     `json:"subject,omitempty"`
+	Pending int64      `json:"pending_bytes"`
+	Reason  string     `json:"reason"`
+}
+
+// RemoteEventMsg is sent when a route, gateway or leafnode connection is
+// established or closed. The kind is one of ROUTE, GATEWAY or LEAFNODE.
+// Stop, duration, data stats and reason are only set on disconnect.
+type RemoteEventMsg struct {
+	Server     ServerInfo `json:"server"`
+	Kind       string     `json:"kind"`
+	CID        uint64     `json:"cid"`
+	RemoteID   string     `json:"remote_id"`
+	Name       string     `json:"name,omitempty"`
+	URL        string     `json:"url,omitempty"`
+	IP         string     `json:"ip"`
+	Port       int        `json:"port"`
+	Outbound   bool       `json:"outbound"`
+	Account    string     `json:"account,omitempty"`
+	TLSVersion string     `json:"tls_version,omitempty"`
+	TLSCipher  string     `json:"tls_cipher_suite,omitempty"`
+	Start      time.Time  `json:"start"`
+	Stop       *time.Time `json:"stop,omitempty"`
+	Duration   string     `json:"duration,omitempty"`
+	Sent       *DataStats `json:"sent,omitempty"`
+	Received   *DataStats `json:"received,omitempty"`
+	Reason     string     `json:"reason,omitempty"`
 }
 
 // AccountNumConns is an event that will be sent from a server that is tracking
@@ -470,6 +558,34 @@ func (s *Server) heartbeatStatsz() {
 		s.sys.stmr.Reset(s.sys.statsz)
 	}
 	s.sendStatsz(fmt.Sprintf(serverStatsSubj, s.info.ID))
+	s.sendAccountsStatsz()
+}
+
+// sendAccountsStatsz sends the traffic statistics of the accounts that
+// have local connections, or had some traffic since the last update.
+// Lock should be held.
+func (s *Server) sendAccountsStatsz() {
+	s.accounts.Range(func(k, v interface{}) bool {
+		acc := v.(*Account)
+		if acc == s.gacc {
+			return true
+		}
+		stats := acc.TrafficStats()
+		acc.mu.Lock()
+		m := AccountStatsMsg{
+			Account:   acc.Name,
+			Conns:     acc.numLocalConnections(),
+			LeafNodes: acc.numLocalLeafNodes(),
+			Stats:     *stats,
+		}
+		changed := stats.Total != acc.lastStats
+		acc.lastStats = stats.Total
+		acc.mu.Unlock()
+		if m.Conns+m.LeafNodes > 0 || changed {
+			s.sendInternalMsg(fmt.Sprintf(accStatsEventSubj, m.Account), _EMPTY_, &m.Server, &m)
+		}
+		return true
+	})
 }
 
 // This should be wrapChk() to setup common locking.
@@ -477,6 +593,56 @@ func (s *Server) startStatszTimer() {
 	s.sys.stmr = time.AfterFunc(s.sys.statsz, s.wrapChk(s.heartbeatStatsz))
 }
 
+// Start a timer that will fire periodically to publish the aggregated
+// latencies of the tracked services, if configured.
+// This should be wrapChk() to setup common locking.
+func (s *Server) startLatencyStatsTimer() {
+	if interval := s.getOpts().LatencyStats.Interval; interval > 0 {
+		s.sys.lstmr = time.AfterFunc(interval, s.wrapChk(s.heartbeatLatencyStats))
+	}
+}
+
+// Restart the timer publishing the aggregated latencies, when the
+// interval is changed on reload.
+// This should be wrapChk() to setup common locking.
+func (s *Server) restartLatencyStatsTimer() {
+	clearTimer(&s.sys.lstmr)
+	s.startLatencyStatsTimer()
+}
+
+// Send out the latencies aggregated since the last interval on the
+// results subjects of the tracked services suffixed with ".STATS".
+// This should be wrapChk() to setup common locking.
+func (s *Server) heartbeatLatencyStats() {
+	if s.sys.lstmr != nil {
+		s.sys.lstmr.Reset(s.getOpts().LatencyStats.Interval)
+	}
+	now := time.Now()
+	var pms []*pubMsg
+	s.accounts.Range(func(k, v interface{}) bool {
+		acc := v.(*Account)
+		acc.mu.RLock()
+		for results, ls := range acc.lstats {
+			if st := ls.intervalStats(now); st != nil {
+				st.Server = &ServerInfo{}
+				pms = append(pms, &pubMsg{acc, fmt.Sprintf(latencyStatsSubj, results), _EMPTY_, st.Server, st, false})
+			}
+		}
+		acc.mu.RUnlock()
+		return true
+	})
+	if len(pms) == 0 {
+		return
+	}
+	sendq := s.sys.sendq
+	// Don't hold lock while placing on the channel.
+	s.mu.Unlock()
+	for _, pm := range pms {
+		sendq <- pm
+	}
+	s.mu.Lock()
+}
+
 // Start a ticker that will fire periodically and check for orphaned servers.
 // This should be wrapChk() to setup common locking.
 func (s *Server) startRemoteServerSweepTimer() {
@@ -546,6 +712,68 @@ func (s *Server) initEventTracking() {
 	if _, err := s.sysSubscribe(serverStatsPingReqSubj, s.statszReq); err != nil {
 		s.Errorf("Error setting up internal tracking: %v", err)
 	}
+	// Listen for requests for our authentication bans.
+	subject = fmt.Sprintf(serverBanzReqSubj, s.info.ID)
+	if _, err := s.sysSubscribe(subject, s.banzReq); err != nil {
+		s.Errorf("Error setting up internal tracking: %v", err)
+	}
+	// Listen for requests for our monitoring endpoints, directed at us
+	// or sent to all servers.
+	monSrvc := map[string]func(msg []byte) (interface{}, error){
+		"VARZ": func(msg []byte) (interface{}, error) {
+			opts := &VarzOptions{}
+			if err := unmarshalMonitorOpts(msg, opts); err != nil {
+				return nil, err
+			}
+			return s.Varz(opts)
+		},
+		"CONNZ": func(msg []byte) (interface{}, error) {
+			opts := &ConnzOptions{}
+			if err := unmarshalMonitorOpts(msg, opts); err != nil {
+				return nil, err
+			}
+			return s.Connz(opts)
+		},
+		"ROUTEZ": func(msg []byte) (interface{}, error) {
+			opts := &RoutezOptions{}
+			if err := unmarshalMonitorOpts(msg, opts); err != nil {
+				return nil, err
+			}
+			return s.Routez(opts)
+		},
+		"GATEWAYZ": func(msg []byte) (interface{}, error) {
+			opts := &GatewayzOptions{}
+			if err := unmarshalMonitorOpts(msg, opts); err != nil {
+				return nil, err
+			}
+			return s.Gatewayz(opts)
+		},
+		"LEAFZ": func(msg []byte) (interface{}, error) {
+			opts := &LeafzOptions{}
+			if err := unmarshalMonitorOpts(msg, opts); err != nil {
+				return nil, err
+			}
+			return s.Leafz(opts)
+		},
+		"SUBSZ": func(msg []byte) (interface{}, error) {
+			opts := &SubszOptions{}
+			if err := unmarshalMonitorOpts(msg, opts); err != nil {
+				return nil, err
+			}
+			return s.Subsz(opts)
+		},
+	}
+	for name, req := range monSrvc {
+		cb := s.monitorReq(name, req)
+		for _, subject := range []string{
+			fmt.Sprintf(serverDirectReqSubj, s.info.ID, name),
+			fmt.Sprintf(serverPingReqSubj, name),
+		} {
+			if _, err := s.sysSubscribe(subject, cb); err != nil {
+				s.Errorf("Error setting up internal tracking: %v", err)
+			}
+		}
+	}
 	// Listen for updates when leaf nodes connect for a given account. This will
 	// force any gateway connections to move to `modeInterestOnly`
 	subject = fmt.Sprintf(leafNodeConnectEventSubj, "*")
@@ -642,6 +870,7 @@ func (s *Server) shutdownEventing() {
 	s.mu.Lock()
 	clearTimer(&s.sys.sweeper)
 	clearTimer(&s.sys.stmr)
+	clearTimer(&s.sys.lstmr)
 	s.mu.Unlock()
 
 	// We will queue up a shutdown event and wait for the
@@ -714,6 +943,56 @@ func (s *Server) statszReq(sub *subscription, _ *client, subject, reply string,
 	s.sendStatsz(reply)
 }
 
+// banzReq is a request for the authentication bans of this server. The
+// request can hold BanzOptions to clear bans.
+func (s *Server) banzReq(sub *subscription, _ *client, subject, reply string, msg []byte) {
+	if !s.eventsRunning() || reply == _EMPTY_ {
+		return
+	}
+	opts := &BanzOptions{}
+	if len(msg) > 0 {
+		if err := json.Unmarshal(msg, opts); err != nil {
+			s.sys.client.Errorf("Error unmarshalling banz request message: %v", err)
+			return
+		}
+	}
+	banz, _ := s.Banz(opts)
+	s.sendInternalMsgLocked(reply, _EMPTY_, nil, banz)
+}
+
+// ServerAPIError is sent in response to a monitoring request that could
+// not be processed.
+type ServerAPIError struct {
+	Server string `json:"server_id"`
+	Error  string `json:"error"`
+}
+
+// unmarshalMonitorOpts decodes the options of a monitoring request, an
+// empty request meaning the default options.
+func unmarshalMonitorOpts(msg []byte, opts interface{}) error {
+	if len(msg) == 0 {
+		return nil
+	}
+	return json.Unmarshal(msg, opts)
+}
+
+// monitorReq returns the handler of requests for a monitoring endpoint.
+// The request holds the options of the endpoint, and the response is
+// the same as the one of the HTTP endpoint.
+func (s *Server) monitorReq(name string, req func(msg []byte) (interface{}, error)) msgHandler {
+	return func(sub *subscription, _ *client, subject, reply string, msg []byte) {
+		if !s.eventsRunning() || reply == _EMPTY_ {
+			return
+		}
+		resp, err := req(msg)
+		if err != nil {
+			s.Debugf("Error processing %s request: %v", strings.ToLower(name), err)
+			resp = &ServerAPIError{Server: s.ID(), Error: err.Error()}
+		}
+		s.sendInternalMsgLocked(reply, _EMPTY_, nil, resp)
+	}
+}
+
 // remoteConnsUpdate gets called when we receive a remote update from another server.
 func (s *Server) remoteConnsUpdate(sub *subscription, _ *client, subject, reply string, msg []byte) {
 	if !s.eventsRunning() {
@@ -861,6 +1140,7 @@ func (s *Server) accountConnectEvent(c *client) {
 			Lang:    c.opts.Lang,
 			Version: c.opts.Version,
 		},
+		AccountStats: c.acc.TrafficStats(),
 	}
 	c.mu.Unlock()
 
@@ -908,7 +1188,8 @@ func (s *Server) accountDisconnectEvent(c *client, now time.Time, reason string)
 			Msgs:  c.outMsgs,
 			Bytes: c.outBytes,
 		},
-		Reason: reason,
+		Reason:       reason,
+		AccountStats: c.acc.TrafficStats(),
 	}
 	c.mu.Unlock()
 
@@ -956,6 +1237,110 @@ func (s *Server) sendAuthErrorEvent(c *client) {
 	s.mu.Unlock()
 }
 
+// sendAuthBanEvent will send an advisory that a source address or a user
+// has been banned because of repeated authentication failures.
+func (s *Server) sendAuthBanEvent(m *AuthBanEventMsg) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.eventsEnabled() {
+		return
+	}
+	subj := fmt.Sprintf(authBanEventSubj, s.info.ID)
+	s.sendInternalMsg(subj, _EMPTY_, &m.Server, m)
+}
+
+// Returns the information of the client for advisories.
+// Lock should be held.
+func (c *client) advisoryClientInfo() ClientInfo {
+	return ClientInfo{
+		Start:   c.start,
+		Host:    c.host,
+		ID:      c.cid,
+		Account: accForClient(c),
+		User:    nameForClient(c),
+		Name:    c.opts.Name,
+		Lang:    c.opts.Lang,
+		Version: c.opts.Version,
+		RTT:     c.getRTT(),
+	}
+}
+
+// sendViolationEvent will send an advisory for a permissions or maximum
+// payload violation of the client. The advisory is published in the
+// system account, and imported by the client's account.
+func (s *Server) sendViolationEvent(c *client, m *ViolationEventMsg, reason ClosedState) {
+	s.mu.Lock()
+	if !s.eventsEnabled() {
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+	c.mu.Lock()
+	if c.acc == nil {
+		c.mu.Unlock()
+		return
+	}
+	m.Client = c.advisoryClientInfo()
+	if reason > 0 {
+		m.Reason = reason.String()
+	}
+	c.mu.Unlock()
+
+	s.mu.Lock()
+	subj := fmt.Sprintf(accViolationEventSubj, m.Client.Account)
+	s.sendInternalMsg(subj, _EMPTY_, &m.Server, m)
+	s.mu.Unlock()
+}
+
+// slowConsumerEvent will send an advisory that the client is closed
+// because it is a slow consumer. Since this is detected with the client's
+// lock held, the advisory is sent from a go routine.
+// Lock should be held.
+func (c *client) slowConsumerEvent(subject []byte, pending int64, reason ClosedState) {
+	if c.srv == nil || c.acc == nil || (c.kind != CLIENT && c.kind != LEAF) {
+		return
+	}
+	m := &SlowConsumerEventMsg{
+		Client:  c.advisoryClientInfo(),
+		Subject: string(subject),
+		Pending: pending,
+		Reason:  reason.String(),
+	}
+	go c.srv.sendSlowConsumerEvent(m)
+}
+
+func (s *Server) sendSlowConsumerEvent(m *SlowConsumerEventMsg) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.eventsEnabled() {
+		return
+	}
+	subj := fmt.Sprintf(accSlowConsumerEventSubj, m.Client.Account)
+	s.sendInternalMsg(subj, _EMPTY_, &m.Server, m)
+}
+
+// Subjects of the advisories of an account, imported by the account from
+// the system account.
+var accAdvisorySubjects = []string{accViolationEventSubj, accSlowConsumerEventSubj}
+
+// addSystemImports adds the imports of the account's own advisories from
+// the system account, so that they can be subscribed to from within the
+// account.
+func (a *Account) addSystemImports(sacc *Account) {
+	if sacc == nil || a.Name == sacc.Name {
+		return
+	}
+	a.mu.Lock()
+	if a.imports.streams == nil {
+		a.imports.streams = make(map[string]*streamImport)
+	}
+	for _, subj := range accAdvisorySubjects {
+		from := fmt.Sprintf(subj, a.Name)
+		a.imports.streams[from] = &streamImport{acc: sacc, from: from, system: true}
+	}
+	a.mu.Unlock()
+}
+
 // Internal message callback. If the msg is needed past the callback it is
 // required to be copied.
 type msgHandler func(sub *subscription, client *client, subject, reply string, msg []byte)
@@ -1078,7 +1463,7 @@ func (s *Server) remoteLatencyUpdate(sub *subscription, _ *client, subject, _ st
 	// Make sure we remove the entry here.
 	si.acc.removeServiceImport(si.from)
 	// Send the metrics
-	s.sendInternalAccountMsg(acc, lsub, &m1)
+	acc.reportLatency(lsub, m1)
 }
 
 // This is used for all inbox replies so that we do not send supercluster wide interest
@@ -1329,3 +1714,115 @@ func (s *Server) wrapChk(f func()) func() {
 		s.mu.Unlock()
 	}
 }
+
+// Returns the kind of the route, gateway or leafnode connection as used
+// in the subjects of their events.
+func linkKindString(kind int) string {
+	switch kind {
+	case ROUTER:
+		return "ROUTE"
+	case GATEWAY:
+		return "GATEWAY"
+	case LEAF:
+		return "LEAFNODE"
+	}
+	return _EMPTY_
+}
+
+// Returns the URL without its user information, since it may contain
+// credentials.
+func linkURLString(u *url.URL) string {
+	if u == nil {
+		return _EMPTY_
+	}
+	return fmt.Sprintf("%s://%s", u.Scheme, u.Host)
+}
+
+// Returns the event of the route, gateway or leafnode connection.
+// Lock should be held.
+func (c *client) remoteEventMsg(nc net.Conn) *RemoteEventMsg {
+	m := &RemoteEventMsg{
+		Kind:  linkKindString(c.kind),
+		CID:   c.cid,
+		IP:    c.host,
+		Port:  int(c.port),
+		Start: c.start,
+	}
+	switch c.kind {
+	case ROUTER:
+		m.RemoteID = c.route.remoteID
+		m.Outbound = c.route.didSolicit
+		m.URL = linkURLString(c.route.url)
+	case GATEWAY:
+		m.RemoteID = c.opts.Name
+		m.Name = c.gw.name
+		m.Outbound = c.gw.outbound
+		m.URL = c.gw.remoteURL
+	case LEAF:
+		m.RemoteID = c.leaf.remoteID
+		if c.acc != nil {
+			m.Account = c.acc.Name
+		}
+		if c.leaf.remote != nil {
+			m.Outbound = true
+			m.URL = linkURLString(c.leaf.remote.getCurrentURL())
+		}
+	}
+	if conn, ok := nc.(*tls.Conn); ok {
+		cs := conn.ConnectionState()
+		m.TLSVersion = tlsVersion(cs.Version)
+		m.TLSCipher = tlsCipher(cs.CipherSuite)
+	}
+	return m
+}
+
+// remoteConnectEvent will send an event that the route, gateway or
+// leafnode connection is established.
+func (s *Server) remoteConnectEvent(c *client) {
+	s.mu.Lock()
+	if !s.eventsEnabled() {
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+
+	c.mu.Lock()
+	if c.nc == nil || c.flags.isSet(linkEventSent) {
+		c.mu.Unlock()
+		return
+	}
+	c.flags.set(linkEventSent)
+	m := c.remoteEventMsg(c.nc)
+	c.mu.Unlock()
+
+	s.mu.Lock()
+	subj := fmt.Sprintf(remoteConnectEventSubj, s.info.ID, m.Kind)
+	s.sendInternalMsg(subj, _EMPTY_, &m.Server, m)
+	s.mu.Unlock()
+}
+
+// remoteDisconnectEvent will send an event that the route, gateway or
+// leafnode connection, whose connect event was sent, is closed.
+func (s *Server) remoteDisconnectEvent(c *client, nc net.Conn, reason ClosedState) {
+	s.mu.Lock()
+	if !s.eventsEnabled() {
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+
+	now := time.Now()
+	c.mu.Lock()
+	m := c.remoteEventMsg(nc)
+	m.Stop = &now
+	m.Duration = myUptime(now.Sub(c.start))
+	m.Sent = &DataStats{Msgs: atomic.LoadInt64(&c.inMsgs), Bytes: atomic.LoadInt64(&c.inBytes)}
+	m.Received = &DataStats{Msgs: c.outMsgs, Bytes: c.outBytes}
+	m.Reason = reason.String()
+	c.mu.Unlock()
+
+	s.mu.Lock()
+	subj := fmt.Sprintf(remoteDisconnectEventSubj, s.info.ID, m.Kind)
+	s.sendInternalMsg(subj, _EMPTY_, &m.Server, m)
+	s.mu.Unlock()
+}
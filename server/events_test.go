@@ -17,9 +17,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -1175,7 +1178,7 @@ func TestSystemAccountWithGateways(t *testing.T) {
 
 	// If this tests fails with wrong number after 10 seconds we may have
 	// added a new inititial subscription for the eventing system.
-	checkExpectedSubs(t, 13, sa)
+	checkExpectedSubs(t, 26, sa)
 
 	// Create a client on B and see if we receive the event
 	urlb := fmt.Sprintf("nats://%s:%d", ob.Host, ob.Port)
@@ -1361,6 +1364,293 @@ func TestServerEventsPingStatsZ(t *testing.T) {
 	}
 }
 
+func TestServerEventsMonitorRequests(t *testing.T) {
+	sa, _, sb, optsB, akp := runTrustedCluster(t)
+	defer sa.Shutdown()
+	defer sb.Shutdown()
+
+	url := fmt.Sprintf("nats://%s:%d", optsB.Host, optsB.Port)
+	nc, err := nats.Connect(url, createUserCreds(t, sb, akp))
+	if err != nil {
+		t.Fatalf("Error on connect: %v", err)
+	}
+	defer nc.Close()
+
+	// Requests directed at a server, through the route for sa.
+	for _, test := range []struct {
+		name string
+		opts interface{}
+		resp interface{}
+	}{
+		{"VARZ", nil, &Varz{}},
+		{"CONNZ", &ConnzOptions{Subscriptions: true}, &Connz{}},
+		{"ROUTEZ", &RoutezOptions{}, &Routez{}},
+		{"GATEWAYZ", nil, &Gatewayz{}},
+		{"LEAFZ", nil, &Leafz{}},
+		{"SUBSZ", &SubszOptions{Subscriptions: true}, &Subsz{}},
+	} {
+		var req []byte
+		if test.opts != nil {
+			req, _ = json.Marshal(test.opts)
+		}
+		for _, s := range []*Server{sa, sb} {
+			msg, err := nc.Request(fmt.Sprintf(serverDirectReqSubj, s.ID(), test.name), req, time.Second)
+			if err != nil {
+				t.Fatalf("Error on %s request: %v", test.name, err)
+			}
+			if err := json.Unmarshal(msg.Data, test.resp); err != nil {
+				t.Fatalf("Error unmarshalling %s response: %v", test.name, err)
+			}
+			id := reflect.ValueOf(test.resp).Elem().FieldByName("ID").String()
+			if id != s.ID() {
+				t.Fatalf("Expected %s response from %q, got %q", test.name, s.ID(), id)
+			}
+		}
+	}
+
+	// Ping all servers.
+	reply := nc.NewRespInbox()
+	sub, _ := nc.SubscribeSync(reply)
+	req, _ := json.Marshal(&ConnzOptions{Limit: 1})
+	nc.PublishRequest(fmt.Sprintf(serverPingReqSubj, "CONNZ"), reply, req)
+	ids := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		msg, err := sub.NextMsg(time.Second)
+		if err != nil {
+			t.Fatalf("Error receiving msg: %v", err)
+		}
+		connz := &Connz{}
+		if err := json.Unmarshal(msg.Data, connz); err != nil {
+			t.Fatalf("Error unmarshalling connz: %v", err)
+		}
+		if connz.Limit != 1 {
+			t.Fatalf("Expected request options to be used, got %+v", connz)
+		}
+		ids[connz.ID] = true
+	}
+	if !ids[sa.ID()] || !ids[sb.ID()] {
+		t.Fatalf("Expected responses from both servers, got %v", ids)
+	}
+
+	// Invalid options are reported.
+	req, _ = json.Marshal(&SubszOptions{Test: "foo.*"})
+	msg, err := nc.Request(fmt.Sprintf(serverDirectReqSubj, sb.ID(), "SUBSZ"), req, time.Second)
+	if err != nil {
+		t.Fatalf("Error on request: %v", err)
+	}
+	apiErr := &ServerAPIError{}
+	if err := json.Unmarshal(msg.Data, apiErr); err != nil || apiErr.Server != sb.ID() || apiErr.Error == _EMPTY_ {
+		t.Fatalf("Expected error response, got %q", msg.Data)
+	}
+}
+
+func TestAccountTrafficStats(t *testing.T) {
+	sa, optsA, sb, optsB, akp := runTrustedCluster(t)
+	defer sa.Shutdown()
+	defer sb.Shutdown()
+
+	acc, accKp := createAccount(sa)
+	if _, err := sb.LookupAccount(acc.Name); err != nil {
+		t.Fatalf("Error looking up account: %v", err)
+	}
+
+	sys, err := nats.Connect(fmt.Sprintf("nats://%s:%d", optsA.Host, optsA.Port), createUserCreds(t, sa, akp))
+	if err != nil {
+		t.Fatalf("Error on connect: %v", err)
+	}
+	defer sys.Close()
+	connSub := natsSubSync(t, sys, fmt.Sprintf(connectEventSubj, acc.Name))
+	discSub := natsSubSync(t, sys, fmt.Sprintf(disconnectEventSubj, acc.Name))
+	statsSub := natsSubSync(t, sys, fmt.Sprintf(accStatsEventSubj, acc.Name))
+	natsFlush(t, sys)
+
+	// A subscriber on sb, a publisher on sa.
+	ncb, err := nats.Connect(fmt.Sprintf("nats://%s:%d", optsB.Host, optsB.Port), createUserCreds(t, sb, accKp))
+	if err != nil {
+		t.Fatalf("Error on connect: %v", err)
+	}
+	defer ncb.Close()
+	sub := natsSubSync(t, ncb, "foo")
+	natsFlush(t, ncb)
+	checkFor(t, time.Second, 15*time.Millisecond, func() error {
+		if n := acc.sl.Count(); n != 1 {
+			return fmt.Errorf("Expected subscription to be propagated, got %d", n)
+		}
+		return nil
+	})
+
+	nca, err := nats.Connect(fmt.Sprintf("nats://%s:%d", optsA.Host, optsA.Port), createUserCreds(t, sa, accKp))
+	if err != nil {
+		t.Fatalf("Error on connect: %v", err)
+	}
+	m := ConnectEventMsg{}
+	if err := json.Unmarshal(natsNexMsg(t, connSub, time.Second).Data, &m); err != nil {
+		t.Fatalf("Error unmarshalling connect event: %v", err)
+	}
+	if m.AccountStats == nil || m.AccountStats.Total.Received.Msgs != 0 {
+		t.Fatalf("Unexpected account stats in connect event: %+v", m.AccountStats)
+	}
+	for i := 0; i < 10; i++ {
+		nca.Publish("foo", []byte("hello"))
+	}
+	natsFlush(t, nca)
+	for i := 0; i < 10; i++ {
+		natsNexMsg(t, sub, time.Second)
+	}
+
+	expected := func(recv, sent TrafficStats) TrafficStats {
+		return TrafficStats{Received: recv.Received, Sent: sent.Sent}
+	}
+	ten := TrafficStats{Received: DataStats{10, 50}, Sent: DataStats{10, 50}}
+	checkFor(t, time.Second, 15*time.Millisecond, func() error {
+		if ats := acc.TrafficStats(); ats.Clients != expected(ten, TrafficStats{}) || ats.Routes != expected(TrafficStats{}, ten) || ats.Total != ten {
+			return fmt.Errorf("Unexpected stats on sa: %+v", ats)
+		}
+		accb, _ := sb.LookupAccount(acc.Name)
+		if ats := accb.TrafficStats(); ats.Clients != expected(TrafficStats{}, ten) || ats.Routes != expected(ten, TrafficStats{}) {
+			return fmt.Errorf("Unexpected stats on sb: %+v", ats)
+		}
+		return nil
+	})
+
+	// Reported in accountz.
+	az, err := sa.Accountz(&AccountzOptions{Account: acc.Name})
+	if err != nil {
+		t.Fatalf("Error on accountz: %v", err)
+	}
+	if az.Accounts[0].Stats.Total != ten {
+		t.Fatalf("Unexpected stats in accountz: %+v", az.Accounts[0].Stats)
+	}
+
+	// Periodically sent.
+	sa.mu.Lock()
+	sa.sys.statsz = 10 * time.Millisecond
+	sa.sys.stmr.Reset(sa.sys.statsz)
+	sa.mu.Unlock()
+	asm := AccountStatsMsg{}
+	if err := json.Unmarshal(natsNexMsg(t, statsSub, time.Second).Data, &asm); err != nil {
+		t.Fatalf("Error unmarshalling stats event: %v", err)
+	}
+	if asm.Server.ID != sa.ID() || asm.Account != acc.Name || asm.Conns != 1 || asm.Stats.Total != ten {
+		t.Fatalf("Unexpected stats event: %+v", asm)
+	}
+
+	nca.Close()
+	dm := DisconnectEventMsg{}
+	if err := json.Unmarshal(natsNexMsg(t, discSub, time.Second).Data, &dm); err != nil {
+		t.Fatalf("Error unmarshalling disconnect event: %v", err)
+	}
+	if dm.AccountStats == nil || dm.AccountStats.Clients.Received.Msgs != 10 {
+		t.Fatalf("Unexpected account stats in disconnect event: %+v", dm.AccountStats)
+	}
+}
+
+func TestAccountAdvisories(t *testing.T) {
+	conf := createConfFile(t, []byte(`
+		listen: "127.0.0.1:-1"
+		max_payload: 32KB
+		max_pending: 64KB
+		write_deadline: "30s"
+		system_account: SYS
+		accounts {
+			SYS { users [{user: sys, password: pwd}] }
+			A { users [{user: a, password: pwd}, {user: slow, password: pwd}] }
+			B { users [{user: b, password: pwd}] }
+		}
+	`))
+	defer os.Remove(conf)
+	s, opts := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	url := func(user string) string {
+		return fmt.Sprintf("nats://%s:pwd@%s:%d", user, opts.Host, opts.Port)
+	}
+	// Advisories are published in the system account and imported
+	// by the accounts, each one only getting its own.
+	sys := natsConnect(t, url("sys"))
+	defer sys.Close()
+	sysViolSub := natsSubSync(t, sys, fmt.Sprintf(accViolationEventSubj, "*"))
+	sysSCSub := natsSubSync(t, sys, fmt.Sprintf(accSlowConsumerEventSubj, "*"))
+	natsFlush(t, sys)
+	nca := natsConnect(t, url("a"))
+	defer nca.Close()
+	aSub := natsSubSync(t, nca, "$SYS.ACCOUNT.A.>")
+	natsFlush(t, nca)
+	ncb := natsConnect(t, url("b"))
+	defer ncb.Close()
+	bSub := natsSubSync(t, ncb, "$SYS.ACCOUNT.>")
+	natsFlush(t, ncb)
+
+	rawConn := func(user, protos string) net.Conn {
+		t.Helper()
+		c, err := net.Dial("tcp", net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port)))
+		if err != nil {
+			t.Fatalf("Error on dial: %v", err)
+		}
+		if _, err := c.Write([]byte(fmt.Sprintf("CONNECT {\"user\":%q,\"pass\":\"pwd\"}\r\n%s", user, protos))); err != nil {
+			t.Fatalf("Error on write: %v", err)
+		}
+		return c
+	}
+	nextAdvisory := func(sub *nats.Subscription, subject string, e interface{}) {
+		t.Helper()
+		msg := natsNexMsg(t, sub, time.Second)
+		if msg.Subject != subject {
+			t.Fatalf("Expected advisory on %q, got %q", subject, msg.Subject)
+		}
+		if err := json.Unmarshal(msg.Data, e); err != nil {
+			t.Fatalf("Error unmarshalling advisory: %v", err)
+		}
+	}
+
+	// Maximum payload.
+	c := rawConn("a", "PUB foo 40000\r\n")
+	defer c.Close()
+	violationSubj := fmt.Sprintf(accViolationEventSubj, "A")
+	for _, sub := range []*nats.Subscription{sysViolSub, aSub} {
+		e := ViolationEventMsg{}
+		nextAdvisory(sub, violationSubj, &e)
+		if e.Client.Account != "A" || e.Type != violationPayload || e.Subject != "foo" ||
+			e.Size != 40000 || e.MaxPayload != 32*1024 || e.Reason != MaxPayloadExceeded.String() {
+			t.Fatalf("Unexpected advisory: %+v", e)
+		}
+	}
+
+	// Slow consumer.
+	c = rawConn("slow", "SUB foo 1\r\nPING\r\n")
+	defer c.Close()
+	c.(*net.TCPConn).SetReadBuffer(128)
+	acc, _ := s.LookupAccount("A")
+	checkFor(t, time.Second, 15*time.Millisecond, func() error {
+		if n := acc.sl.Count(); n != 2 {
+			return fmt.Errorf("Expected subscription of slow consumer, got %d subscriptions", n)
+		}
+		return nil
+	})
+	payload := make([]byte, 30*1024)
+	for i := 0; i < 500; i++ {
+		nca.Publish("foo", payload)
+	}
+	natsFlush(t, nca)
+	scSubj := fmt.Sprintf(accSlowConsumerEventSubj, "A")
+	for _, sub := range []*nats.Subscription{sysSCSub, aSub} {
+		e := SlowConsumerEventMsg{}
+		nextAdvisory(sub, scSubj, &e)
+		if e.Client.Account != "A" || e.Subject != "foo" ||
+			e.Pending <= 64*1024 || e.Reason != SlowConsumerPendingBytes.String() {
+			t.Fatalf("Unexpected advisory: %+v", e)
+		}
+	}
+	// Messages sent to the cleared connection don't produce more advisories.
+	if msg, err := sysSCSub.NextMsg(100 * time.Millisecond); err == nil {
+		t.Fatalf("Unexpected advisory: %s", msg.Data)
+	}
+
+	if msg, err := bSub.NextMsg(100 * time.Millisecond); err == nil {
+		t.Fatalf("Unexpected advisory for account B on %q", msg.Subject)
+	}
+}
+
 func TestGatewayNameClientInfo(t *testing.T) {
 	sa, _, sb, _, _ := runTrustedCluster(t)
 	defer sa.Shutdown()
@@ -1530,3 +1820,114 @@ func TestConnectionUpdatesTimerProperlySet(t *testing.T) {
 		return nil
 	})
 }
+
+func TestRemoteConnectionEvents(t *testing.T) {
+	confA := createConfFile(t, []byte(`
+		listen: "127.0.0.1:-1"
+		system_account: SYS
+		accounts { SYS { users [{user: sys, password: pwd}] } }
+		cluster { listen: "127.0.0.1:-1" }
+		gateway { name: "A", listen: "127.0.0.1:-1" }
+		leafnodes { listen: "127.0.0.1:-1" }
+	`))
+	defer os.Remove(confA)
+	sa, optsA := RunServerWithConfig(confA)
+	defer sa.Shutdown()
+
+	nc := natsConnect(t, fmt.Sprintf("nats://sys:pwd@%s:%d", optsA.Host, optsA.Port))
+	defer nc.Close()
+	connSub := natsSubSync(t, nc, fmt.Sprintf(remoteConnectEventSubj, sa.ID(), "*"))
+	discSub := natsSubSync(t, nc, fmt.Sprintf(remoteDisconnectEventSubj, sa.ID(), "*"))
+	natsFlush(t, nc)
+
+	nextEvent := func(sub *nats.Subscription) *RemoteEventMsg {
+		t.Helper()
+		m := &RemoteEventMsg{}
+		if err := json.Unmarshal(natsNexMsg(t, sub, 2*time.Second).Data, m); err != nil {
+			t.Fatalf("Error unmarshalling event: %v", err)
+		}
+		return m
+	}
+	checkEvents := func(sub *nats.Subscription, kind, remoteID string, disconnect bool, outbound ...bool) {
+		t.Helper()
+		for _, out := range outbound {
+			m := nextEvent(sub)
+			if m.Server.ID != sa.ID() || m.Kind != kind || m.RemoteID != remoteID || m.Outbound != out {
+				t.Fatalf("Unexpected event: %+v", m)
+			}
+			if m.IP == _EMPTY_ || m.Port == 0 || m.Start.IsZero() {
+				t.Fatalf("Expected address and start to be set: %+v", m)
+			}
+			if out && kind == "GATEWAY" && m.URL == _EMPTY_ {
+				t.Fatalf("Expected url to be set: %+v", m)
+			}
+			if kind == "GATEWAY" && m.Name != "G" {
+				t.Fatalf("Expected gateway name, got %+v", m)
+			}
+			if kind == "LEAFNODE" && m.Account != globalAccountName {
+				t.Fatalf("Expected leafnode account, got %+v", m)
+			}
+			if disconnect && (m.Stop == nil || m.Duration == _EMPTY_ || m.Reason == _EMPTY_ || m.Sent == nil) {
+				t.Fatalf("Expected disconnect details: %+v", m)
+			}
+			if !disconnect && (m.Stop != nil || m.Reason != _EMPTY_) {
+				t.Fatalf("Unexpected disconnect details: %+v", m)
+			}
+		}
+	}
+
+	// Route
+	confB := createConfFile(t, []byte(fmt.Sprintf(`
+		listen: "127.0.0.1:-1"
+		system_account: SYS
+		accounts { SYS { users [{user: sys, password: pwd}] } }
+		cluster { listen: "127.0.0.1:-1", routes: ["nats://127.0.0.1:%d"] }
+	`, optsA.Cluster.Port)))
+	defer os.Remove(confB)
+	sb, _ := RunServerWithConfig(confB)
+	checkClusterFormed(t, sa, sb)
+	checkEvents(connSub, "ROUTE", sb.ID(), false, false)
+	sb.Shutdown()
+	checkEvents(discSub, "ROUTE", sb.ID(), true, false)
+
+	// Gateway, inbound then outbound.
+	confG := createConfFile(t, []byte(fmt.Sprintf(`
+		listen: "127.0.0.1:-1"
+		system_account: SYS
+		accounts { SYS { users [{user: sys, password: pwd}] } }
+		gateway {
+			name: "G"
+			listen: "127.0.0.1:-1"
+			gateways [{name: "A", url: "nats://127.0.0.1:%d"}]
+		}
+	`, optsA.Gateway.Port)))
+	defer os.Remove(confG)
+	sg, _ := RunServerWithConfig(confG)
+	waitForOutboundGateways(t, sa, 1, 2*time.Second)
+	checkEvents(connSub, "GATEWAY", sg.ID(), false, false, true)
+	// The connect URL, which may hold credentials, is not kept around.
+	if c := sa.getOutboundGatewayConnection("G"); c != nil {
+		c.mu.Lock()
+		u := c.gw.connectURL
+		c.mu.Unlock()
+		if u != nil {
+			t.Fatalf("Expected connect URL to be cleared, got %v", u)
+		}
+	}
+	sg.Shutdown()
+	m1, m2 := nextEvent(discSub), nextEvent(discSub)
+	if m1.Kind != "GATEWAY" || m2.Kind != "GATEWAY" || m1.Outbound == m2.Outbound || m1.RemoteID != sg.ID() || m1.Reason == _EMPTY_ {
+		t.Fatalf("Unexpected gateway disconnect events: %+v %+v", m1, m2)
+	}
+
+	// Leafnode
+	confL := createConfFile(t, []byte(fmt.Sprintf(`
+		listen: "127.0.0.1:-1"
+		leafnodes { remotes [{url: "nats://127.0.0.1:%d"}] }
+	`, optsA.LeafNode.Port)))
+	defer os.Remove(confL)
+	sl, _ := RunServerWithConfig(confL)
+	checkEvents(connSub, "LEAFNODE", sl.ID(), false, false)
+	sl.Shutdown()
+	checkEvents(discSub, "LEAFNODE", sl.ID(), true, false)
+}
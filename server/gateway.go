@@ -166,6 +166,7 @@ type gateway struct {
 	outbound   bool
 	cfg        *gatewayCfg
 	connectURL *url.URL          // Needed when sending CONNECT after receiving INFO from remote
+	remoteURL  string            // URL of the remote without credentials, for events
 	infoJSON   []byte            // Needed when sending INFO after receiving INFO from remote
 	outsim     *sync.Map         // Per-account subject interest (or no-interest) (outbound conn)
 	insim      map[string]*insie // Per-account subject no-interest sent or modeInterestOnly mode (inbound conn)
@@ -682,6 +683,7 @@ func (s *Server) createGateway(cfg *gatewayCfg, url *url.URL, conn net.Conn) {
 		// Since we are delaying the connect until after receiving
 		// the remote's INFO protocol, save the URL we need to connect to.
 		c.gw.connectURL = url
+		c.gw.remoteURL = linkURLString(url)
 		c.gw.infoJSON = infoJSON
 
 		c.Noticef("Creating outbound gateway connection to %q", cfg.Name)
@@ -1485,6 +1487,8 @@ func (s *Server) registerInboundGatewayConnection(cid uint64, gwc *client) {
 	s.gateway.Lock()
 	s.gateway.in[cid] = gwc
 	s.gateway.Unlock()
+
+	s.remoteConnectEvent(gwc)
 }
 
 // Register the given gateway connection (*client) in the outbound gateways
@@ -1499,6 +1503,8 @@ func (s *Server) registerOutboundGatewayConnection(name string, gwc *client) boo
 	s.gateway.outo = append(s.gateway.outo, gwc)
 	s.gateway.orderOutboundConnectionsLocked()
 	s.gateway.Unlock()
+
+	s.remoteConnectEvent(gwc)
 	return true
 }
 
@@ -2356,7 +2362,9 @@ func (c *client) sendMsgToGateways(acc *Account, msg, subject, reply []byte, qgr
 		sub.nm, sub.max = 0, 0
 		sub.client = gwc
 		sub.subject = c.pa.subject
-		c.deliverMsg(sub, c.pa.subject, mh, msg)
+		if c.deliverMsg(sub, c.pa.subject, mh, msg) {
+			acc.addOutbound(GATEWAY, 1, int64(len(msg)-LEN_CR_LF))
+		}
 	}
 	// Done with subscription, put back to pool. We don't need
 	// to reset content since we explicitly set when using it.
@@ -2567,6 +2575,11 @@ func (c *client) processInboundGatewayMsg(msg []byte) {
 		c.srv.gatewayHandleAccountNoInterest(c, c.pa.account)
 		return
 	}
+	acc.addInbound(GATEWAY, 1, int64(len(msg)-LEN_CR_LF))
+
+	if hs := c.srv.hotSubs; hs != nil {
+		c.sampleHotSubject(hs, acc, c.pa.subject, c.pa.size)
+	}
 
 	// Check to see if we need to map/route to another account.
 	if acc.imports.services != nil && isServiceReply(c.pa.subject) {
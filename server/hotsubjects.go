@@ -0,0 +1,240 @@
+// Copyright 2019 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HotSubject reports the traffic of one of the subjects with the most
+// messages. Counts are estimated from the sampled messages, and may be
+// overestimated by up to MaxError messages.
+type HotSubject struct {
+	Account  string  `json:"account"`
+	Subject  string  `json:"subject"`
+	Msgs     int64   `json:"msgs"`
+	Bytes    int64   `json:"bytes"`
+	MsgRate  float64 `json:"msg_rate"`
+	ByteRate float64 `json:"byte_rate"`
+	MaxError int64   `json:"max_error,omitempty"`
+}
+
+// hotEntry is a counter of the space-saving algorithm.
+type hotEntry struct {
+	acc   string
+	subj  string
+	msgs  int64
+	bytes int64
+	err   int64
+	index int
+}
+
+// hotTable keeps the counters of at most size keys, evicting the key with
+// the smallest count to make room for new ones (space-saving top-K).
+type hotTable struct {
+	start   time.Time
+	entries map[string]*hotEntry
+	heap    hotHeap
+}
+
+// hotHeap is a min-heap of the counters, by number of messages.
+type hotHeap []*hotEntry
+
+func (h hotHeap) Len() int           { return len(h) }
+func (h hotHeap) Less(i, j int) bool { return h[i].msgs < h[j].msgs }
+func (h hotHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *hotHeap) Push(x interface{}) {
+	e := x.(*hotEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+func (h *hotHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+func newHotTable(size int, now time.Time) *hotTable {
+	return &hotTable{
+		start:   now,
+		entries: make(map[string]*hotEntry, size),
+		heap:    make(hotHeap, 0, size),
+	}
+}
+
+func (t *hotTable) add(size int, acc, subj string, bytes int64) {
+	key := acc + " " + subj
+	if e := t.entries[key]; e != nil {
+		e.msgs++
+		e.bytes += bytes
+		heap.Fix(&t.heap, e.index)
+		return
+	}
+	if len(t.heap) < size {
+		e := &hotEntry{acc: acc, subj: subj, msgs: 1, bytes: bytes}
+		t.entries[key] = e
+		heap.Push(&t.heap, e)
+		return
+	}
+	// Replace the smallest counter, the new key inherits its count
+	// which is the maximum error of the new counter.
+	e := t.heap[0]
+	delete(t.entries, e.acc+" "+e.subj)
+	e.acc, e.subj = acc, subj
+	e.err = e.msgs
+	e.msgs++
+	e.bytes = bytes
+	t.entries[key] = e
+	heap.Fix(&t.heap, 0)
+}
+
+// hotSubjects tracks the subjects with the most messages over a sliding
+// window made of the current and previous time windows.
+type hotSubjects struct {
+	sync.Mutex
+	size     int
+	sampling int
+	depth    int
+	window   time.Duration
+	cur      *hotTable
+	prev     *hotTable
+}
+
+func newHotSubjects(o *HotSubjectsOpts) *hotSubjects {
+	if o.Size <= 0 {
+		return nil
+	}
+	hs := &hotSubjects{
+		size:     o.Size,
+		sampling: o.Sampling,
+		depth:    o.Depth,
+		window:   o.Window,
+	}
+	if hs.sampling <= 0 {
+		hs.sampling = DEFAULT_HOT_SUBJECTS_SAMPLING
+	}
+	if hs.window <= 0 {
+		hs.window = DEFAULT_HOT_SUBJECTS_WINDOW
+	}
+	hs.cur = newHotTable(hs.size, time.Now())
+	return hs
+}
+
+// Returns the key the subject is tracked under, which is its prefix made
+// of the configured number of tokens, if any.
+func (hs *hotSubjects) subjectKey(subject []byte) string {
+	if hs.depth > 0 {
+		n := 0
+		for i, b := range subject {
+			if b == btsep {
+				if n++; n == hs.depth {
+					return string(subject[:i])
+				}
+			}
+		}
+	}
+	return string(subject)
+}
+
+// Rotates the windows if the current one is over.
+// Lock should be held.
+func (hs *hotSubjects) rotate(now time.Time) {
+	if now.Sub(hs.cur.start) < hs.window {
+		return
+	}
+	hs.prev = hs.cur
+	// If no message was seen for a whole window, the previous one is empty.
+	if now.Sub(hs.prev.start) >= 2*hs.window {
+		hs.prev = newHotTable(0, now.Add(-hs.window))
+	}
+	hs.cur = newHotTable(hs.size, hs.prev.start.Add(hs.window))
+}
+
+// sampleHotSubject is called for each message received by a connection, and records
+// one message out of the sampling rate.
+// Called from the read loop of the connection.
+func (c *client) sampleHotSubject(hs *hotSubjects, acc *Account, subject []byte, size int) {
+	c.in.hsn++
+	if c.in.hsn < uint32(hs.sampling) {
+		return
+	}
+	c.in.hsn = 0
+	key := hs.subjectKey(subject)
+	now := time.Now()
+	hs.Lock()
+	hs.rotate(now)
+	hs.cur.add(hs.size, acc.Name, key, int64(size)*int64(hs.sampling))
+	hs.Unlock()
+}
+
+// top returns the n subjects with the most messages, all of them if n is
+// not positive.
+func (hs *hotSubjects) top(n int) []*HotSubject {
+	now := time.Now()
+	hs.Lock()
+	hs.rotate(now)
+	start := hs.cur.start
+	totals := make(map[string]*HotSubject, len(hs.cur.entries))
+	for _, t := range []*hotTable{hs.prev, hs.cur} {
+		if t == nil {
+			continue
+		}
+		if t.start.Before(start) {
+			start = t.start
+		}
+		for key, e := range t.entries {
+			hsub := totals[key]
+			if hsub == nil {
+				hsub = &HotSubject{Account: e.acc, Subject: e.subj}
+				totals[key] = hsub
+			}
+			hsub.Msgs += e.msgs * int64(hs.sampling)
+			hsub.Bytes += e.bytes
+			hsub.MaxError += e.err * int64(hs.sampling)
+		}
+	}
+	hs.Unlock()
+
+	subjects := make([]*HotSubject, 0, len(totals))
+	for _, hsub := range totals {
+		subjects = append(subjects, hsub)
+	}
+	sort.Slice(subjects, func(i, j int) bool {
+		if subjects[i].Msgs != subjects[j].Msgs {
+			return subjects[i].Msgs > subjects[j].Msgs
+		}
+		if subjects[i].Account != subjects[j].Account {
+			return subjects[i].Account < subjects[j].Account
+		}
+		return subjects[i].Subject < subjects[j].Subject
+	})
+	if n > 0 && len(subjects) > n {
+		subjects = subjects[:n]
+	}
+	if secs := now.Sub(start).Seconds(); secs > 0 {
+		for _, hsub := range subjects {
+			hsub.MsgRate = float64(hsub.Msgs) / secs
+			hsub.ByteRate = float64(hsub.Bytes) / secs
+		}
+	}
+	return subjects
+}
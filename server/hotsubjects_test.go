@@ -0,0 +1,166 @@
+// Copyright 2019 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestHotSubjectsTracker(t *testing.T) {
+	hs := newHotSubjects(&HotSubjectsOpts{Size: 3, Sampling: 1, Depth: 2})
+	c := &client{}
+	acc := NewAccount("A")
+	pub := func(subject string, n int) {
+		for i := 0; i < n; i++ {
+			c.sampleHotSubject(hs, acc, []byte(subject), 10)
+		}
+	}
+	pub("orders.eu.1", 5)
+	pub("orders.eu.2", 5)
+	pub("orders.us", 8)
+	pub("events.a.b", 3)
+	pub("metrics", 1)
+
+	top := hs.top(0)
+	if len(top) != 3 {
+		t.Fatalf("Expected 3 subjects, got %d", len(top))
+	}
+	if hsub := top[0]; hsub.Subject != "orders.eu" || hsub.Msgs != 10 || hsub.Bytes != 100 || hsub.MaxError != 0 {
+		t.Fatalf("Unexpected top subject: %+v", hsub)
+	}
+	if hsub := top[1]; hsub.Subject != "orders.us" || hsub.Msgs != 8 {
+		t.Fatalf("Unexpected second subject: %+v", hsub)
+	}
+	// "metrics" replaced "events.a", inheriting its count as error.
+	if hsub := top[2]; hsub.Subject != "metrics" || hsub.Msgs != 4 || hsub.MaxError != 3 || hsub.Bytes != 10 {
+		t.Fatalf("Unexpected third subject: %+v", hsub)
+	}
+	if top := hs.top(1); len(top) != 1 || top[0].Subject != "orders.eu" {
+		t.Fatalf("Unexpected top: %+v", top)
+	}
+
+	// Counts of the previous window are kept, older ones are dropped.
+	hs.Lock()
+	hs.cur.start = hs.cur.start.Add(-hs.window)
+	hs.Unlock()
+	pub("orders.us", 4)
+	if top := hs.top(1); top[0].Subject != "orders.us" || top[0].Msgs != 12 || top[0].MsgRate <= 0 {
+		t.Fatalf("Unexpected top: %+v", top[0])
+	}
+	hs.Lock()
+	hs.cur.start = hs.cur.start.Add(-2 * hs.window)
+	hs.Unlock()
+	if top := hs.top(0); len(top) != 0 {
+		t.Fatalf("Expected no subjects, got %+v", top)
+	}
+
+	// Only one message out of sampling is recorded, counts are scaled.
+	hs = newHotSubjects(&HotSubjectsOpts{Size: 3, Sampling: 4})
+	pub("foo", 9)
+	if top := hs.top(0); len(top) != 1 || top[0].Msgs != 8 || top[0].Bytes != 80 {
+		t.Fatalf("Unexpected top: %+v", top)
+	}
+
+	if hs := newHotSubjects(&HotSubjectsOpts{}); hs != nil {
+		t.Fatal("Expected tracking to be disabled")
+	}
+}
+
+func TestHotSubjectsConfig(t *testing.T) {
+	conf := createConfFile(t, []byte(`
+		hot_subjects {
+			size: 20
+			sampling: 5
+			depth: 2
+			window: "30s"
+		}
+	`))
+	defer os.Remove(conf)
+	opts, err := ProcessConfigFile(conf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := HotSubjectsOpts{Size: 20, Sampling: 5, Depth: 2, Window: 30 * time.Second}
+	if opts.HotSubjects != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, opts.HotSubjects)
+	}
+
+	conf2 := createConfFile(t, []byte(`hot_subjects: true`))
+	defer os.Remove(conf2)
+	if opts, err = ProcessConfigFile(conf2); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.HotSubjects.Size != DEFAULT_HOT_SUBJECTS_SIZE {
+		t.Fatalf("Unexpected hot_subjects: %+v", opts.HotSubjects)
+	}
+
+	for _, test := range []string{
+		`hot_subjects: "yes"`,
+		`hot_subjects { size: -1 }`,
+		`hot_subjects { window: "1x" }`,
+		`hot_subjects { unknown: 1 }`,
+	} {
+		conf := createConfFile(t, []byte(test))
+		if _, err := ProcessConfigFile(conf); err == nil {
+			t.Fatalf("Expected error for %q", test)
+		}
+		os.Remove(conf)
+	}
+}
+
+func TestSubszTop(t *testing.T) {
+	opts := DefaultMonitorOptions()
+	opts.HotSubjects = HotSubjectsOpts{Size: 10, Sampling: 1}
+	s := RunServer(opts)
+	defer s.Shutdown()
+
+	nc, err := nats.Connect(fmt.Sprintf("nats://127.0.0.1:%d", opts.Port))
+	if err != nil {
+		t.Fatalf("Error on connect: %v", err)
+	}
+	defer nc.Close()
+	for i := 0; i < 5; i++ {
+		nc.Publish("foo", []byte("hello"))
+	}
+	nc.Publish("bar", []byte("hello"))
+	nc.Flush()
+
+	url := fmt.Sprintf("http://127.0.0.1:%d/subsz?top=1", s.MonitorAddr().Port)
+	checkFor(t, time.Second, 15*time.Millisecond, func() error {
+		sz := &Subsz{}
+		if err := json.Unmarshal(readBody(t, url), sz); err != nil {
+			t.Fatalf("Error unmarshalling: %v", err)
+		}
+		if len(sz.Top) != 1 {
+			return fmt.Errorf("Expected 1 subject, got %+v", sz.Top)
+		}
+		if hsub := sz.Top[0]; hsub.Account != globalAccountName || hsub.Subject != "foo" || hsub.Msgs != 5 || hsub.Bytes != 25 {
+			return fmt.Errorf("Unexpected top subject: %+v", hsub)
+		}
+		return nil
+	})
+
+	// Top requires tracking to be enabled.
+	sm := runMonitorServer()
+	defer sm.Shutdown()
+	url = fmt.Sprintf("http://127.0.0.1:%d/subsz?top=1", sm.MonitorAddr().Port)
+	readBodyEx(t, url, http.StatusBadRequest, textPlain)
+}
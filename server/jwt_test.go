@@ -1313,6 +1313,46 @@ func TestJWTAccountLimitsMaxConns(t *testing.T) {
 	newClient("-ERR ")
 }
 
+func TestJWTSourceCIDRs(t *testing.T) {
+	// Connections in this test come from a pipe, so they have no
+	// source address and are rejected by any filter.
+	nuc := newJWTTestUserClaims()
+	nuc.Src = "10.0.0.0/8,192.168.0.0/16"
+	s, _, _ := setupJWTTestWithUserClaims(t, nuc, "-ERR ")
+	if v, _ := s.Varz(nil); v.SourceRejects != 1 {
+		t.Fatalf("Expected 1 rejection, got %v", v.SourceRejects)
+	}
+
+	nuc = newJWTTestUserClaims()
+	nuc.Tags.Add("denied_cidr:10.0.0.0/8")
+	setupJWTTestWithUserClaims(t, nuc, "-ERR ")
+
+	nac := newJWTTestAccountClaims()
+	nac.Tags.Add("allowed_cidr:10.0.0.0/8")
+	s, akp, _, _ := setupJWTTestWitAccountClaims(t, nac, "-ERR ")
+
+	apub, _ := akp.PublicKey()
+	acc, _ := s.LookupAccount(apub)
+	if acc.srcFilter.allows("127.0.0.1") || !acc.srcFilter.allows("10.1.2.3") {
+		t.Fatal("Unexpected account source filter")
+	}
+	// Removing the tags removes the restriction.
+	nac = jwt.NewAccountClaims(apub)
+	okp, _ := nkeys.FromSeed(oSeed)
+	ajwt, err := nac.Encode(okp)
+	if err != nil {
+		t.Fatalf("Error generating account JWT: %v", err)
+	}
+	ac, _ := jwt.DecodeAccountClaims(ajwt)
+	s.updateAccountClaims(acc, ac)
+	acc.mu.RLock()
+	f := acc.srcFilter
+	acc.mu.RUnlock()
+	if f != nil {
+		t.Fatal("Expected account source filter to be removed")
+	}
+}
+
 // This will test that we can switch from a public export to a private
 // one and back with export claims to make sure the claim update mechanism
 // is working properly.
@@ -1695,6 +1735,136 @@ func TestJWTUserSigningKey(t *testing.T) {
 	}
 }
 
+// Encodes the account claims with roles for its signing keys, which the
+// jwt library does not know about.
+func encodeAccountWithRoles(t *testing.T, nac *jwt.AccountClaims, roles map[string]*SigningKeyRole) string {
+	t.Helper()
+	okp, _ := nkeys.FromSeed(oSeed)
+	ajwt, err := nac.Encode(okp)
+	if err != nil {
+		t.Fatalf("Error generating account JWT: %v", err)
+	}
+	chunks := strings.Split(ajwt, ".")
+	payload, _ := base64.RawURLEncoding.DecodeString(chunks[1])
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatalf("Error decoding account JWT: %v", err)
+	}
+	claims["nats"].(map[string]interface{})["signing_key_roles"] = roles
+	payload, _ = json.Marshal(claims)
+	chunks[1] = base64.RawURLEncoding.EncodeToString(payload)
+	sig, _ := okp.Sign([]byte(chunks[1]))
+	chunks[2] = base64.RawURLEncoding.EncodeToString(sig)
+	return strings.Join(chunks, ".")
+}
+
+func TestJWTSigningKeyRoles(t *testing.T) {
+	s := opTrustBasicSetup()
+	defer s.Shutdown()
+	buildMemAccResolver(s)
+
+	akp, _ := nkeys.CreateAccount()
+	apub, _ := akp.PublicKey()
+	devKP, _ := nkeys.CreateAccount()
+	devPub, _ := devKP.PublicKey()
+	adminKP, _ := nkeys.CreateAccount()
+	adminPub, _ := adminKP.PublicKey()
+
+	dev := &SigningKeyRole{Role: "dev"}
+	dev.Template.Pub.Allow.Add("dev.{{name()}}.>")
+	dev.Template.Sub.Allow.Add("_INBOX.>")
+	dev.Template.Max = 2
+	nac := jwt.NewAccountClaims(apub)
+	nac.SigningKeys.Add(devPub, adminPub)
+	addAccountToMemResolver(s, apub, encodeAccountWithRoles(t, nac, map[string]*SigningKeyRole{devPub: dev}))
+
+	connect := func(kp nkeys.KeyPair) *client {
+		t.Helper()
+		nkp, _ := nkeys.CreateUser()
+		pub, _ := nkp.PublicKey()
+		nuc := jwt.NewUserClaims(pub)
+		nuc.Name = "alice"
+		nuc.IssuerAccount = apub
+		// The role ignores the user's own permissions.
+		nuc.Pub.Allow.Add(">")
+		ujwt, err := nuc.Encode(kp)
+		if err != nil {
+			t.Fatalf("Error generating user JWT: %v", err)
+		}
+		c, cr, l := newClientForServer(s)
+		var info nonceInfo
+		json.Unmarshal([]byte(l[5:]), &info)
+		sigraw, _ := nkp.Sign([]byte(info.Nonce))
+		sig := base64.RawURLEncoding.EncodeToString(sigraw)
+		go c.parse([]byte(fmt.Sprintf("CONNECT {\"jwt\":%q,\"sig\":\"%s\"}\r\nPING\r\n", ujwt, sig)))
+		if l, _ = cr.ReadString('\n'); !strings.HasPrefix(l, "PONG") {
+			t.Fatalf("Expected a PONG, got %q", l)
+		}
+		return c
+	}
+
+	c := connect(devKP)
+	if !c.pubAllowed("dev.alice.foo") || c.pubAllowed("foo") || !c.canSubscribe("_INBOX.foo") {
+		t.Fatal("Expected permissions of the role")
+	}
+	c.mu.Lock()
+	role, msubs := c.user.role, c.msubs
+	c.mu.Unlock()
+	if role != "dev" || msubs != 2 {
+		t.Fatalf("Expected role limits, got role %q and max subs %d", role, msubs)
+	}
+	// Signing keys without a role keep the user's permissions.
+	admin := connect(adminKP)
+	if !admin.pubAllowed("foo") {
+		t.Fatal("Expected permissions of the user")
+	}
+
+	// Changing the role updates the connected users.
+	dev.Template.Pub.Allow = jwt.StringList{"staging.>"}
+	acc, _ := s.LookupAccount(apub)
+	if err := s.updateAccountWithClaimJWT(acc, encodeAccountWithRoles(t, nac, map[string]*SigningKeyRole{devPub: dev})); err != nil {
+		t.Fatalf("Error updating account: %v", err)
+	}
+	if c.pubAllowed("dev.alice.foo") || !c.pubAllowed("staging.foo") {
+		t.Fatal("Expected permissions of the updated role")
+	}
+	isClosed := func(c *client) bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.flags.isSet(closeConnection)
+	}
+
+	// Claims applied without their JWT keep the roles of their signing keys.
+	uac := jwt.NewAccountClaims(apub)
+	uac.SigningKeys.Add(devPub, adminPub)
+	s.UpdateAccountClaims(acc, uac)
+	if isClosed(c) || !c.pubAllowed("staging.foo") {
+		t.Fatal("Expected client to keep the role")
+	}
+
+	// Removing the role evicts the users that had it.
+	if err := s.updateAccountWithClaimJWT(acc, encodeAccountWithRoles(t, nac, nil)); err != nil {
+		t.Fatalf("Error updating account: %v", err)
+	}
+	if !isClosed(c) {
+		t.Fatal("Expected client to be closed")
+	}
+	if isClosed(admin) {
+		t.Fatal("Expected client without role to stay connected")
+	}
+
+	// An invalid role denies everything to users of any signing key.
+	bad := &SigningKeyRole{Role: "bad"}
+	bad.Template.Pub.Allow.Add("foo bar")
+	if err := s.updateAccountWithClaimJWT(acc, encodeAccountWithRoles(t, nac, map[string]*SigningKeyRole{devPub: bad})); err != nil {
+		t.Fatalf("Error updating account: %v", err)
+	}
+	c = connect(devKP)
+	if c.pubAllowed("foo") || c.canSubscribe("foo") {
+		t.Fatal("Expected everything to be denied")
+	}
+}
+
 func TestJWTAccountImportSignerRemoved(t *testing.T) {
 	s := opTrustBasicSetup()
 	defer s.Shutdown()
@@ -2189,3 +2359,105 @@ func TestJWTAccountLimitsMaxConnsAfterExpired(t *testing.T) {
 		return nil
 	})
 }
+
+func TestJWTAccountMappedImports(t *testing.T) {
+	s := opTrustBasicSetup()
+	defer s.Shutdown()
+	buildMemAccResolver(s)
+
+	okp, _ := nkeys.FromSeed(oSeed)
+
+	fooKP, _ := nkeys.CreateAccount()
+	fooPub, _ := fooKP.PublicKey()
+	fooAC := jwt.NewAccountClaims(fooPub)
+	fooAC.Exports.Add(&jwt.Export{Subject: "events.>", Type: jwt.Stream})
+	fooAC.Exports.Add(&jwt.Export{Subject: "requests.>", Type: jwt.Service})
+	fooJWT, err := fooAC.Encode(okp)
+	if err != nil {
+		t.Fatalf("Error generating account JWT: %v", err)
+	}
+	addAccountToMemResolver(s, fooPub, fooJWT)
+
+	barKP, _ := nkeys.CreateAccount()
+	barPub, _ := barKP.PublicKey()
+	barAC := jwt.NewAccountClaims(barPub)
+	barAC.Imports.Add(&jwt.Import{Account: fooPub, Subject: "events.*.created", To: "partner.$1.new", Type: jwt.Stream})
+	barAC.Imports.Add(&jwt.Import{Account: fooPub, Subject: "partner.$1.request", To: "requests.*", Type: jwt.Service})
+	barJWT, err := barAC.Encode(okp)
+	if err != nil {
+		t.Fatalf("Error generating account JWT: %v", err)
+	}
+	addAccountToMemResolver(s, barPub, barJWT)
+
+	c, cr, cs := createClient(t, s, barKP)
+	parseAsync, quit := genAsyncParser(c)
+	defer func() { quit <- true }()
+
+	parseAsync(cs)
+	expectPong(t, cr)
+
+	parseAsync("SUB partner.22.new 1\r\nPING\r\n")
+	expectPong(t, cr)
+
+	c.mu.Lock()
+	shadow := c.subs["1"].shadow
+	c.mu.Unlock()
+	if len(shadow) != 1 || string(shadow[0].subject) != "events.22.created" {
+		t.Fatalf("Expected a shadow subscription on %q, got %+v", "events.22.created", shadow)
+	}
+
+	acc, _ := s.LookupAccount(barPub)
+	acc.mu.RLock()
+	si := acc.imports.services["partner.*.request"]
+	acc.mu.RUnlock()
+	if si == nil || si.to != "requests.*" || si.invalid {
+		t.Fatalf("Expected a valid mapped service import, got %+v", si)
+	}
+}
+
+func TestJWTAccountServiceImportCycle(t *testing.T) {
+	s := opTrustBasicSetup()
+	defer s.Shutdown()
+	buildMemAccResolver(s)
+
+	l := &captureWarnLogger{warn: make(chan string, 10)}
+	s.SetLogger(l, false, false)
+
+	okp, _ := nkeys.FromSeed(oSeed)
+
+	fooKP, _ := nkeys.CreateAccount()
+	fooPub, _ := fooKP.PublicKey()
+	barKP, _ := nkeys.CreateAccount()
+	barPub, _ := barKP.PublicKey()
+
+	// Requests on "foo" in foo are sent to bar, which sends them back.
+	fooAC := jwt.NewAccountClaims(fooPub)
+	fooAC.Exports.Add(&jwt.Export{Subject: "foo", Type: jwt.Service})
+	fooAC.Imports.Add(&jwt.Import{Account: barPub, Subject: "foo", To: "bar", Type: jwt.Service})
+	fooJWT, err := fooAC.Encode(okp)
+	if err != nil {
+		t.Fatalf("Error generating account JWT: %v", err)
+	}
+	addAccountToMemResolver(s, fooPub, fooJWT)
+
+	barAC := jwt.NewAccountClaims(barPub)
+	barAC.Exports.Add(&jwt.Export{Subject: "bar", Type: jwt.Service})
+	barAC.Imports.Add(&jwt.Import{Account: fooPub, Subject: "bar", To: "foo", Type: jwt.Service})
+	barJWT, err := barAC.Encode(okp)
+	if err != nil {
+		t.Fatalf("Error generating account JWT: %v", err)
+	}
+	addAccountToMemResolver(s, barPub, barJWT)
+
+	if _, err := s.LookupAccount(fooPub); err != nil {
+		t.Fatalf("Error looking up account: %v", err)
+	}
+	select {
+	case w := <-l.warn:
+		if !strings.Contains(w, "service import cycle") {
+			t.Fatalf("Unexpected warning: %q", w)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected a warning about the service import cycle")
+	}
+}
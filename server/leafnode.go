@@ -46,6 +46,8 @@ type leaf struct {
 	smap map[string]int32
 	// We have any auth stuff here for solicited connections.
 	remote *leafNodeCfg
+	// ID of the remote server.
+	remoteID string
 }
 
 // Used for remote (solicited) leafnodes.
@@ -741,6 +743,7 @@ func (c *client) processLeafnodeInfo(info *Info) {
 	if c.flags.setIfNotSet(infoReceived) {
 		// Capture a nonce here.
 		c.nonce = []byte(info.Nonce)
+		c.leaf.remoteID = info.ID
 		if info.TLSRequired && c.leaf.remote != nil {
 			c.leaf.remote.TLS = true
 		}
@@ -833,6 +836,8 @@ func (s *Server) addLeafNodeConnection(c *client) {
 	s.mu.Lock()
 	s.leafs[cid] = c
 	s.mu.Unlock()
+
+	s.remoteConnectEvent(c)
 }
 
 func (s *Server) removeLeafNodeConnection(c *client) {
@@ -890,6 +895,10 @@ func (c *client) processLeafNodeConnect(s *Server, arg []byte, lang string) erro
 	c.opts.Echo = false
 	c.opts.Pedantic = false
 
+	c.mu.Lock()
+	c.leaf.remoteID = proto.Name
+	c.mu.Unlock()
+
 	// Create and initialize the smap since we know our bound account now.
 	s.initLeafNodeSmap(c)
 
@@ -1354,6 +1363,10 @@ func (c *client) processInboundLeafMsg(msg []byte) {
 		return
 	}
 
+	if hs := srv.hotSubs; hs != nil {
+		c.sampleHotSubject(hs, acc, c.pa.subject, c.pa.size)
+	}
+
 	// Check to see if we need to map/route to another account.
 	if acc.imports.services != nil {
 		c.checkForImportServices(acc, msg)
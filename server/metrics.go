@@ -0,0 +1,599 @@
+// Copyright 2019 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Upper bounds, in seconds, of the buckets of the service latency histograms.
+var latencyBuckets = []float64{
+	0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10,
+}
+
+// latencyHistogram is a cumulative histogram of latencies.
+type latencyHistogram struct {
+	counts []uint64 // per bucket, the last one is +Inf
+	count  uint64
+	sum    time.Duration
+	max    time.Duration
+}
+
+func (h *latencyHistogram) observe(d time.Duration) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(latencyBuckets)+1)
+	}
+	secs := d.Seconds()
+	i := sort.SearchFloat64s(latencyBuckets, secs)
+	h.counts[i]++
+	h.count++
+	h.sum += d
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// Returns the estimated q quantile of the latencies, interpolating
+// linearly within its bucket. It never exceeds the maximum latency.
+func (h *latencyHistogram) quantile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	rank := q * float64(h.count)
+	var cumulative uint64
+	for i, n := range h.counts {
+		if n == 0 || float64(cumulative+n) < rank {
+			cumulative += n
+			continue
+		}
+		if i == len(latencyBuckets) {
+			break
+		}
+		var lower float64
+		if i > 0 {
+			lower = latencyBuckets[i-1]
+		}
+		secs := lower + (latencyBuckets[i]-lower)*(rank-float64(cumulative))/float64(n)
+		if d := time.Duration(secs * float64(time.Second)); d < h.max {
+			return d
+		}
+		break
+	}
+	return h.max
+}
+
+func (h *latencyHistogram) percentiles() LatencyPercentiles {
+	return LatencyPercentiles{
+		Count: h.count,
+		P50:   h.quantile(0.5),
+		P90:   h.quantile(0.9),
+		P99:   h.quantile(0.99),
+		Max:   h.max,
+	}
+}
+
+// LatencyPercentiles are the percentiles of latencies, estimated from
+// their histogram, and the maximum latency.
+type LatencyPercentiles struct {
+	Count uint64        `json:"count"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+// NATSLatencyPercentiles are the percentiles of the NATS latencies.
+type NATSLatencyPercentiles struct {
+	Requestor LatencyPercentiles `json:"req"`
+	Responder LatencyPercentiles `json:"resp"`
+	System    LatencyPercentiles `json:"sys"`
+}
+
+// ServiceLatencyStats are the aggregated latencies of a tracked service
+// export, from Start to End. When published on the results subject
+// suffixed with ".STATS", they cover the latencies measured since the
+// previous interval. Statuses counts the requests by outcome, only the
+// ones that got a response being part of the latencies.
+type ServiceLatencyStats struct {
+	Server         *ServerInfo            `json:"server,omitempty"`
+	Service        string                 `json:"service"`
+	Start          time.Time              `json:"start"`
+	End            time.Time              `json:"end"`
+	Statuses       map[string]uint64      `json:"statuses,omitempty"`
+	ServiceLatency LatencyPercentiles     `json:"svc"`
+	NATSLatency    NATSLatencyPercentiles `json:"nats"`
+	TotalLatency   LatencyPercentiles     `json:"total"`
+}
+
+// latencyHistograms are the histograms of the components of the latency
+// of a service.
+type latencyHistograms struct {
+	start     time.Time
+	statuses  map[string]uint64
+	total     latencyHistogram
+	svc       latencyHistogram
+	requestor latencyHistogram
+	responder latencyHistogram
+	system    latencyHistogram
+}
+
+func (lh *latencyHistograms) observe(sl *ServiceLatency) {
+	if lh.statuses == nil {
+		lh.statuses = make(map[string]uint64)
+	}
+	lh.statuses[sl.Status]++
+	if sl.Status != LatencyStatusOK {
+		return
+	}
+	lh.total.observe(sl.TotalLatency)
+	lh.svc.observe(sl.ServiceLatency)
+	lh.requestor.observe(sl.NATSLatency.Requestor)
+	lh.responder.observe(sl.NATSLatency.Responder)
+	lh.system.observe(sl.NATSLatency.System)
+}
+
+func (lh *latencyHistograms) stats(service string, end time.Time) *ServiceLatencyStats {
+	return &ServiceLatencyStats{
+		Service:        service,
+		Start:          lh.start,
+		End:            end,
+		Statuses:       lh.copyStatuses(),
+		ServiceLatency: lh.svc.percentiles(),
+		NATSLatency: NATSLatencyPercentiles{
+			Requestor: lh.requestor.percentiles(),
+			Responder: lh.responder.percentiles(),
+			System:    lh.system.percentiles(),
+		},
+		TotalLatency: lh.total.percentiles(),
+	}
+}
+
+func (lh *latencyHistograms) copyStatuses() map[string]uint64 {
+	if len(lh.statuses) == 0 {
+		return nil
+	}
+	statuses := make(map[string]uint64, len(lh.statuses))
+	for status, n := range lh.statuses {
+		statuses[status] = n
+	}
+	return statuses
+}
+
+// Returns a copy of the histograms that does not share their buckets.
+func (lh *latencyHistograms) clone() *latencyHistograms {
+	c := *lh
+	c.statuses = lh.copyStatuses()
+	for _, h := range []*latencyHistogram{&c.total, &c.svc, &c.requestor, &c.responder, &c.system} {
+		h.counts = append([]uint64(nil), h.counts...)
+	}
+	return &c
+}
+
+// latencyStats holds the latency histograms of a tracked service export,
+// since it is tracked and since the last published interval.
+type latencyStats struct {
+	sync.Mutex
+	service string
+	latencyHistograms
+	ival latencyHistograms
+}
+
+// Returns the latency stats for the given results subject, creating them
+// for the service if needed.
+// Lock should be held.
+func (a *Account) latencyStatsForService(service, results string) *latencyStats {
+	if a.lstats == nil {
+		a.lstats = make(map[string]*latencyStats)
+	}
+	ls := a.lstats[results]
+	if ls == nil || ls.service != service {
+		now := time.Now()
+		ls = &latencyStats{service: service}
+		ls.start, ls.ival.start = now, now
+		a.lstats[results] = ls
+	}
+	return ls
+}
+
+// pruneLatencyStats drops the latency stats of services that are no longer
+// tracked, for instance after the exports were replaced by new claims.
+func (a *Account) pruneLatencyStats() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for results, ls := range a.lstats {
+		se := a.exports.services[ls.service]
+		if se == nil || se.latency == nil || se.latency.subject != results {
+			delete(a.lstats, results)
+		}
+	}
+}
+
+// recordLatency adds a service latency measurement that is being reported
+// on the given results subject to the histograms.
+func (a *Account) recordLatency(results string, sl *ServiceLatency) {
+	a.mu.RLock()
+	ls := a.lstats[results]
+	a.mu.RUnlock()
+	if ls == nil {
+		return
+	}
+	ls.Lock()
+	ls.observe(sl)
+	ls.ival.observe(sl)
+	ls.Unlock()
+}
+
+// reportLatency records a service latency measurement and publishes it
+// on the results subject, unless the server is configured to only
+// publish the aggregated latencies.
+func (a *Account) reportLatency(results string, sl *ServiceLatency) {
+	a.recordLatency(results, sl)
+	if s := a.srv; s != nil && !s.getOpts().LatencyStats.NoSamples {
+		s.sendInternalAccountMsg(a, results, sl)
+	}
+}
+
+// Returns the latency stats since the service is tracked.
+func (ls *latencyStats) stats() *ServiceLatencyStats {
+	ls.Lock()
+	defer ls.Unlock()
+	return ls.latencyHistograms.stats(ls.service, time.Now())
+}
+
+// Returns the latency stats since the last interval, and starts a new one.
+// It returns nil if no latency was measured during the interval.
+func (ls *latencyStats) intervalStats(now time.Time) *ServiceLatencyStats {
+	ls.Lock()
+	defer ls.Unlock()
+	if len(ls.ival.statuses) == 0 {
+		ls.ival.start = now
+		return nil
+	}
+	st := ls.ival.stats(ls.service, now)
+	ls.ival = latencyHistograms{start: now}
+	return st
+}
+
+// promFamily is a metric family in the Prometheus text format.
+type promFamily struct {
+	name    string
+	help    string
+	typ     string
+	samples []string
+}
+
+// promWriter collects metrics and renders them in the Prometheus text
+// exposition format, keeping the samples of a family together.
+type promWriter struct {
+	families []*promFamily
+	byName   map[string]*promFamily
+}
+
+func newPromWriter() *promWriter {
+	return &promWriter{byName: make(map[string]*promFamily)}
+}
+
+func (w *promWriter) family(name, typ, help string) *promFamily {
+	f := w.byName[name]
+	if f == nil {
+		f = &promFamily{name: name, help: help, typ: typ}
+		w.byName[name] = f
+		w.families = append(w.families, f)
+	}
+	return f
+}
+
+// Formats the labels, given as name and value pairs.
+func promLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(labels[i])
+		b.WriteString(`="`)
+		v := strings.Replace(labels[i+1], `\`, `\\`, -1)
+		v = strings.Replace(v, "\n", `\n`, -1)
+		b.WriteString(strings.Replace(v, `"`, `\"`, -1))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func promValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func (w *promWriter) add(name, typ, help string, v float64, labels ...string) {
+	f := w.family(name, typ, help)
+	f.samples = append(f.samples, name+promLabels(labels)+" "+promValue(v))
+}
+
+func (w *promWriter) gauge(name, help string, v float64, labels ...string) {
+	w.add(name, "gauge", help, v, labels...)
+}
+
+func (w *promWriter) counter(name, help string, v float64, labels ...string) {
+	w.add(name, "counter", help, v, labels...)
+}
+
+func (w *promWriter) histogram(name, help string, h *latencyHistogram, labels ...string) {
+	f := w.family(name, "histogram", help)
+	var cumulative uint64
+	for i := 0; i <= len(latencyBuckets); i++ {
+		if h.counts != nil {
+			cumulative += h.counts[i]
+		}
+		le := math.Inf(1)
+		if i < len(latencyBuckets) {
+			le = latencyBuckets[i]
+		}
+		bl := append(append([]string(nil), labels...), "le", promValue(le))
+		f.samples = append(f.samples, fmt.Sprintf("%s_bucket%s %d", name, promLabels(bl), cumulative))
+	}
+	f.samples = append(f.samples, fmt.Sprintf("%s_sum%s %s", name, promLabels(labels), promValue(h.sum.Seconds())))
+	f.samples = append(f.samples, fmt.Sprintf("%s_count%s %d", name, promLabels(labels), h.count))
+}
+
+func (w *promWriter) bytes() []byte {
+	var b bytes.Buffer
+	for _, f := range w.families {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.typ)
+		for _, s := range f.samples {
+			b.WriteString(s)
+			b.WriteByte('\n')
+		}
+	}
+	return b.Bytes()
+}
+
+// Metrics returns the server metrics in the Prometheus text format.
+func (s *Server) Metrics() []byte {
+	w := newPromWriter()
+	s.serverMetrics(w)
+	s.accountMetrics(w)
+	s.routeMetrics(w)
+	s.gatewayMetrics(w)
+	s.leafMetrics(w)
+	return w.bytes()
+}
+
+func (s *Server) serverMetrics(w *promWriter) {
+	v, err := s.Varz(nil)
+	if err != nil {
+		return
+	}
+	sid := []string{"server_id", v.ID}
+	w.gauge("nats_server_info", "Server information.", 1,
+		"server_id", v.ID, "version", v.Version, "go", v.GoVersion)
+	w.gauge("nats_server_start_time_seconds", "Start time of the server since unix epoch.", float64(v.Start.Unix()), sid...)
+	w.gauge("nats_server_mem_bytes", "Resident memory of the server.", float64(v.Mem), sid...)
+	w.gauge("nats_server_cpu_percent", "CPU usage of the server.", v.CPU, sid...)
+	w.gauge("nats_server_connections", "Current number of client connections.", float64(v.Connections), sid...)
+	w.counter("nats_server_connections_total", "Total number of client connections.", float64(v.TotalConnections), sid...)
+	w.gauge("nats_server_max_connections", "Maximum number of client connections.", float64(v.MaxConn), sid...)
+	w.gauge("nats_server_routes", "Current number of routes.", float64(v.Routes), sid...)
+	w.gauge("nats_server_remotes", "Current number of remote servers.", float64(v.Remotes), sid...)
+	w.gauge("nats_server_leafnodes", "Current number of leafnode connections.", float64(v.Leafs), sid...)
+	w.gauge("nats_server_subscriptions", "Current number of subscriptions.", float64(v.Subscriptions), sid...)
+	w.counter("nats_server_in_msgs_total", "Messages received by the server.", float64(v.InMsgs), sid...)
+	w.counter("nats_server_out_msgs_total", "Messages sent by the server.", float64(v.OutMsgs), sid...)
+	w.counter("nats_server_in_bytes_total", "Bytes received by the server.", float64(v.InBytes), sid...)
+	w.counter("nats_server_out_bytes_total", "Bytes sent by the server.", float64(v.OutBytes), sid...)
+	w.counter("nats_server_slow_consumers_total", "Connections closed as slow consumers.", float64(v.SlowConsumers), sid...)
+	w.counter("nats_server_max_connections_per_ip_rejections_total", "Connections rejected by the per source address limit.", float64(v.ConnPerIPRejects), sid...)
+	w.counter("nats_server_source_address_rejections_total", "Connections rejected by source address restrictions.", float64(v.SourceRejects), sid...)
+	paths := make([]string, 0, len(v.HTTPReqStats))
+	for path := range v.HTTPReqStats {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		w.counter("nats_server_http_requests_total", "Requests to the monitoring endpoints.", float64(v.HTTPReqStats[path]),
+			"server_id", v.ID, "path", path)
+	}
+}
+
+func (s *Server) accountMetrics(w *promWriter) {
+	var accs []*Account
+	s.accounts.Range(func(k, v interface{}) bool {
+		accs = append(accs, v.(*Account))
+		return true
+	})
+	sort.Slice(accs, func(i, j int) bool { return accs[i].Name < accs[j].Name })
+
+	for _, acc := range accs {
+		acc.mu.RLock()
+		name := acc.Name
+		conns := acc.numLocalConnections()
+		leafs := acc.numLocalLeafNodes()
+		var ss *SublistStats
+		if acc.sl != nil {
+			ss = acc.sl.Stats()
+		}
+		lstats := make([]*latencyStats, 0, len(acc.lstats))
+		for _, ls := range acc.lstats {
+			lstats = append(lstats, ls)
+		}
+		acc.mu.RUnlock()
+
+		ats := acc.TrafficStats()
+
+		l := []string{"account", name}
+		w.gauge("nats_account_connections", "Current number of client connections of the account.", float64(conns), l...)
+		w.gauge("nats_account_leafnodes", "Current number of leafnode connections of the account.", float64(leafs), l...)
+		for _, src := range []struct {
+			name string
+			ts   TrafficStats
+		}{
+			{"clients", ats.Clients},
+			{"routes", ats.Routes},
+			{"gateways", ats.Gateways},
+			{"leafnodes", ats.LeafNodes},
+		} {
+			sl := []string{"account", name, "source", src.name}
+			w.counter("nats_account_in_msgs_total", "Messages of the account received, by kind of connection.", float64(src.ts.Received.Msgs), sl...)
+			w.counter("nats_account_out_msgs_total", "Messages of the account sent, by kind of connection.", float64(src.ts.Sent.Msgs), sl...)
+			w.counter("nats_account_in_bytes_total", "Bytes of the account received, by kind of connection.", float64(src.ts.Received.Bytes), sl...)
+			w.counter("nats_account_out_bytes_total", "Bytes of the account sent, by kind of connection.", float64(src.ts.Sent.Bytes), sl...)
+		}
+		if ss != nil {
+			w.gauge("nats_account_subscriptions", "Current number of subscriptions of the account.", float64(ss.NumSubs), l...)
+			w.gauge("nats_account_sublist_cache_entries", "Entries in the sublist cache of the account.", float64(ss.NumCache), l...)
+			w.counter("nats_account_sublist_inserts_total", "Subscriptions inserted in the sublist of the account.", float64(ss.NumInserts), l...)
+			w.counter("nats_account_sublist_removes_total", "Subscriptions removed from the sublist of the account.", float64(ss.NumRemoves), l...)
+			w.counter("nats_account_sublist_matches_total", "Matches done in the sublist of the account.", float64(ss.NumMatches), l...)
+			w.gauge("nats_account_sublist_cache_hit_ratio", "Cache hit ratio of the sublist of the account.", ss.CacheHitRate, l...)
+			w.gauge("nats_account_sublist_max_fanout", "Maximum fanout of the sublist cache of the account.", float64(ss.MaxFanout), l...)
+			w.gauge("nats_account_sublist_avg_fanout", "Average fanout of the sublist cache of the account.", ss.AvgFanout, l...)
+		}
+
+		sort.Slice(lstats, func(i, j int) bool { return lstats[i].service < lstats[j].service })
+		for _, ls := range lstats {
+			ls.Lock()
+			lh := ls.latencyHistograms.clone()
+			ls.Unlock()
+			sl := []string{"account", name, "service", ls.service}
+			w.histogram("nats_service_latency_seconds", "Total latency of the requests to the service.", &lh.total, sl...)
+			w.histogram("nats_service_processing_seconds", "Time spent by the service to process requests.", &lh.svc, sl...)
+			statuses := make([]string, 0, len(lh.statuses))
+			for status := range lh.statuses {
+				statuses = append(statuses, status)
+			}
+			sort.Strings(statuses)
+			for _, status := range statuses {
+				w.counter("nats_service_requests_total", "Tracked requests to the service, by outcome.", float64(lh.statuses[status]),
+					"account", name, "service", ls.service, "status", status)
+			}
+			for _, c := range []struct {
+				name string
+				h    *latencyHistogram
+			}{
+				{"total", &lh.total},
+				{"svc", &lh.svc},
+				{"requestor", &lh.requestor},
+				{"responder", &lh.responder},
+				{"system", &lh.system},
+			} {
+				p := c.h.percentiles()
+				for _, q := range []struct {
+					name string
+					d    time.Duration
+				}{{"0.5", p.P50}, {"0.9", p.P90}, {"0.99", p.P99}} {
+					w.gauge("nats_service_latency_percentile_seconds", "Estimated percentiles of the latencies of the service, by component.",
+						q.d.Seconds(), "account", name, "service", ls.service, "latency", c.name, "quantile", q.name)
+				}
+				w.gauge("nats_service_latency_max_seconds", "Maximum latency of the service, by component.",
+					c.h.max.Seconds(), "account", name, "service", ls.service, "latency", c.name)
+			}
+		}
+	}
+}
+
+func (s *Server) routeMetrics(w *promWriter) {
+	rz, err := s.Routez(nil)
+	if err != nil {
+		return
+	}
+	for _, r := range rz.Routes {
+		l := []string{"rid", strconv.FormatUint(r.Rid, 10), "remote_id", r.RemoteID}
+		w.counter("nats_route_in_msgs_total", "Messages received from the route.", float64(r.InMsgs), l...)
+		w.counter("nats_route_out_msgs_total", "Messages sent to the route.", float64(r.OutMsgs), l...)
+		w.counter("nats_route_in_bytes_total", "Bytes received from the route.", float64(r.InBytes), l...)
+		w.counter("nats_route_out_bytes_total", "Bytes sent to the route.", float64(r.OutBytes), l...)
+		w.gauge("nats_route_pending_bytes", "Bytes pending to be sent to the route.", float64(r.Pending), l...)
+		w.gauge("nats_route_subscriptions", "Subscriptions of the route.", float64(r.NumSubs), l...)
+	}
+}
+
+func (s *Server) gatewayMetrics(w *promWriter) {
+	gz, err := s.Gatewayz(nil)
+	if err != nil {
+		return
+	}
+	conn := func(gw, dir string, ci *ConnInfo) {
+		if ci == nil {
+			return
+		}
+		l := []string{"gateway", gw, "direction", dir, "cid", strconv.FormatUint(ci.Cid, 10)}
+		w.counter("nats_gateway_in_msgs_total", "Messages received from the gateway.", float64(ci.InMsgs), l...)
+		w.counter("nats_gateway_out_msgs_total", "Messages sent to the gateway.", float64(ci.OutMsgs), l...)
+		w.counter("nats_gateway_in_bytes_total", "Bytes received from the gateway.", float64(ci.InBytes), l...)
+		w.counter("nats_gateway_out_bytes_total", "Bytes sent to the gateway.", float64(ci.OutBytes), l...)
+		w.gauge("nats_gateway_pending_bytes", "Bytes pending to be sent to the gateway.", float64(ci.Pending), l...)
+	}
+	names := make([]string, 0, len(gz.OutboundGateways))
+	for name := range gz.OutboundGateways {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		conn(name, "outbound", gz.OutboundGateways[name].Connection)
+	}
+	names = names[:0]
+	for name := range gz.InboundGateways {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, rgw := range gz.InboundGateways[name] {
+			conn(name, "inbound", rgw.Connection)
+		}
+	}
+}
+
+func (s *Server) leafMetrics(w *promWriter) {
+	lz, err := s.Leafz(nil)
+	if err != nil {
+		return
+	}
+	for _, ln := range lz.Leafs {
+		l := []string{"account", ln.Account, "ip", ln.IP, "port", strconv.Itoa(ln.Port)}
+		w.counter("nats_leafnode_in_msgs_total", "Messages received from the leafnode.", float64(ln.InMsgs), l...)
+		w.counter("nats_leafnode_out_msgs_total", "Messages sent to the leafnode.", float64(ln.OutMsgs), l...)
+		w.counter("nats_leafnode_in_bytes_total", "Bytes received from the leafnode.", float64(ln.InBytes), l...)
+		w.counter("nats_leafnode_out_bytes_total", "Bytes sent to the leafnode.", float64(ln.OutBytes), l...)
+		w.gauge("nats_leafnode_subscriptions", "Subscriptions of the leafnode.", float64(ln.NumSubs), l...)
+	}
+}
+
+// HandleMetrics process HTTP requests for the metrics in the Prometheus
+// text exposition format.
+func (s *Server) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.httpReqStats[MetricsPath]++
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(s.Metrics())
+}
@@ -27,6 +27,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/nats-io/jwt"
 	"github.com/nats-io/nats-server/v2/server/pse"
 )
 
@@ -80,6 +81,28 @@ type ConnzOptions struct {
 
 	// Filter by account.
 	Account string `json:"acc"`
+
+	// Filter closed connections by the time they were closed, From being
+	// inclusive and To exclusive. When the closed connections are
+	// persisted, the whole on-disk history is searched.
+	From time.Time `json:"from,omitempty"`
+	To   time.Time `json:"to,omitempty"`
+
+	// Filter by interest, connections having a subscription that could
+	// receive messages published on subjects matching this subject.
+	FilterSubject string `json:"filter_subject,omitempty"`
+
+	// Filter by client name, language and version. The version can be
+	// prefixed by a comparison operator, such as "<1.9".
+	Name    string `json:"name,omitempty"`
+	Lang    string `json:"lang,omitempty"`
+	Version string `json:"version,omitempty"`
+
+	// Filter by IP address or CIDR block.
+	IP string `json:"ip,omitempty"`
+
+	// Filter closed connections by reason.
+	Reason string `json:"reason,omitempty"`
 }
 
 // ConnState is for filtering states of connections. We will only have two, open and closed.
@@ -142,6 +165,9 @@ func (s *Server) Connz(opts *ConnzOptions) (*Connz, error) {
 		state   = ConnOpen
 		user    string
 		acc     string
+		from    time.Time
+		to      time.Time
+		filter  *connzFilter
 	)
 
 	if opts != nil {
@@ -183,6 +209,16 @@ func (s *Server) Connz(opts *ConnzOptions) (*Connz, error) {
 		if sortOpt == ByReason && state != ConnClosed {
 			return nil, fmt.Errorf("sort by reason only valid on closed connections")
 		}
+		// And so is a time range.
+		from, to = opts.From, opts.To
+		if (!from.IsZero() || !to.IsZero()) && state != ConnClosed {
+			return nil, fmt.Errorf("time range only valid on closed connections")
+		}
+
+		var err error
+		if filter, err = newConnzFilter(opts); err != nil {
+			return nil, err
+		}
 
 		// If searching by CID
 		if opts.CID > 0 {
@@ -214,8 +250,20 @@ func (s *Server) Connz(opts *ConnzOptions) (*Connz, error) {
 	case ConnOpen:
 		c.Total = len(s.clients)
 	case ConnClosed:
-		c.Total = s.closed.len()
-		closedClients = s.closed.closedClients()
+		if from.IsZero() && to.IsZero() {
+			closedClients = s.closed.closedClients()
+		} else if s.closedLog == nil {
+			closedClients = s.closed.closedClientsInRange(from, to)
+		} else {
+			// Read the history without holding the server lock.
+			s.mu.Unlock()
+			var err error
+			closedClients, err = s.closedLog.closedClientsInRange(from, to)
+			if err != nil {
+				return nil, fmt.Errorf("error reading closed connections log: %v", err)
+			}
+			s.mu.Lock()
+		}
 		c.Total = len(closedClients)
 	case ConnAll:
 		closedClients = s.closed.closedClients()
@@ -274,6 +322,15 @@ func (s *Server) Connz(opts *ConnzOptions) (*Connz, error) {
 				if user != "" && client.opts.Username != user {
 					continue
 				}
+				// Then the filters that need the client's lock.
+				if filter != nil {
+					client.mu.Lock()
+					match := filter.matchClient(client)
+					client.mu.Unlock()
+					if !match {
+						continue
+					}
+				}
 				openClients = append(openClients, client)
 			}
 		}
@@ -327,6 +384,9 @@ func (s *Server) Connz(opts *ConnzOptions) (*Connz, error) {
 		if user != "" && cc.user != user {
 			continue
 		}
+		if filter != nil && !filter.matchClosed(cc) {
+			continue
+		}
 
 		// Copy if needed for any changes to the ConnInfo
 		if needCopy {
@@ -524,6 +584,20 @@ func decodeState(w http.ResponseWriter, r *http.Request) (ConnState, error) {
 	return 0, err
 }
 
+func decodeTime(w http.ResponseWriter, r *http.Request, param string) (time.Time, error) {
+	str := r.URL.Query().Get(param)
+	if str == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, str)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("Error decoding time for '%s': %v", param, err)))
+		return time.Time{}, err
+	}
+	return t, nil
+}
+
 // HandleConnz process HTTP requests for connection information.
 func (s *Server) HandleConnz(w http.ResponseWriter, r *http.Request) {
 	sortOpt := SortOpt(r.URL.Query().Get("sort"))
@@ -552,8 +626,18 @@ func (s *Server) HandleConnz(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	from, err := decodeTime(w, r, "from")
+	if err != nil {
+		return
+	}
+	to, err := decodeTime(w, r, "to")
+	if err != nil {
+		return
+	}
+
 	user := r.URL.Query().Get("user")
 	acc := r.URL.Query().Get("acc")
+	q := r.URL.Query()
 
 	connzOpts := &ConnzOptions{
 		Sort:          sortOpt,
@@ -565,6 +649,14 @@ func (s *Server) HandleConnz(w http.ResponseWriter, r *http.Request) {
 		State:         state,
 		User:          user,
 		Account:       acc,
+		From:          from,
+		To:            to,
+		FilterSubject: q.Get("filter_subject"),
+		Name:          q.Get("name"),
+		Lang:          q.Get("lang"),
+		Version:       q.Get("version"),
+		IP:            q.Get("ip"),
+		Reason:        q.Get("reason"),
 	}
 
 	s.mu.Lock()
@@ -706,11 +798,15 @@ func (s *Server) HandleRoutez(w http.ResponseWriter, r *http.Request) {
 
 // Subsz represents detail information on current connections.
 type Subsz struct {
+	ID  string    `json:"server_id"`
+	Now time.Time `json:"now"`
 	*SublistStats
 	Total  int         `json:"total"`
 	Offset int         `json:"offset"`
 	Limit  int         `json:"limit"`
 	Subs   []SubDetail `json:"subscriptions_list,omitempty"`
+	// Top lists the subjects with the most messages, when requested.
+	Top []*HotSubject `json:"top,omitempty"`
 }
 
 // SubszOptions are the options passed to Subsz.
@@ -729,6 +825,10 @@ type SubszOptions struct {
 	// Test the list against this subject. Needs to be literal since it signifies a publish subject.
 	// We will only return subscriptions that would match if a message was sent to this subject.
 	Test string `json:"test,omitempty"`
+
+	// Top is the number of hot subjects to return, with the most messages
+	// first. Requires hot subjects tracking to be enabled.
+	Top int `json:"top,omitempty"`
 }
 
 // SubDetail is for verbose information for subscriptions.
@@ -749,6 +849,7 @@ func (s *Server) Subsz(opts *SubszOptions) (*Subsz, error) {
 		offset    int
 		limit     = DefaultSubListSize
 		testSub   = ""
+		top       int
 	)
 
 	if opts != nil {
@@ -768,10 +869,24 @@ func (s *Server) Subsz(opts *SubszOptions) (*Subsz, error) {
 				return nil, fmt.Errorf("invalid test subject, must be valid publish subject: %s", testSub)
 			}
 		}
+		top = opts.Top
+		if top > 0 && s.hotSubs == nil {
+			return nil, fmt.Errorf("hot subjects tracking is not enabled")
+		}
 	}
 
 	// FIXME(dlc) - Make account aware.
-	sz := &Subsz{s.gacc.sl.Stats(), 0, offset, limit, nil}
+	sz := &Subsz{
+		ID:           s.ID(),
+		Now:          time.Now(),
+		SublistStats: s.gacc.sl.Stats(),
+		Offset:       offset,
+		Limit:        limit,
+	}
+
+	if top > 0 {
+		sz.Top = s.hotSubs.top(top)
+	}
 
 	if subdetail {
 		// Now add in subscription's details
@@ -839,6 +954,10 @@ func (s *Server) HandleSubsz(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		return
 	}
+	top, err := decodeInt(w, r, "top")
+	if err != nil {
+		return
+	}
 	testSub := r.URL.Query().Get("test")
 
 	subszOpts := &SubszOptions{
@@ -846,6 +965,7 @@ func (s *Server) HandleSubsz(w http.ResponseWriter, r *http.Request) {
 		Offset:        offset,
 		Limit:         limit,
 		Test:          testSub,
+		Top:           top,
 	}
 
 	st, err := s.Subsz(subszOpts)
@@ -857,7 +977,7 @@ func (s *Server) HandleSubsz(w http.ResponseWriter, r *http.Request) {
 
 	var b []byte
 
-	if len(st.Subs) == 0 {
+	if len(st.Subs) == 0 && len(st.Top) == 0 {
 		b, err = json.MarshalIndent(st.SublistStats, "", "  ")
 	} else {
 		b, err = json.MarshalIndent(st, "", "  ")
@@ -870,6 +990,73 @@ func (s *Server) HandleSubsz(w http.ResponseWriter, r *http.Request) {
 	ResponseHandler(w, r, b)
 }
 
+// Banz represents the source addresses and users that are temporarily
+// banned after repeated authentication failures.
+type Banz struct {
+	ID      string     `json:"server_id"`
+	Now     time.Time  `json:"now"`
+	Cleared int        `json:"cleared,omitempty"`
+	Bans    []*BanInfo `json:"bans"`
+}
+
+// BanzOptions are the options passed to Banz.
+type BanzOptions struct {
+	// ClearIP lifts the ban and forgets the failures of this source address.
+	ClearIP string `json:"clear_ip,omitempty"`
+
+	// ClearUser lifts the ban and forgets the failures of this user.
+	ClearUser string `json:"clear_user,omitempty"`
+
+	// ClearAll lifts all bans and forgets all failures.
+	ClearAll bool `json:"clear_all,omitempty"`
+}
+
+// BanInfo has detailed information on a ban.
+type BanInfo struct {
+	Type    string    `json:"type"`
+	Name    string    `json:"name"`
+	Bans    int       `json:"bans"`
+	Expires time.Time `json:"expires"`
+}
+
+// Banz returns a Banz struct containing the bans in effect, after
+// clearing the ones requested in the options.
+func (s *Server) Banz(opts *BanzOptions) (*Banz, error) {
+	b := &Banz{ID: s.ID()}
+	if opts != nil {
+		b.Cleared = s.clearAuthBans(opts.ClearIP, opts.ClearUser, opts.ClearAll)
+	}
+	b.Bans = s.authBans()
+	if b.Bans == nil {
+		b.Bans = []*BanInfo{}
+	}
+	b.Now = time.Now()
+	return b, nil
+}
+
+// HandleBanz processes HTTP requests for the authentication bans.
+// The endpoint is read-only, bans can only be cleared with a request
+// on the system account.
+func (s *Server) HandleBanz(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.httpReqStats[BanzPath]++
+	s.mu.Unlock()
+
+	banz, err := s.Banz(nil)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	b, err := json.MarshalIndent(banz, "", "  ")
+	if err != nil {
+		s.Errorf("Error marshaling response to /banz request: %v", err)
+	}
+
+	// Handle response
+	ResponseHandler(w, r, b)
+}
+
 // HandleStacksz processes HTTP requests for getting stacks
 func (s *Server) HandleStacksz(w http.ResponseWriter, r *http.Request) {
 	// Do not get any lock here that would prevent getting the stacks
@@ -905,6 +1092,7 @@ type Varz struct {
 	IP                string            `json:"ip,omitempty"`
 	ClientConnectURLs []string          `json:"connect_urls,omitempty"`
 	MaxConn           int               `json:"max_connections"`
+	MaxConnPerIP      int               `json:"max_connections_per_ip,omitempty"`
 	MaxSubs           int               `json:"max_subscriptions,omitempty"`
 	PingInterval      time.Duration     `json:"ping_interval"`
 	MaxPingsOut       int               `json:"ping_max"`
@@ -936,6 +1124,8 @@ type Varz struct {
 	InBytes           int64             `json:"in_bytes"`
 	OutBytes          int64             `json:"out_bytes"`
 	SlowConsumers     int64             `json:"slow_consumers"`
+	ConnPerIPRejects  int64             `json:"max_connections_per_ip_rejections"`
+	SourceRejects     int64             `json:"source_address_rejections"`
 	Subscriptions     uint32            `json:"subscriptions"`
 	HTTPReqStats      map[string]uint64 `json:"http_req_stats"`
 	ConfigLoadTime    time.Time         `json:"config_load_time"`
@@ -1039,6 +1229,10 @@ func (s *Server) HandleRoot(w http.ResponseWriter, r *http.Request) {
 	<a href=/gatewayz>gatewayz</a><br/>
 	<a href=/leafz>leafz</a><br/>
 	<a href=/subsz>subsz</a><br/>
+	<a href=/banz>banz</a><br/>
+	<a href=/accountz>accountz</a><br/>
+	<a href=/healthz>healthz</a><br/>
+	<a href=/metrics>metrics</a><br/>
     <br/>
     <a href=https://nats-io.github.io/docs/nats_server/monitoring.html>help</a>
   </body>
@@ -1162,6 +1356,7 @@ func (s *Server) updateVarzConfigReloadableFields(v *Varz) {
 	v.TLSRequired = info.TLSRequired
 	v.TLSVerify = info.TLSVerify
 	v.MaxConn = opts.MaxConn
+	v.MaxConnPerIP = opts.MaxConnPerIP
 	v.PingInterval = opts.PingInterval
 	v.MaxPingsOut = opts.MaxPingsOut
 	v.AuthTimeout = opts.AuthTimeout
@@ -1202,6 +1397,8 @@ func (s *Server) updateVarzRuntimeFields(v *Varz, forceUpdate bool, pcpu float64
 	v.OutMsgs = atomic.LoadInt64(&s.outMsgs)
 	v.OutBytes = atomic.LoadInt64(&s.outBytes)
 	v.SlowConsumers = atomic.LoadInt64(&s.slowConsumers)
+	v.ConnPerIPRejects = atomic.LoadInt64(&s.ipLimitRejects)
+	v.SourceRejects = atomic.LoadInt64(&s.srcAddrRejects)
 	// FIXME(dlc) - make this multi-account aware.
 	v.Subscriptions = s.gacc.sl.Count()
 	v.HTTPReqStats = make(map[string]uint64, len(s.httpReqStats))
@@ -1695,6 +1892,554 @@ func (s *Server) HandleLeafz(w http.ResponseWriter, r *http.Request) {
 	ResponseHandler(w, r, b)
 }
 
+// Accountz represents detailed information on accounts.
+type Accountz struct {
+	ID            string         `json:"server_id"`
+	Now           time.Time      `json:"now"`
+	SystemAccount string         `json:"system_account,omitempty"`
+	NumAccounts   int            `json:"num_accounts"`
+	Accounts      []*AccountInfo `json:"accounts"`
+	Graph         *AccountGraph  `json:"graph,omitempty"`
+}
+
+// AccountzOptions are options passed to Accountz
+type AccountzOptions struct {
+	// Account filters by the account name, or the name in the account JWT.
+	Account string `json:"account"`
+	// Details includes imports, exports, revocations and JWT claims.
+	// They are always included when filtering by account.
+	Details bool `json:"details"`
+	// Graph includes the graph of the imports between the accounts.
+	Graph bool `json:"graph"`
+}
+
+// AccountGraph is the graph of the accounts, with the imports as edges.
+type AccountGraph struct {
+	Nodes []string            `json:"nodes"`
+	Edges []*AccountGraphEdge `json:"edges"`
+	// Cycle describes a cycle of service imports, in which requests loop.
+	// Cycles prevent a configuration from loading, but the ones formed by
+	// account JWTs, which can't be rejected, are only reported here and
+	// logged as a warning.
+	Cycle string `json:"service_import_cycle,omitempty"`
+}
+
+// AccountGraphEdge is an import of an account from an exporting account.
+type AccountGraphEdge struct {
+	Type     string `json:"type"`
+	Account  string `json:"account"`
+	Subject  string `json:"subject"`
+	Exporter string `json:"exporter"`
+	To       string `json:"to"`
+	Invalid  bool   `json:"invalid,omitempty"`
+}
+
+// AccountInfo has detailed information on an account.
+type AccountInfo struct {
+	Name           string               `json:"name"`
+	JWTName        string               `json:"jwt_name,omitempty"`
+	Issuer         string               `json:"issuer,omitempty"`
+	IsSystem       bool                 `json:"is_system,omitempty"`
+	Expired        bool                 `json:"expired"`
+	Expires        *time.Time           `json:"expires,omitempty"`
+	Updated        *time.Time           `json:"updated,omitempty"`
+	NumConns       int                  `json:"num_connections"`
+	NumRemoteConns int                  `json:"num_remote_connections"`
+	NumLeafs       int                  `json:"num_leafnodes"`
+	NumSubs        uint32               `json:"num_subscriptions"`
+	Limits         AccountLimitsInfo    `json:"limits"`
+	ResponseMaps   ResponseMapsInfo     `json:"response_maps"`
+	Stats          *AccountTrafficStats `json:"stats"`
+	Imports        []*ImportInfo        `json:"imports,omitempty"`
+	Exports        []*ExportInfo        `json:"exports,omitempty"`
+	RevokedUsers   map[string]time.Time `json:"revoked_users,omitempty"`
+	SigningKeys    []string             `json:"signing_keys,omitempty"`
+	Claims         *jwt.AccountClaims   `json:"jwt_claims,omitempty"`
+}
+
+// AccountLimitsInfo holds the limits of an account, -1 meaning no limit.
+type AccountLimitsInfo struct {
+	MaxConns    int `json:"max_connections"`
+	MaxLeafs    int `json:"max_leafnodes"`
+	MaxSubs     int `json:"max_subscriptions"`
+	MaxPayload  int `json:"max_payload"`
+	MaxAutoResp int `json:"max_auto_expire_response_maps"`
+	MaxResp     int `json:"max_response_maps"`
+}
+
+// ResponseMapsInfo holds the number of response mappings of an account.
+type ResponseMapsInfo struct {
+	AutoExpire int    `json:"auto_expire"`
+	Responses  int    `json:"responses"`
+	TTL        string `json:"auto_expire_ttl,omitempty"`
+}
+
+// ImportInfo has information on an account's stream or service import.
+type ImportInfo struct {
+	Type     string `json:"type"`
+	Account  string `json:"account"`
+	Subject  string `json:"subject"`
+	To       string `json:"to,omitempty"`
+	Prefix   string `json:"prefix,omitempty"`
+	RespType string `json:"response_type,omitempty"`
+	Tracking bool   `json:"tracking,omitempty"`
+	Invalid  bool   `json:"invalid,omitempty"`
+}
+
+// ExportInfo has information on an account's stream or service export.
+type ExportInfo struct {
+	Type     string       `json:"type"`
+	Subject  string       `json:"subject"`
+	TokenReq bool         `json:"token_required,omitempty"`
+	Approved []string     `json:"approved_accounts,omitempty"`
+	RespType string       `json:"response_type,omitempty"`
+	Latency  *LatencyInfo `json:"latency,omitempty"`
+}
+
+// LatencyInfo describes the latency tracking of a service export, and
+// the latencies measured since it is tracked.
+type LatencyInfo struct {
+	Sampling int                  `json:"sampling"`
+	Subject  string               `json:"results"`
+	Stats    *ServiceLatencyStats `json:"stats,omitempty"`
+}
+
+// Accountz returns an Accountz structure containing information about accounts.
+func (s *Server) Accountz(opts *AccountzOptions) (*Accountz, error) {
+	var filter string
+	var details, graph bool
+	if opts != nil {
+		filter, details = opts.Account, opts.Details || opts.Account != ""
+		graph = opts.Graph
+	}
+	var sysName string
+	if sacc := s.SystemAccount(); sacc != nil {
+		sysName = sacc.Name
+	}
+
+	var accs []*Account
+	s.accounts.Range(func(k, v interface{}) bool {
+		accs = append(accs, v.(*Account))
+		return true
+	})
+
+	infos := make([]*AccountInfo, 0, len(accs))
+	for _, acc := range accs {
+		ai := acc.accountInfo(details)
+		if filter != "" && ai.Name != filter && ai.JWTName != filter {
+			continue
+		}
+		ai.IsSystem = ai.Name == sysName
+		infos = append(infos, ai)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+
+	if filter != "" && len(infos) == 0 {
+		return nil, fmt.Errorf("account %q not found", filter)
+	}
+	az := &Accountz{
+		ID:            s.ID(),
+		Now:           time.Now(),
+		SystemAccount: sysName,
+		NumAccounts:   len(infos),
+		Accounts:      infos,
+	}
+	if graph {
+		var named *Account
+		if filter != "" {
+			for _, acc := range accs {
+				if acc.Name == infos[0].Name {
+					named = acc
+				}
+			}
+		}
+		az.Graph = accountGraph(accs, named)
+	}
+	return az, nil
+}
+
+// Returns the graph of the imports between the accounts, or only the
+// imports from or to the given account if not nil.
+func accountGraph(accs []*Account, acc *Account) *AccountGraph {
+	g := &AccountGraph{Nodes: []string{}, Edges: []*AccountGraphEdge{}}
+	nodes := make(map[string]struct{})
+	addEdge := func(e *AccountGraphEdge) {
+		if acc != nil && e.Account != acc.Name && e.Exporter != acc.Name {
+			return
+		}
+		g.Edges = append(g.Edges, e)
+		nodes[e.Account] = struct{}{}
+		nodes[e.Exporter] = struct{}{}
+	}
+	for _, a := range accs {
+		if acc == nil || a == acc {
+			nodes[a.Name] = struct{}{}
+		}
+		a.mu.RLock()
+		for _, si := range a.imports.streams {
+			// Skip the imports of the account's advisories.
+			if si.system {
+				continue
+			}
+			subject := si.prefix + si.from
+			if si.tr != nil {
+				subject = si.to
+			}
+			addEdge(&AccountGraphEdge{
+				Type:     jwt.Stream.String(),
+				Account:  a.Name,
+				Subject:  subject,
+				Exporter: si.acc.Name,
+				To:       si.from,
+				Invalid:  si.invalid,
+			})
+		}
+		for _, si := range a.imports.services {
+			// Skip the mappings created for responses.
+			if si.internal {
+				continue
+			}
+			addEdge(&AccountGraphEdge{
+				Type:     jwt.Service.String(),
+				Account:  a.Name,
+				Subject:  si.from,
+				Exporter: si.acc.Name,
+				To:       si.to,
+				Invalid:  si.invalid,
+			})
+		}
+		a.mu.RUnlock()
+	}
+	for name := range nodes {
+		g.Nodes = append(g.Nodes, name)
+	}
+	sort.Strings(g.Nodes)
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].Account != g.Edges[j].Account {
+			return g.Edges[i].Account < g.Edges[j].Account
+		}
+		return g.Edges[i].Subject < g.Edges[j].Subject
+	})
+	if cycle := findServiceImportCycle(serviceImportEdges(accs), acc); cycle != nil {
+		g.Cycle = importCyclePath(cycle)
+	}
+	return g
+}
+
+// Returns the information on this account reported by Accountz.
+func (a *Account) accountInfo(details bool) *AccountInfo {
+	a.mu.RLock()
+	ai := &AccountInfo{
+		Name:           a.Name,
+		JWTName:        a.nameTag,
+		Issuer:         a.Issuer,
+		Expired:        a.expired,
+		NumConns:       a.numLocalConnections(),
+		NumRemoteConns: int(a.nrclients),
+		NumLeafs:       a.numLocalLeafNodes(),
+		Limits: AccountLimitsInfo{
+			MaxConns:    int(a.mconns),
+			MaxLeafs:    int(a.mleafs),
+			MaxSubs:     int(a.msubs),
+			MaxPayload:  int(a.mpay),
+			MaxAutoResp: int(a.maxnae),
+			MaxResp:     int(a.maxnrm),
+		},
+		ResponseMaps: ResponseMapsInfo{
+			AutoExpire: int(a.nae),
+			Responses:  len(a.respMap),
+		},
+		Stats: a.TrafficStats(),
+	}
+	if a.sl != nil {
+		ai.NumSubs = a.sl.Count()
+	}
+	if a.maxaettl > 0 {
+		ai.ResponseMaps.TTL = a.maxaettl.String()
+	}
+	if !a.updated.IsZero() {
+		updated := a.updated
+		ai.Updated = &updated
+	}
+	claimJWT := a.claimJWT
+	if details {
+		ai.Imports = a.importsInfo()
+		ai.Exports = a.exportsInfo()
+		if len(a.usersRevoked) > 0 {
+			ai.RevokedUsers = make(map[string]time.Time, len(a.usersRevoked))
+			for pk, t := range a.usersRevoked {
+				ai.RevokedUsers[pk] = time.Unix(t, 0).UTC()
+			}
+		}
+		ai.SigningKeys = copyStrings(a.signingKeys)
+	}
+	a.mu.RUnlock()
+
+	if claimJWT != "" {
+		if ac, err := jwt.DecodeAccountClaims(claimJWT); err == nil {
+			if ac.Expires > 0 {
+				expires := time.Unix(ac.Expires, 0).UTC()
+				ai.Expires = &expires
+			}
+			if details {
+				ai.Claims = ac
+			}
+		}
+	}
+	return ai
+}
+
+// Returns the imports of the account, without the response mappings.
+// Lock should be held.
+func (a *Account) importsInfo() []*ImportInfo {
+	var imports []*ImportInfo
+	for _, si := range a.imports.streams {
+		// Skip the imports of the account's advisories.
+		if si.system {
+			continue
+		}
+		imports = append(imports, &ImportInfo{
+			Type:    jwt.Stream.String(),
+			Account: si.acc.Name,
+			Subject: si.from,
+			To:      si.to,
+			Prefix:  strings.TrimSuffix(si.prefix, tsep),
+			Invalid: si.invalid,
+		})
+	}
+	for _, si := range a.imports.services {
+		// Skip the mappings created for responses.
+		if si.internal {
+			continue
+		}
+		imports = append(imports, &ImportInfo{
+			Type:     jwt.Service.String(),
+			Account:  si.acc.Name,
+			Subject:  si.from,
+			To:       si.to,
+			RespType: si.rt.String(),
+			Tracking: si.latency != nil,
+			Invalid:  si.invalid,
+		})
+	}
+	sort.Slice(imports, func(i, j int) bool {
+		if imports[i].Type != imports[j].Type {
+			return imports[i].Type > imports[j].Type
+		}
+		return imports[i].Subject < imports[j].Subject
+	})
+	return imports
+}
+
+// Returns the exports of the account.
+// Lock should be held.
+func (a *Account) exportsInfo() []*ExportInfo {
+	approved := func(ea *exportAuth) []string {
+		if len(ea.approved) == 0 {
+			return nil
+		}
+		names := make([]string, 0, len(ea.approved))
+		for name := range ea.approved {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	}
+	var exports []*ExportInfo
+	for subj, se := range a.exports.streams {
+		ei := &ExportInfo{Type: jwt.Stream.String(), Subject: subj}
+		if se != nil {
+			ei.TokenReq, ei.Approved = se.tokenReq, approved(&se.exportAuth)
+		}
+		exports = append(exports, ei)
+	}
+	for subj, se := range a.exports.services {
+		ei := &ExportInfo{Type: jwt.Service.String(), Subject: subj, RespType: Singleton.String()}
+		if se != nil {
+			ei.TokenReq, ei.Approved = se.tokenReq, approved(&se.exportAuth)
+			ei.RespType = se.respType.String()
+			if se.latency != nil {
+				ei.Latency = &LatencyInfo{Sampling: int(se.latency.sampling), Subject: se.latency.subject}
+				if ls := a.lstats[se.latency.subject]; ls != nil {
+					ei.Latency.Stats = ls.stats()
+				}
+			}
+		}
+		exports = append(exports, ei)
+	}
+	sort.Slice(exports, func(i, j int) bool {
+		if exports[i].Type != exports[j].Type {
+			return exports[i].Type > exports[j].Type
+		}
+		return exports[i].Subject < exports[j].Subject
+	})
+	return exports
+}
+
+// HandleAccountz process HTTP requests for account information.
+func (s *Server) HandleAccountz(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.httpReqStats[AccountzPath]++
+	s.mu.Unlock()
+
+	details, err := decodeBool(w, r, "details")
+	if err != nil {
+		return
+	}
+	graph, err := decodeBool(w, r, "graph")
+	if err != nil {
+		return
+	}
+	opts := &AccountzOptions{
+		Account: r.URL.Query().Get("acc"),
+		Details: details,
+		Graph:   graph,
+	}
+
+	a, err := s.Accountz(opts)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	b, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		s.Errorf("Error marshaling response to /accountz request: %v", err)
+	}
+
+	// Handle response
+	ResponseHandler(w, r, b)
+}
+
+// Healthz represents the health of the server. The server is ready only
+// when no check failed.
+type Healthz struct {
+	Status string         `json:"status"`
+	Failed []*HealthCheck `json:"failed,omitempty"`
+}
+
+// HealthCheck is a health check that failed.
+type HealthCheck struct {
+	Check string `json:"check"`
+	Error string `json:"error"`
+}
+
+// HealthzOptions are options passed to Healthz
+type HealthzOptions struct {
+	// Routes requires a route to be connected when routes are configured.
+	Routes bool `json:"routes"`
+	// Gateways requires all configured remote gateways to be connected.
+	Gateways bool `json:"gateways"`
+	// LeafNodes requires all configured leafnode remotes to be connected.
+	LeafNodes bool `json:"leafnodes"`
+}
+
+// Health check names.
+const (
+	healthCheckShutdown  = "shutdown"
+	healthCheckLameDuck  = "lame_duck"
+	healthCheckListener  = "listener"
+	healthCheckRoutes    = "routes"
+	healthCheckGateways  = "gateways"
+	healthCheckLeafNodes = "leafnodes"
+	healthCheckResolver  = "account_resolver"
+)
+
+// Healthz returns the health of the server, listing the checks that failed.
+func (s *Server) Healthz(opts *HealthzOptions) *Healthz {
+	if opts == nil {
+		opts = &HealthzOptions{}
+	}
+	h := &Healthz{}
+	failed := func(check, format string, args ...interface{}) {
+		h.Failed = append(h.Failed, &HealthCheck{Check: check, Error: fmt.Sprintf(format, args...)})
+	}
+	sopts := s.getOpts()
+
+	s.mu.Lock()
+	shutdown, ldm, listening := s.shutdown || !s.running, s.ldm, s.listener != nil
+	numRemotes, solicitedLeafs := len(s.remotes), 0
+	for _, c := range s.leafs {
+		if c.isSolicitedLeafNode() {
+			solicitedLeafs++
+		}
+	}
+	ar := s.accResolver
+	s.mu.Unlock()
+
+	if shutdown {
+		failed(healthCheckShutdown, "server is shutting down")
+	}
+	if ldm {
+		failed(healthCheckLameDuck, "server is in lame duck mode")
+	}
+	if !listening && !shutdown && !ldm {
+		failed(healthCheckListener, "client listener is not accepting connections")
+	}
+	if opts.Routes && len(sopts.Routes) > 0 && numRemotes == 0 {
+		failed(healthCheckRoutes, "no route connected out of %d configured", len(sopts.Routes))
+	}
+	if opts.Gateways && s.gateway.enabled {
+		var missing []string
+		for _, gw := range sopts.Gateway.Gateways {
+			if gw.Name != sopts.Gateway.Name && s.getOutboundGatewayConnection(gw.Name) == nil {
+				missing = append(missing, gw.Name)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			failed(healthCheckGateways, "gateways not connected: %s", strings.Join(missing, ", "))
+		}
+	}
+	if opts.LeafNodes && solicitedLeafs < len(sopts.LeafNode.Remotes) {
+		failed(healthCheckLeafNodes, "%d of %d leafnode remotes connected", solicitedLeafs, len(sopts.LeafNode.Remotes))
+	}
+	// The URL resolver does the same basic check when created.
+	if ur, ok := ar.(*URLAccResolver); ok {
+		if err := ur.healthCheck(); err != nil {
+			failed(healthCheckResolver, "%v", err)
+		}
+	}
+
+	if len(h.Failed) > 0 {
+		h.Status = "unavailable"
+	} else {
+		h.Status = "ok"
+	}
+	return h
+}
+
+// HandleHealthz process HTTP requests for the health of the server. The
+// status is 503 when any check failed.
+func (s *Server) HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.httpReqStats[HealthzPath]++
+	s.mu.Unlock()
+
+	opts := &HealthzOptions{}
+	var err error
+	if opts.Routes, err = decodeBool(w, r, "routes"); err != nil {
+		return
+	}
+	if opts.Gateways, err = decodeBool(w, r, "gateways"); err != nil {
+		return
+	}
+	if opts.LeafNodes, err = decodeBool(w, r, "leafnodes"); err != nil {
+		return
+	}
+
+	h := s.Healthz(opts)
+	b, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		s.Errorf("Error marshaling response to /healthz request: %v", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if len(h.Failed) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(b)
+}
+
 // ResponseHandler handles responses for monitoring routes
 func ResponseHandler(w http.ResponseWriter, r *http.Request, data []byte) {
 	// Get callback from request
@@ -1763,6 +2508,14 @@ func (reason ClosedState) String() string {
 		return "Missing Account"
 	case Revocation:
 		return "Credentials Revoked"
+	case MaxConnectionsPerIPExceeded:
+		return "Maximum Connections Per Source Address Exceeded"
+	case SourceAddressNotAllowed:
+		return "Source Address Not Allowed"
+	case AuthenticationBanned:
+		return "Authentication Banned"
+	case MaxPermissionsViolationsExceeded:
+		return "Maximum Permissions Violations Exceeded"
 	}
 	return "Unknown State"
 }
@@ -0,0 +1,197 @@
+// Copyright 2019 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// monitorPaths are the endpoints served on the monitoring port.
+var monitorPaths = []string{
+	RootPath, VarzPath, ConnzPath, RoutezPath, GatewayzPath, LeafzPath, SubszPath,
+	StackszPath, BanzPath, AccountzPath, HealthzPath, MetricsPath,
+}
+
+// monitorEndpoint returns the endpoint serving the given path, the way
+// the monitoring mux routes requests, and whether the path is exactly
+// the one of an endpoint.
+func monitorEndpoint(path string) (string, bool) {
+	if path == "/subscriptionsz" {
+		return SubszPath, true
+	}
+	for _, p := range monitorPaths {
+		if path == p {
+			return p, true
+		}
+	}
+	// Anything else is handled by the root.
+	return RootPath, false
+}
+
+// validateHTTPAuth checks that the http_auth options can be enforced.
+func validateHTTPAuth(o *Options) error {
+	ha := o.HTTPAuth
+	if ha == nil {
+		return nil
+	}
+	if (ha.Verify || ha.hasCertUsers()) && o.HTTPSPort == 0 {
+		return errors.New("http_auth certificate authentication requires the https monitoring port")
+	}
+	return nil
+}
+
+// isPublic returns true if the endpoint can be accessed without
+// authentication.
+func (ha *HTTPAuthOpts) isPublic(endpoint string) bool {
+	for _, p := range ha.Public {
+		if p == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+// canAccess returns true if the user is allowed to access the endpoint.
+func (u *HTTPUser) canAccess(endpoint string) bool {
+	if len(u.Endpoints) == 0 {
+		return true
+	}
+	for _, p := range u.Endpoints {
+		if p == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCertUsers returns true if users can be identified by their client
+// certificate.
+func (ha *HTTPAuthOpts) hasCertUsers() bool {
+	for _, u := range ha.Users {
+		if u.Certificate != _EMPTY_ {
+			return true
+		}
+	}
+	return false
+}
+
+// httpUser returns the user the request is authenticated as, if any.
+func (ha *HTTPAuthOpts) httpUser(r *http.Request) *HTTPUser {
+	if username, password, ok := r.BasicAuth(); ok {
+		for _, u := range ha.Users {
+			if u.Username != _EMPTY_ && u.Username == username && comparePasswords(u.Password, password) {
+				return u
+			}
+		}
+		return nil
+	}
+	if auth := r.Header.Get("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		token := strings.TrimSpace(auth[7:])
+		for _, u := range ha.Users {
+			if u.Token != _EMPTY_ && comparePasswords(u.Token, token) {
+				return u
+			}
+		}
+		return nil
+	}
+	// Certificates are verified during the handshake.
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+	cert := r.TLS.PeerCertificates[0]
+	for _, mt := range defaultTLSMapTypes {
+		for _, id := range certIdentities(cert, mt) {
+			for _, u := range ha.Users {
+				if u.Certificate != _EMPTY_ && matchCertIdentity(u.Certificate, id) {
+					return u
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkHTTPAuth returns the status of the given request regarding
+// the http_auth options: http.StatusOK if the request is allowed.
+func (s *Server) checkHTTPAuth(r *http.Request) int {
+	ha := s.getOpts().HTTPAuth
+	if ha == nil {
+		return http.StatusOK
+	}
+	endpoint, _ := monitorEndpoint(r.URL.Path)
+	if ha.isPublic(endpoint) {
+		return http.StatusOK
+	}
+	// Without users, a verified certificate is all that is needed.
+	if len(ha.Users) == 0 && ha.Verify && r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return http.StatusOK
+	}
+	u := ha.httpUser(r)
+	if u == nil {
+		return http.StatusUnauthorized
+	}
+	if !u.canAccess(endpoint) {
+		return http.StatusForbidden
+	}
+	return http.StatusOK
+}
+
+// httpAuthHandler wraps the handler of the monitoring port to enforce
+// the http_auth options. Options are read on each request so that they
+// can be reloaded.
+func (s *Server) httpAuthHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch status := s.checkHTTPAuth(r); status {
+		case http.StatusOK:
+			next.ServeHTTP(w, r)
+		case http.StatusUnauthorized:
+			w.Header().Set("WWW-Authenticate", `Basic realm="nats-server"`)
+			http.Error(w, http.StatusText(status), status)
+		default:
+			http.Error(w, http.StatusText(status), status)
+		}
+	})
+}
+
+// monitorTLSConfig returns the TLS configuration of the HTTPS monitoring
+// port. Client certificates are requested when the http_auth options
+// make use of them. The configuration of the client listener may have
+// its own hook, for instance to serve reloaded certificates, in which
+// case its result is used.
+func (s *Server) monitorTLSConfig(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+	opts := s.getOpts()
+	config := opts.TLSConfig
+	if config.GetConfigForClient != nil {
+		cc, err := config.GetConfigForClient(hello)
+		if err != nil {
+			return nil, err
+		}
+		if cc != nil {
+			config = cc
+		}
+	}
+	config = config.Clone()
+	config.ClientAuth = tls.NoClientCert
+	if ha := opts.HTTPAuth; ha != nil {
+		if ha.Verify {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		} else if ha.hasCertUsers() {
+			config.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,225 @@
+// Copyright 2019 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHTTPAuthConfig(t *testing.T) {
+	conf := createConfFile(t, []byte(`
+		https: "127.0.0.1:-1"
+		http_auth {
+			public: ["/healthz", "/subscriptionsz"]
+			users [
+				{user: admin, password: pwd}
+				{token: abc, endpoints: "/metrics"}
+				{cert: "CN=monitor", endpoints: ["/varz", "/connz"]}
+			]
+		}
+	`))
+	defer os.Remove(conf)
+	opts, err := ProcessConfigFile(conf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ha := opts.HTTPAuth
+	if ha == nil || !reflect.DeepEqual(ha.Public, []string{HealthzPath, SubszPath}) || len(ha.Users) != 3 || ha.Verify {
+		t.Fatalf("Unexpected http_auth: %+v", ha)
+	}
+	if u := ha.Users[1]; u.Token != "abc" || !reflect.DeepEqual(u.Endpoints, []string{MetricsPath}) {
+		t.Fatalf("Unexpected user: %+v", u)
+	}
+	if u := ha.Users[2]; u.Certificate != "CN=monitor" || len(u.Endpoints) != 2 {
+		t.Fatalf("Unexpected user: %+v", u)
+	}
+
+	for _, test := range []string{
+		`http_auth { public: "/unknown" }`,
+		`http_auth { users: [{user: admin}] }`,
+		`http_auth { users: [{password: pwd}] }`,
+		`http_auth { users: [{user: admin, password: pwd, token: abc}] }`,
+		`http_auth { users: [{token: abc, endpoints: ["/varz/"]}] }`,
+		`http_auth { unknown: true }`,
+	} {
+		conf := createConfFile(t, []byte(test))
+		if _, err := ProcessConfigFile(conf); err == nil {
+			t.Fatalf("Expected error for %q", test)
+		}
+		os.Remove(conf)
+	}
+
+	// Certificates can only be checked on the https port.
+	opts = DefaultMonitorOptions()
+	opts.HTTPAuth = &HTTPAuthOpts{Verify: true}
+	if _, err := NewServer(opts); err == nil {
+		t.Fatal("Expected error for certificate authentication without https")
+	}
+}
+
+func TestHTTPAuthUsers(t *testing.T) {
+	conf := createConfFile(t, []byte(`
+		listen: "127.0.0.1:-1"
+		http: "127.0.0.1:-1"
+		http_auth {
+			public: "/healthz"
+			users [
+				{user: admin, password: pwd}
+				{user: viewer, password: pwd, endpoints: ["/varz", "/subsz"]}
+				{token: abc, endpoints: "/metrics"}
+			]
+		}
+	`))
+	defer os.Remove(conf)
+	s, _ := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	base := fmt.Sprintf("http://127.0.0.1:%d", s.MonitorAddr().Port)
+	check := func(path, user, token string, expected int) {
+		t.Helper()
+		req, _ := http.NewRequest("GET", base+path, nil)
+		if user != _EMPTY_ {
+			req.SetBasicAuth(user, "pwd")
+		}
+		if token != _EMPTY_ {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Error on request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != expected {
+			t.Fatalf("Expected status %d for %s as %q/%q, got %d", expected, path, user, token, resp.StatusCode)
+		}
+		if expected == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == _EMPTY_ {
+			t.Fatal("Expected authentication challenge")
+		}
+	}
+
+	check(HealthzPath, _EMPTY_, _EMPTY_, http.StatusOK)
+	check(ConnzPath, _EMPTY_, _EMPTY_, http.StatusUnauthorized)
+	check("/unknown", _EMPTY_, _EMPTY_, http.StatusUnauthorized)
+	check(ConnzPath, "admin", _EMPTY_, http.StatusOK)
+	check(StackszPath, "admin", _EMPTY_, http.StatusOK)
+	check(VarzPath, "viewer", _EMPTY_, http.StatusOK)
+	check("/subscriptionsz", "viewer", _EMPTY_, http.StatusOK)
+	check(ConnzPath, "viewer", _EMPTY_, http.StatusForbidden)
+	check(ConnzPath, "unknown", _EMPTY_, http.StatusUnauthorized)
+	check(MetricsPath, _EMPTY_, "abc", http.StatusOK)
+	check(VarzPath, _EMPTY_, "abc", http.StatusForbidden)
+	check(MetricsPath, _EMPTY_, "bad", http.StatusUnauthorized)
+
+	// Rules can be reloaded.
+	changeCurrentConfigContentWithNewContent(t, conf, []byte(`
+		listen: "127.0.0.1:-1"
+		http: "127.0.0.1:-1"
+		http_auth {
+			public: ["/healthz", "/connz"]
+			users [{user: viewer, password: pwd, endpoints: "/varz"}]
+		}
+	`))
+	if err := s.Reload(); err != nil {
+		t.Fatalf("Error on reload: %v", err)
+	}
+	check(ConnzPath, _EMPTY_, _EMPTY_, http.StatusOK)
+	check(StackszPath, "admin", _EMPTY_, http.StatusUnauthorized)
+	check(SubszPath, "viewer", _EMPTY_, http.StatusForbidden)
+
+	changeCurrentConfigContentWithNewContent(t, conf, []byte(`
+		listen: "127.0.0.1:-1"
+		http: "127.0.0.1:-1"
+	`))
+	if err := s.Reload(); err != nil {
+		t.Fatalf("Error on reload: %v", err)
+	}
+	check(StackszPath, _EMPTY_, _EMPTY_, http.StatusOK)
+}
+
+func TestHTTPAuthClientCert(t *testing.T) {
+	cert, err := tls.LoadX509KeyPair("../test/configs/certs/tlsauth/client.pem", "../test/configs/certs/tlsauth/client-key.pem")
+	if err != nil {
+		t.Fatalf("Error loading certificate: %v", err)
+	}
+	x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("Error parsing certificate: %v", err)
+	}
+
+	opts := DefaultOptions()
+	opts.HTTPHost = "127.0.0.1"
+	opts.HTTPPort = 0
+	opts.HTTPSPort = -1
+	opts.TLSConfig, err = GenTLSConfig(&TLSConfigOpts{
+		CertFile: "../test/configs/certs/tlsauth/server.pem",
+		KeyFile:  "../test/configs/certs/tlsauth/server-key.pem",
+		CaFile:   "../test/configs/certs/tlsauth/ca.pem",
+	})
+	if err != nil {
+		t.Fatalf("Error generating tls config: %v", err)
+	}
+	// Verify the client certificate while it is valid.
+	opts.TLSConfig.Time = func() time.Time { return x509Cert.NotBefore.Add(time.Hour) }
+	opts.HTTPAuth = &HTTPAuthOpts{
+		Public: []string{HealthzPath},
+		Users:  []*HTTPUser{{Certificate: x509Cert.Subject.String(), Endpoints: []string{VarzPath}}},
+	}
+	s := RunServer(opts)
+	defer s.Shutdown()
+
+	base := fmt.Sprintf("https://127.0.0.1:%d", s.MonitorAddr().Port)
+	check := func(path string, certs []tls.Certificate, expected int) {
+		t.Helper()
+		c := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			Certificates:       certs,
+		}}}
+		resp, err := c.Get(base + path)
+		if expected == 0 {
+			if err == nil {
+				resp.Body.Close()
+				t.Fatalf("Expected handshake failure for %s", path)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("Error on request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != expected {
+			t.Fatalf("Expected status %d for %s, got %d", expected, path, resp.StatusCode)
+		}
+	}
+	certs := []tls.Certificate{cert}
+	check(HealthzPath, nil, http.StatusOK)
+	check(VarzPath, nil, http.StatusUnauthorized)
+	check(VarzPath, certs, http.StatusOK)
+	check(ConnzPath, certs, http.StatusForbidden)
+
+	// Require a certificate from all clients.
+	newOpts := s.getOpts().Clone()
+	newOpts.HTTPAuth = &HTTPAuthOpts{Verify: true}
+	if err := s.reloadOptions(s.getOpts(), newOpts); err != nil {
+		t.Fatalf("Error on reload: %v", err)
+	}
+	check(HealthzPath, nil, 0)
+	check(ConnzPath, certs, http.StatusOK)
+}
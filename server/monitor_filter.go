@@ -0,0 +1,245 @@
+// Copyright 2019 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// connzFilter holds the parsed filters of a Connz request that need
+// to inspect the connections themselves.
+type connzFilter struct {
+	subject   string
+	name      string
+	lang      string
+	versionOp string
+	version   string
+	ipNet     *net.IPNet
+	ip        net.IP
+	reason    string
+}
+
+// newConnzFilter returns the filter for the given options, or nil if
+// none of its filters are set.
+func newConnzFilter(opts *ConnzOptions) (*connzFilter, error) {
+	if opts.FilterSubject == _EMPTY_ && opts.Name == _EMPTY_ && opts.Lang == _EMPTY_ &&
+		opts.Version == _EMPTY_ && opts.IP == _EMPTY_ && opts.Reason == _EMPTY_ {
+		return nil, nil
+	}
+	cf := &connzFilter{
+		subject: opts.FilterSubject,
+		name:    opts.Name,
+		lang:    strings.ToLower(opts.Lang),
+	}
+	if cf.subject != _EMPTY_ && !IsValidSubject(cf.subject) {
+		return nil, fmt.Errorf("invalid filter subject: %s", cf.subject)
+	}
+	if opts.Version != _EMPTY_ {
+		cf.versionOp, cf.version = parseVersionFilter(opts.Version)
+		if _, err := versionComponents(cf.version); err != nil {
+			return nil, fmt.Errorf("invalid version filter: %s", opts.Version)
+		}
+	}
+	if opts.IP != _EMPTY_ {
+		if strings.Contains(opts.IP, "/") {
+			_, ipNet, err := net.ParseCIDR(opts.IP)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ip filter: %s", opts.IP)
+			}
+			cf.ipNet = ipNet
+		} else if cf.ip = net.ParseIP(opts.IP); cf.ip == nil {
+			return nil, fmt.Errorf("invalid ip filter: %s", opts.IP)
+		}
+	}
+	if opts.Reason != _EMPTY_ {
+		if opts.State != ConnClosed {
+			return nil, fmt.Errorf("filter by reason only valid on closed connections")
+		}
+		cf.reason = normalizeReason(opts.Reason)
+	}
+	return cf, nil
+}
+
+// matchClient returns true if the open connection passes the filter.
+// Client lock should be held.
+func (cf *connzFilter) matchClient(c *client) bool {
+	if cf.reason != _EMPTY_ {
+		return false
+	}
+	if !cf.matchInfo(c.opts.Name, c.opts.Lang, c.opts.Version, c.host) {
+		return false
+	}
+	if cf.subject == _EMPTY_ {
+		return true
+	}
+	for _, sub := range c.subs {
+		if subjectsIntersect(string(sub.subject), cf.subject) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchClosed returns true if the closed connection passes the filter.
+func (cf *connzFilter) matchClosed(cc *closedClient) bool {
+	if cf.reason != _EMPTY_ && normalizeReason(cc.Reason) != cf.reason {
+		return false
+	}
+	if !cf.matchInfo(cc.Name, cc.Lang, cc.Version, cc.IP) {
+		return false
+	}
+	if cf.subject == _EMPTY_ {
+		return true
+	}
+	for _, subject := range cc.subs {
+		if subjectsIntersect(subject, cf.subject) {
+			return true
+		}
+	}
+	return false
+}
+
+func (cf *connzFilter) matchInfo(name, lang, version, host string) bool {
+	if cf.name != _EMPTY_ && name != cf.name {
+		return false
+	}
+	if cf.lang != _EMPTY_ && strings.ToLower(lang) != cf.lang {
+		return false
+	}
+	if cf.version != _EMPTY_ && !matchVersion(version, cf.versionOp, cf.version) {
+		return false
+	}
+	if cf.ip != nil || cf.ipNet != nil {
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return false
+		}
+		if cf.ipNet != nil && !cf.ipNet.Contains(ip) {
+			return false
+		}
+		if cf.ip != nil && !cf.ip.Equal(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// subjectsIntersect returns true if a message could be published on a
+// subject matching both subjects, which may contain wildcards.
+func subjectsIntersect(s1, s2 string) bool {
+	t1 := strings.Split(s1, tsep)
+	t2 := strings.Split(s2, tsep)
+	for i := 0; i < len(t1) && i < len(t2); i++ {
+		if isFwc(t1[i]) || isFwc(t2[i]) {
+			return true
+		}
+		if !isPwc(t1[i]) && !isPwc(t2[i]) && t1[i] != t2[i] {
+			return false
+		}
+	}
+	return len(t1) == len(t2)
+}
+
+func isFwc(token string) bool { return len(token) == 1 && token[0] == fwc }
+func isPwc(token string) bool { return len(token) == 1 && token[0] == pwc }
+
+// Splits a version filter, such as "<1.9" or "1.8.1", into its
+// comparison operator and version.
+func parseVersionFilter(filter string) (string, string) {
+	for _, op := range []string{"<=", ">=", "!=", "<", ">", "="} {
+		if strings.HasPrefix(filter, op) {
+			return op, strings.TrimSpace(filter[len(op):])
+		}
+	}
+	return "=", strings.TrimSpace(filter)
+}
+
+// Returns the numeric components of a version, ignoring a leading "v"
+// and any pre-release or build suffix.
+func versionComponents(version string) ([]int, error) {
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	parts := strings.Split(version, ".")
+	comps := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid version %q", version)
+		}
+		comps[i] = n
+	}
+	return comps, nil
+}
+
+// Compares two versions, missing components counting as 0.
+func compareVersions(v1, v2 []int) int {
+	for i := 0; i < len(v1) || i < len(v2); i++ {
+		var n1, n2 int
+		if i < len(v1) {
+			n1 = v1[i]
+		}
+		if i < len(v2) {
+			n2 = v2[i]
+		}
+		if n1 != n2 {
+			if n1 < n2 {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// Returns true if the version compares to the filter version as
+// requested by the operator. Versions that can not be parsed never match.
+func matchVersion(version, op, filter string) bool {
+	v1, err := versionComponents(version)
+	if err != nil {
+		return false
+	}
+	v2, _ := versionComponents(filter)
+	cmp := compareVersions(v1, v2)
+	switch op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "!=":
+		return cmp != 0
+	default:
+		return cmp == 0
+	}
+}
+
+// Normalizes a closed reason so that "Slow Consumer (Pending Bytes)"
+// can be matched as "SlowConsumerPendingBytes".
+func normalizeReason(reason string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, reason)
+}
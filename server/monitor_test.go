@@ -21,6 +21,7 @@ import (
 	"math/rand"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"reflect"
@@ -1575,6 +1576,92 @@ func TestConnzClosedConnsRace(t *testing.T) {
 	wg.Wait()
 }
 
+func TestConnzFilters(t *testing.T) {
+	s := runMonitorServer()
+	defer s.Shutdown()
+
+	connect := func(name, lang, version, subject string) net.Conn {
+		t.Helper()
+		c, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", s.Addr().(*net.TCPAddr).Port))
+		if err != nil {
+			t.Fatalf("Error on dial: %v", err)
+		}
+		c.Write([]byte(fmt.Sprintf("CONNECT {\"name\":%q,\"lang\":%q,\"version\":%q}\r\nSUB %s 1\r\nPING\r\n",
+			name, lang, version, subject)))
+		buf := make([]byte, 4096)
+		c.SetReadDeadline(time.Now().Add(2 * time.Second))
+		for !strings.Contains(string(buf), "PONG") {
+			if _, err := c.Read(buf); err != nil {
+				t.Fatalf("Error on read: %v", err)
+			}
+		}
+		return c
+	}
+	c1 := connect("payer", "go", "1.8.3", "payments.eu.*")
+	defer c1.Close()
+	c2 := connect("audit", "go", "1.9.1", ">")
+	defer c2.Close()
+	c3 := connect("orders", "java", "2.6.0", "orders.>")
+	defer c3.Close()
+	c4 := connect("payer", "go", "v1.10.0-beta", "payments.us")
+	defer c4.Close()
+
+	names := func(opts *ConnzOptions) []string {
+		t.Helper()
+		c, err := s.Connz(opts)
+		if err != nil {
+			t.Fatalf("Error on connz: %v", err)
+		}
+		var names []string
+		for _, ci := range c.Conns {
+			names = append(names, ci.Name)
+		}
+		return names
+	}
+	for _, test := range []struct {
+		opts     ConnzOptions
+		expected []string
+	}{
+		{ConnzOptions{FilterSubject: "payments.>"}, []string{"payer", "audit", "payer"}},
+		{ConnzOptions{FilterSubject: "payments.eu.1"}, []string{"payer", "audit"}},
+		{ConnzOptions{FilterSubject: "orders.*"}, []string{"audit", "orders"}},
+		{ConnzOptions{Name: "payer"}, []string{"payer", "payer"}},
+		{ConnzOptions{Lang: "Go", Version: "<1.9"}, []string{"payer"}},
+		{ConnzOptions{Lang: "go", Version: ">=1.9"}, []string{"audit", "payer"}},
+		{ConnzOptions{Version: "2.6"}, []string{"orders"}},
+		{ConnzOptions{IP: "127.0.0.0/8", Name: "orders"}, []string{"orders"}},
+		{ConnzOptions{IP: "127.0.0.1", Lang: "java"}, []string{"orders"}},
+		{ConnzOptions{IP: "10.2.0.0/16"}, nil},
+	} {
+		if got := names(&test.opts); !reflect.DeepEqual(got, test.expected) {
+			t.Fatalf("Expected %q for %+v, got %q", test.expected, test.opts, got)
+		}
+	}
+
+	// Closed connections are filtered the same way, and by reason.
+	c1.Close()
+	c3.Write([]byte("BADPROTO\r\n"))
+	checkClosedConns(t, s, 2, time.Second)
+	if got := names(&ConnzOptions{State: ConnClosed, FilterSubject: "payments.eu.>", Version: "1.8.3"}); !reflect.DeepEqual(got, []string{"payer"}) {
+		t.Fatalf("Unexpected closed connections: %q", got)
+	}
+	if got := names(&ConnzOptions{State: ConnClosed, Reason: "ProtocolViolation"}); !reflect.DeepEqual(got, []string{"orders"}) {
+		t.Fatalf("Unexpected closed connections: %q", got)
+	}
+	if got := names(&ConnzOptions{State: ConnClosed, Reason: ProtocolViolation.String(), Name: "payer"}); got != nil {
+		t.Fatalf("Unexpected closed connections: %q", got)
+	}
+
+	url := fmt.Sprintf("http://127.0.0.1:%d/connz?", s.MonitorAddr().Port)
+	c := pollConz(t, s, 0, url+"filter_subject=payments.>&lang=go&version=%3C1.10", nil)
+	if c.NumConns != 1 || c.Conns[0].Name != "audit" {
+		t.Fatalf("Unexpected connz: %+v", c)
+	}
+	for _, bad := range []string{"filter_subject=foo..bar", "version=abc", "ip=10.2.0.0/33", "ip=host", "reason=ClientClosed"} {
+		readBodyEx(t, url+bad, http.StatusBadRequest, textPlain)
+	}
+}
+
 // Make sure a bad client that is disconnected right away has proper values.
 func TestConnzClosedConnsBadClient(t *testing.T) {
 	s := runMonitorServer()
@@ -3387,3 +3474,451 @@ func TestMonitorLeafz(t *testing.T) {
 		}
 	}
 }
+
+func pollAccountz(t *testing.T, s *Server, mode int, url string, opts *AccountzOptions) *Accountz {
+	t.Helper()
+	if mode == 0 {
+		a := &Accountz{}
+		body := readBody(t, url)
+		if err := json.Unmarshal(body, a); err != nil {
+			t.Fatalf("Got an error unmarshalling the body: %v\n", err)
+		}
+		return a
+	}
+	a, err := s.Accountz(opts)
+	if err != nil {
+		t.Fatalf("Error on Accountz: %v", err)
+	}
+	return a
+}
+
+func TestMonitorAccountz(t *testing.T) {
+	conf := createConfFile(t, []byte(`
+		listen: "127.0.0.1:-1"
+		http: "127.0.0.1:-1"
+		system_account: SYS
+		accounts {
+			SYS {}
+			A {
+				users [{user: a, password: pwd}]
+				exports [
+					{service: "req.>", latency: {sampling: 50, subject: "lat"}}
+					{service: "str.>", response: stream}
+					{stream: "events.>", accounts: [B]}
+				]
+			}
+			B {
+				users [{user: b, password: pwd}]
+				imports [
+					{service: {account: A, subject: "req.foo"}, to: "foo"}
+					{stream: {account: A, subject: "events.>"}, prefix: "a"}
+				]
+			}
+		}
+	`))
+	defer os.Remove(conf)
+	s, opts := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	nc := natsConnect(t, fmt.Sprintf("nats://b:pwd@%s:%d", opts.Host, opts.Port))
+	defer nc.Close()
+	natsSubSync(t, nc, "bar")
+	natsFlush(t, nc)
+
+	url := fmt.Sprintf("http://127.0.0.1:%d%s", s.MonitorAddr().Port, AccountzPath)
+	for mode := 0; mode < 2; mode++ {
+		az := pollAccountz(t, s, mode, url, nil)
+		// A, B, the global and system accounts.
+		if az.NumAccounts != 4 || len(az.Accounts) != 4 {
+			t.Fatalf("Expected 4 accounts, got %+v", az.Accounts)
+		}
+		for _, ai := range az.Accounts {
+			if ai.Imports != nil || ai.Exports != nil {
+				t.Fatalf("Expected no details, got %+v", ai)
+			}
+			if ai.Name == "B" && (ai.NumConns != 1 || ai.NumSubs == 0) {
+				t.Fatalf("Unexpected account info: %+v", ai)
+			}
+		}
+
+		az = pollAccountz(t, s, mode, url+"?acc=A", &AccountzOptions{Account: "A"})
+		if len(az.Accounts) != 1 {
+			t.Fatalf("Expected 1 account, got %+v", az.Accounts)
+		}
+		a := az.Accounts[0]
+		if a.Name != "A" || a.Limits.MaxConns != -1 || len(a.Exports) != 3 {
+			t.Fatalf("Unexpected account info: %+v", a)
+		}
+		if e := a.Exports[0]; e.Type != "stream" || e.Subject != "events.>" || len(e.Approved) != 1 || e.Approved[0] != "B" {
+			t.Fatalf("Unexpected stream export: %+v", e)
+		}
+		if e := a.Exports[1]; e.Type != "service" || e.RespType != Singleton.String() || e.Latency == nil || e.Latency.Sampling != 50 || e.Latency.Subject != "lat" {
+			t.Fatalf("Unexpected service export: %+v", e)
+		}
+		if e := a.Exports[2]; e.Type != "service" || e.RespType != Stream.String() || e.Latency != nil {
+			t.Fatalf("Unexpected service export: %+v", e)
+		}
+
+		az = pollAccountz(t, s, mode, url+"?acc=B", &AccountzOptions{Account: "B"})
+		b := az.Accounts[0]
+		if len(b.Imports) != 2 {
+			t.Fatalf("Expected 2 imports, got %+v", b.Imports)
+		}
+		if i := b.Imports[0]; i.Type != "stream" || i.Account != "A" || i.Prefix != "a" {
+			t.Fatalf("Unexpected stream import: %+v", i)
+		}
+		if i := b.Imports[1]; i.Type != "service" || i.Subject != "foo" || i.To != "req.foo" || i.RespType != Singleton.String() || !i.Tracking {
+			t.Fatalf("Unexpected service import: %+v", i)
+		}
+	}
+
+	if _, err := s.Accountz(&AccountzOptions{Account: "C"}); err == nil {
+		t.Fatal("Expected error for unknown account")
+	}
+	resp, err := http.Get(url + "?acc=C")
+	if err != nil {
+		t.Fatalf("Error on request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestMonitorAccountzGraph(t *testing.T) {
+	conf := createConfFile(t, []byte(`
+		listen: "127.0.0.1:-1"
+		http: "127.0.0.1:-1"
+		accounts {
+			A {
+				exports [
+					{service: "req.>"}
+					{stream: "events.>"}
+				]
+			}
+			B {
+				exports [{service: "b.req"}]
+				imports [
+					{service: {account: A, subject: "req.foo"}, to: "foo"}
+					{stream: {account: A, subject: "events.*.created"}, to: "a.$1"}
+				]
+			}
+			C {}
+		}
+	`))
+	defer os.Remove(conf)
+	s, _ := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	url := fmt.Sprintf("http://127.0.0.1:%d%s", s.MonitorAddr().Port, AccountzPath)
+	for mode := 0; mode < 2; mode++ {
+		az := pollAccountz(t, s, mode, url, nil)
+		if az.Graph != nil {
+			t.Fatalf("Expected no graph, got %+v", az.Graph)
+		}
+		az = pollAccountz(t, s, mode, url+"?graph=1", &AccountzOptions{Graph: true})
+		g := az.Graph
+		if g == nil || len(g.Edges) != 2 || g.Cycle != "" {
+			t.Fatalf("Unexpected graph: %+v", g)
+		}
+		if e := g.Edges[0]; e.Type != "stream" || e.Account != "B" || e.Subject != "a.$1" || e.Exporter != "A" || e.To != "events.*.created" {
+			t.Fatalf("Unexpected stream edge: %+v", e)
+		}
+		if e := g.Edges[1]; e.Type != "service" || e.Account != "B" || e.Subject != "foo" || e.Exporter != "A" || e.To != "req.foo" {
+			t.Fatalf("Unexpected service edge: %+v", e)
+		}
+		az = pollAccountz(t, s, mode, url+"?graph=1&acc=C", &AccountzOptions{Account: "C", Graph: true})
+		if g := az.Graph; len(g.Nodes) != 1 || g.Nodes[0] != "C" || len(g.Edges) != 0 {
+			t.Fatalf("Unexpected graph: %+v", g)
+		}
+	}
+
+	// Accounts added at runtime are not validated, the cycle is reported.
+	a, _ := s.LookupAccount("A")
+	b, _ := s.LookupAccount("B")
+	if err := a.AddServiceImport(b, "req.bar", "b.req"); err != nil {
+		t.Fatalf("Error adding service import: %v", err)
+	}
+	if err := b.AddServiceImport(a, "b.req", "req.bar"); err != nil {
+		t.Fatalf("Error adding service import: %v", err)
+	}
+	az, err := s.Accountz(&AccountzOptions{Graph: true})
+	if err != nil {
+		t.Fatalf("Error on Accountz: %v", err)
+	}
+	if expected := `A "req.bar" -> B "b.req" -> A "req.bar"`; az.Graph.Cycle != expected {
+		t.Fatalf("Expected cycle %q, got %q", expected, az.Graph.Cycle)
+	}
+}
+
+func TestMonitorAccountzJWT(t *testing.T) {
+	nac := newJWTTestAccountClaims()
+	nac.Name = "acme"
+	nac.Expires = time.Now().Add(time.Hour).Unix()
+	nac.Limits.Conn = 10
+	nac.Revoke("UAXXX")
+	s, akp, _, _ := setupJWTTestWitAccountClaims(t, nac, "+OK")
+	defer s.Shutdown()
+	apub, _ := akp.PublicKey()
+
+	az, err := s.Accountz(&AccountzOptions{Account: "acme"})
+	if err != nil {
+		t.Fatalf("Error on Accountz: %v", err)
+	}
+	a := az.Accounts[0]
+	if a.Name != apub || a.JWTName != "acme" || a.Limits.MaxConns != 10 || a.NumConns != 1 {
+		t.Fatalf("Unexpected account info: %+v", a)
+	}
+	if a.Expires == nil || a.Expires.Unix() != nac.Expires || a.Expired {
+		t.Fatalf("Unexpected expiration: %v", a.Expires)
+	}
+	if _, ok := a.RevokedUsers["UAXXX"]; !ok || len(a.RevokedUsers) != 1 {
+		t.Fatalf("Unexpected revoked users: %+v", a.RevokedUsers)
+	}
+	if a.Claims == nil || a.Claims.Subject != apub {
+		t.Fatalf("Unexpected claims: %+v", a.Claims)
+	}
+}
+
+func TestMonitorHealthz(t *testing.T) {
+	// A port nothing listens on.
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	deadPort := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	opts := DefaultMonitorOptions()
+	opts.Cluster.Host = "127.0.0.1"
+	opts.Cluster.Port = -1
+	opts.Routes = RoutesFromStr(fmt.Sprintf("nats://127.0.0.1:%d", deadPort))
+	u, _ := url.Parse(fmt.Sprintf("nats-leaf://127.0.0.1:%d", deadPort))
+	opts.LeafNode.Remotes = []*RemoteLeafOpts{{URLs: []*url.URL{u}}}
+	s := RunServer(opts)
+	defer s.Shutdown()
+
+	healthz := func(query string, expectedStatus int, expectedChecks ...string) {
+		t.Helper()
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d%s%s", s.MonitorAddr().Port, HealthzPath, query))
+		if err != nil {
+			t.Fatalf("Error on request: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != expectedStatus {
+			t.Fatalf("Expected status %d, got %d", expectedStatus, resp.StatusCode)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		h := &Healthz{}
+		if err := json.Unmarshal(body, h); err != nil {
+			t.Fatalf("Error unmarshalling healthz: %v", err)
+		}
+		var checks []string
+		for _, f := range h.Failed {
+			checks = append(checks, f.Check)
+		}
+		if !reflect.DeepEqual(checks, expectedChecks) {
+			t.Fatalf("Expected failed checks %q, got %+v", expectedChecks, h)
+		}
+		if (h.Status == "ok") != (len(expectedChecks) == 0) {
+			t.Fatalf("Unexpected status %q", h.Status)
+		}
+	}
+
+	// Connections are only required when asked for.
+	healthz("", http.StatusOK)
+	healthz("?routes=true&leafnodes=true", http.StatusServiceUnavailable, healthCheckRoutes, healthCheckLeafNodes)
+	healthz("?routes=1", http.StatusServiceUnavailable, healthCheckRoutes)
+
+	// Account resolver must be reachable.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ur, err := NewURLAccResolver(ts.URL)
+	if err != nil {
+		t.Fatalf("Error creating resolver: %v", err)
+	}
+	s.SetAccountResolver(ur)
+	healthz("", http.StatusOK)
+	ts.Close()
+	// The result of the last check is reused for some time.
+	healthz("", http.StatusOK)
+	ur.hmu.Lock()
+	ur.hchecked = time.Time{}
+	ur.hmu.Unlock()
+	healthz("", http.StatusServiceUnavailable, healthCheckResolver)
+	s.SetAccountResolver(nil)
+
+	// Simulate lame duck mode.
+	s.mu.Lock()
+	s.ldm = true
+	s.mu.Unlock()
+	healthz("", http.StatusServiceUnavailable, healthCheckLameDuck)
+	s.mu.Lock()
+	s.ldm = false
+	s.mu.Unlock()
+
+	if h := s.Healthz(nil); h.Status != "ok" {
+		t.Fatalf("Expected server to be healthy, got %+v", h)
+	}
+}
+
+func TestMonitorMetrics(t *testing.T) {
+	conf := createConfFile(t, []byte(`
+		listen: "127.0.0.1:-1"
+		http: "127.0.0.1:-1"
+		system_account: SYS
+		accounts {
+			SYS {}
+			A {
+				users [{user: a, password: pwd}]
+				exports [{service: "req", latency: {sampling: 100, subject: "lat"}}]
+			}
+			B {
+				users [{user: b, password: pwd}]
+				imports [{service: {account: A, subject: "req"}}]
+			}
+		}
+	`))
+	defer os.Remove(conf)
+	s, opts := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	nca := natsConnect(t, fmt.Sprintf("nats://a:pwd@%s:%d", opts.Host, opts.Port))
+	defer nca.Close()
+	lat := natsSubSync(t, nca, "lat")
+	nca.Subscribe("req", func(m *nats.Msg) { m.Respond([]byte("ok")) })
+	natsFlush(t, nca)
+
+	ncb := natsConnect(t, fmt.Sprintf("nats://b:pwd@%s:%d", opts.Host, opts.Port))
+	defer ncb.Close()
+	if _, err := ncb.Request("req", []byte("help"), time.Second); err != nil {
+		t.Fatalf("Error on request: %v", err)
+	}
+	natsNexMsg(t, lat, time.Second)
+
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d%s", s.MonitorAddr().Port, MetricsPath))
+	if err != nil {
+		t.Fatalf("Error on request: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("Unexpected content type %q", ct)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	metrics := string(body)
+	for _, expected := range []string{
+		"# TYPE nats_server_in_msgs_total counter\n",
+		"# TYPE nats_server_connections gauge\n",
+		"nats_server_connections{server_id=\"" + s.ID() + "\"} 2\n",
+		"nats_server_http_requests_total{server_id=\"" + s.ID() + "\",path=\"/metrics\"} 1\n",
+		"nats_account_connections{account=\"A\"} 1\n",
+		"nats_account_connections{account=\"B\"} 1\n",
+		"nats_account_out_msgs_total{account=\"B\",source=\"clients\"} 1\n",
+		"nats_account_in_msgs_total{account=\"A\",source=\"clients\"} 1\n",
+		"nats_account_sublist_cache_hit_ratio{account=\"A\"} ",
+		"# TYPE nats_service_latency_seconds histogram\n",
+		"nats_service_latency_seconds_bucket{account=\"A\",service=\"req\",le=\"+Inf\"} 1\n",
+		"nats_service_latency_seconds_count{account=\"A\",service=\"req\"} 1\n",
+		"nats_service_processing_seconds_count{account=\"A\",service=\"req\"} 1\n",
+	} {
+		if !strings.Contains(metrics, expected) {
+			t.Fatalf("Expected metrics to contain %q, got:\n%s", expected, metrics)
+		}
+	}
+	// Each family is described once.
+	if n := strings.Count(metrics, "# TYPE nats_account_connections "); n != 1 {
+		t.Fatalf("Expected family to be described once, got %d", n)
+	}
+}
+
+func TestServiceLatencyStats(t *testing.T) {
+	h := &latencyHistogram{}
+	for i := 1; i <= 100; i++ {
+		h.observe(time.Duration(i) * time.Millisecond)
+	}
+	if p := h.percentiles(); p.Count != 100 || p.Max != 100*time.Millisecond ||
+		p.P50 <= 25*time.Millisecond || p.P50 > 50*time.Millisecond ||
+		p.P90 <= 50*time.Millisecond || p.P99 > p.Max || p.P99 < p.P90 {
+		t.Fatalf("Unexpected percentiles: %+v", p)
+	}
+
+	conf := createConfFile(t, []byte(`
+		listen: "127.0.0.1:-1"
+		http: "127.0.0.1:-1"
+		system_account: SYS
+		service_latency {
+			interval: "100ms"
+			samples: false
+		}
+		accounts {
+			SYS {}
+			A {
+				users [{user: a, password: pwd}]
+				exports [{service: "req", latency: {sampling: 100, subject: "lat"}}]
+			}
+			B {
+				users [{user: b, password: pwd}]
+				imports [{service: {account: A, subject: "req"}}]
+			}
+		}
+	`))
+	defer os.Remove(conf)
+	s, opts := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	nca := natsConnect(t, fmt.Sprintf("nats://a:pwd@%s:%d", opts.Host, opts.Port))
+	defer nca.Close()
+	lat := natsSubSync(t, nca, "lat")
+	stats := natsSubSync(t, nca, "lat.STATS")
+	nca.Subscribe("req", func(m *nats.Msg) { m.Respond([]byte("ok")) })
+	natsFlush(t, nca)
+
+	ncb := natsConnect(t, fmt.Sprintf("nats://b:pwd@%s:%d", opts.Host, opts.Port))
+	defer ncb.Close()
+	for i := 0; i < 3; i++ {
+		if _, err := ncb.Request("req", []byte("help"), time.Second); err != nil {
+			t.Fatalf("Error on request: %v", err)
+		}
+	}
+
+	// Only the aggregated latencies are published.
+	st := &ServiceLatencyStats{}
+	if err := json.Unmarshal(natsNexMsg(t, stats, time.Second).Data, st); err != nil {
+		t.Fatalf("Error unmarshalling: %v", err)
+	}
+	if st.Server == nil || st.Server.ID != s.ID() || st.Service != "req" || st.TotalLatency.Count != 3 ||
+		st.TotalLatency.Max <= 0 || st.TotalLatency.P50 > st.TotalLatency.Max || !st.End.After(st.Start) {
+		t.Fatalf("Unexpected stats: %+v", st)
+	}
+	if msg, err := lat.NextMsg(50 * time.Millisecond); err == nil {
+		t.Fatalf("Unexpected latency sample: %q", msg.Data)
+	}
+	// Intervals without requests are not published.
+	if msg, err := stats.NextMsg(250 * time.Millisecond); err == nil {
+		t.Fatalf("Unexpected stats: %q", msg.Data)
+	}
+
+	// Accountz reports the latencies since the service is tracked.
+	az, err := s.Accountz(&AccountzOptions{Account: "A"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	exports := az.Accounts[0].Exports
+	if len(exports) != 1 || exports[0].Latency == nil || exports[0].Latency.Stats == nil ||
+		exports[0].Latency.Stats.TotalLatency.Count != 3 || exports[0].Latency.Stats.Server != nil {
+		t.Fatalf("Unexpected exports: %+v", exports)
+	}
+
+	metrics := string(s.Metrics())
+	for _, expected := range []string{
+		"# TYPE nats_service_latency_percentile_seconds gauge\n",
+		"nats_service_latency_percentile_seconds{account=\"A\",service=\"req\",latency=\"total\",quantile=\"0.99\"} ",
+		"nats_service_latency_max_seconds{account=\"A\",service=\"req\",latency=\"requestor\"} ",
+	} {
+		if !strings.Contains(metrics, expected) {
+			t.Fatalf("Expected metrics to contain %q, got:\n%s", expected, metrics)
+		}
+	}
+
+	conf2 := createConfFile(t, []byte(`service_latency { interval: "x" }`))
+	defer os.Remove(conf2)
+	if _, err := ProcessConfigFile(conf2); err == nil {
+		t.Fatal("Expected error parsing interval")
+	}
+}
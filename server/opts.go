@@ -64,10 +64,14 @@ type ClusterOpts struct {
 	TLSTimeout     float64           `json:"-"`
 	TLSConfig      *tls.Config       `json:"-"`
 	TLSMap         bool              `json:"-"`
+	TLSMapTypes    []string          `json:"-"`
 	ListenStr      string            `json:"-"`
 	Advertise      string            `json:"-"`
 	NoAdvertise    bool              `json:"-"`
 	ConnectRetries int               `json:"-"`
+
+	// Not exported, used to watch the TLS certificate files.
+	tlsConfigOpts *TLSConfigOpts
 }
 
 // GatewayOpts are options for gateways.
@@ -83,11 +87,15 @@ type GatewayOpts struct {
 	TLSConfig      *tls.Config          `json:"-"`
 	TLSTimeout     float64              `json:"tls_timeout,omitempty"`
 	TLSMap         bool                 `json:"-"`
+	TLSMapTypes    []string             `json:"-"`
 	Advertise      string               `json:"advertise,omitempty"`
 	ConnectRetries int                  `json:"connect_retries,omitempty"`
 	Gateways       []*RemoteGatewayOpts `json:"gateways,omitempty"`
 	RejectUnknown  bool                 `json:"reject_unknown,omitempty"`
 
+	// Not exported, used to watch the TLS certificate files.
+	tlsConfigOpts *TLSConfigOpts
+
 	// Not exported, for tests.
 	resolver         netResolver
 	sendQSubsBufSize int
@@ -115,6 +123,7 @@ type LeafNodeOpts struct {
 	TLSConfig         *tls.Config   `json:"-"`
 	TLSTimeout        float64       `json:"tls_timeout,omitempty"`
 	TLSMap            bool          `json:"-"`
+	TLSMapTypes       []string      `json:"-"`
 	Advertise         string        `json:"-"`
 	NoAdvertise       bool          `json:"-"`
 	ReconnectInterval time.Duration `json:"-"`
@@ -122,6 +131,9 @@ type LeafNodeOpts struct {
 	// For solicited connections to other clusters/superclusters.
 	Remotes []*RemoteLeafOpts `json:"remotes,omitempty"`
 
+	// Not exported, used to watch the TLS certificate files.
+	tlsConfigOpts *TLSConfigOpts
+
 	// Not exported, for tests.
 	resolver    netResolver
 	dialTimeout time.Duration
@@ -137,6 +149,82 @@ type RemoteLeafOpts struct {
 	TLSTimeout   float64     `json:"tls_timeout,omitempty"`
 }
 
+// AuthFailureOpts are options to protect the server against repeated
+// authentication failures. Failures are tracked per source address and
+// per user over a sliding window. Each failure delays the error returned
+// to the client, and reaching the maximum number of failures bans the
+// source address or user for a time that doubles on each new ban.
+type AuthFailureOpts struct {
+	MaxFailures int           `json:"max_failures,omitempty"`
+	Window      time.Duration `json:"window,omitempty"`
+	Delay       time.Duration `json:"delay,omitempty"`
+	MaxDelay    time.Duration `json:"max_delay,omitempty"`
+	BanTime     time.Duration `json:"ban_time,omitempty"`
+	MaxBanTime  time.Duration `json:"max_ban_time,omitempty"`
+}
+
+// PermViolationOpts is the policy used to close connections that
+// repeatedly violate their permissions. A connection is closed once it
+// reaches Max violations within Window, or within its lifetime if
+// Window is not set.
+type PermViolationOpts struct {
+	Max    int           `json:"max,omitempty"`
+	Window time.Duration `json:"window,omitempty"`
+}
+
+// HotSubjectsOpts configures the tracking of the subjects with the most
+// messages. Tracking is disabled unless Size is set. One message out of
+// Sampling is recorded, under its subject or, if Depth is set, under the
+// prefix made of its first Depth tokens.
+type HotSubjectsOpts struct {
+	Size     int           `json:"size,omitempty"`
+	Sampling int           `json:"sampling,omitempty"`
+	Depth    int           `json:"depth,omitempty"`
+	Window   time.Duration `json:"window,omitempty"`
+}
+
+// ClosedConnsLogOpts configures the on-disk log of closed connections.
+// The log is rotated once File reaches MaxSize bytes, keeping at most
+// MaxFiles files.
+type ClosedConnsLogOpts struct {
+	File     string `json:"file,omitempty"`
+	MaxSize  int64  `json:"max_size,omitempty"`
+	MaxFiles int    `json:"max_files,omitempty"`
+}
+
+// LatencyStatsOpts configures the aggregation of the latencies of the
+// tracked service exports. If Interval is set, their percentiles are
+// published every Interval. NoSamples stops the publishing of the
+// individual measurements of the sampled requests.
+type LatencyStatsOpts struct {
+	Interval  time.Duration `json:"interval,omitempty"`
+	NoSamples bool          `json:"no_samples,omitempty"`
+}
+
+// HTTPAuthOpts restricts access to the monitoring endpoints. Endpoints
+// listed in Public are open to anyone, the others require one of the
+// users. Users are identified by basic auth, bearer token or, on the
+// HTTPS port, by their client certificate.
+type HTTPAuthOpts struct {
+	Public []string    `json:"public,omitempty"`
+	Users  []*HTTPUser `json:"-"`
+	// Verify requires clients of the HTTPS port to present a valid
+	// certificate, even for public endpoints.
+	Verify bool `json:"verify,omitempty"`
+}
+
+// HTTPUser is a user of the monitoring endpoints, identified by either
+// a username and password, a token or an identity of its client
+// certificate (email, DNS name, URI, IP address or subject). Endpoints
+// lists the endpoints the user can access, all of them if empty.
+type HTTPUser struct {
+	Username    string   `json:"user,omitempty"`
+	Password    string   `json:"-"`
+	Token       string   `json:"-"`
+	Certificate string   `json:"cert,omitempty"`
+	Endpoints   []string `json:"endpoints,omitempty"`
+}
+
 // Options block for nats-server.
 // NOTE: This structure is no longer used for monitoring endpoints
 // and json tags are deprecated and may be removed in the future.
@@ -152,6 +240,7 @@ type Options struct {
 	NoSublistCache   bool          `json:"-"`
 	Logtime          bool          `json:"-"`
 	MaxConn          int           `json:"max_connections"`
+	MaxConnPerIP     int           `json:"max_connections_per_ip,omitempty"`
 	MaxSubs          int           `json:"max_subscriptions,omitempty"`
 	Nkeys            []*NkeyUser   `json:"-"`
 	Users            []*User       `json:"-"`
@@ -166,6 +255,7 @@ type Options struct {
 	HTTPHost         string        `json:"http_host"`
 	HTTPPort         int           `json:"http_port"`
 	HTTPSPort        int           `json:"https_port"`
+	HTTPAuth         *HTTPAuthOpts `json:"-"`
 	AuthTimeout      float64       `json:"auth_timeout"`
 	MaxControlLine   int32         `json:"max_control_line"`
 	MaxPayload       int32         `json:"max_payload"`
@@ -185,10 +275,12 @@ type Options struct {
 	TLS              bool          `json:"-"`
 	TLSVerify        bool          `json:"-"`
 	TLSMap           bool          `json:"-"`
+	TLSMapTypes      []string      `json:"-"`
 	TLSCert          string        `json:"-"`
 	TLSKey           string        `json:"-"`
 	TLSCaCert        string        `json:"-"`
 	TLSConfig        *tls.Config   `json:"-"`
+	TLSWatchInterval time.Duration `json:"-"`
 	WriteDeadline    time.Duration `json:"-"`
 	MaxClosedClients int           `json:"-"`
 	LameDuckDuration time.Duration `json:"-"`
@@ -201,6 +293,9 @@ type Options struct {
 	AccountResolver  AccountResolver       `json:"-"`
 	resolverPreloads map[string]string
 
+	// Not exported, used to watch the TLS certificate files.
+	tlsConfigOpts *TLSConfigOpts
+
 	CustomClientAuthentication Authentication `json:"-"`
 	CustomRouterAuthentication Authentication `json:"-"`
 
@@ -217,6 +312,27 @@ type Options struct {
 	// that this applies to reconnect events.
 	ReconnectErrorReports int
 
+	// AuthFailures configures the protection against repeated
+	// authentication failures.
+	AuthFailures AuthFailureOpts `json:"-"`
+
+	// PermViolations is the policy used to close connections that
+	// repeatedly violate their permissions. It can be overridden
+	// per account.
+	PermViolations PermViolationOpts `json:"-"`
+
+	// HotSubjects configures the tracking of the subjects with the
+	// most messages, reported by the subsz endpoint.
+	HotSubjects HotSubjectsOpts `json:"-"`
+
+	// ClosedConnsLog configures the persistence of closed connections,
+	// reloaded on startup and searchable through the connz endpoint.
+	ClosedConnsLog ClosedConnsLogOpts `json:"-"`
+
+	// LatencyStats configures the aggregation of the latencies of
+	// the tracked service exports.
+	LatencyStats LatencyStatsOpts `json:"-"`
+
 	// private fields, used to know if bool options are explicitly
 	// defined in config and/or command line params.
 	inConfig  map[string]bool
@@ -309,6 +425,7 @@ type TLSConfigOpts struct {
 	Verify           bool
 	Insecure         bool
 	Map              bool
+	MapTypes         []string
 	Timeout          float64
 	Ciphers          []uint16
 	CurvePreferences []tls.CurveID
@@ -325,6 +442,7 @@ e.g.
         ca_file:        "./certs/ca.pem"
         verify:         true
         verify_and_map: true
+        map_types:      ["email", "dns", "uri", "ip", "subject"]
 
         cipher_suites: [
             "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
@@ -341,6 +459,7 @@ Available cipher suites include:
 `
 
 // ProcessConfigFile processes a configuration file.
+// Files with a .json, .yaml or .yml extension are read as JSON or YAML.
 // FIXME(dlc): A bit hacky
 func ProcessConfigFile(configFile string) (*Options, error) {
 	opts := &Options{}
@@ -362,6 +481,7 @@ type token interface {
 	IsUsedVariable() bool
 	SourceFile() string
 	Position() int
+	IsSecret() bool
 }
 
 // unwrapValue can be used to get the token and value from an item
@@ -541,6 +661,42 @@ func (o *Options) ProcessConfigFile(configFile string) error {
 				errors = append(errors, err)
 				continue
 			}
+		case "http_auth":
+			if err := parseHTTPAuth(tk, o, &errors, &warnings); err != nil {
+				errors = append(errors, err)
+				continue
+			}
+		case "auth_failures":
+			if err := parseAuthFailures(tk, o, &errors, &warnings); err != nil {
+				errors = append(errors, err)
+				continue
+			}
+		case "permission_violations":
+			pv, err := parsePermViolations(tk, &errors)
+			if err != nil {
+				errors = append(errors, err)
+				continue
+			}
+			o.PermViolations = *pv
+		case "hot_subjects":
+			hs, err := parseHotSubjects(tk, &errors)
+			if err != nil {
+				errors = append(errors, err)
+				continue
+			}
+			o.HotSubjects = *hs
+		case "closed_connections_log":
+			if err := parseClosedConnsLog(tk, o, &errors); err != nil {
+				errors = append(errors, err)
+				continue
+			}
+		case "service_latency":
+			ls, err := parseLatencyStats(tk, &errors)
+			if err != nil {
+				errors = append(errors, err)
+				continue
+			}
+			o.LatencyStats = *ls
 		case "logfile", "log_file":
 			o.LogFile = v.(string)
 		case "syslog":
@@ -572,6 +728,8 @@ func (o *Options) ProcessConfigFile(configFile string) error {
 			o.MaxPending = v.(int64)
 		case "max_connections", "max_conn":
 			o.MaxConn = int(v.(int64))
+		case "max_connections_per_ip", "max_conn_per_ip":
+			o.MaxConnPerIP = int(v.(int64))
 		case "max_traced_msg_len":
 			o.MaxTracedMsgLen = int(v.(int64))
 		case "max_subscriptions", "max_subs":
@@ -593,6 +751,16 @@ func (o *Options) ProcessConfigFile(configFile string) error {
 			}
 			o.TLSTimeout = tc.Timeout
 			o.TLSMap = tc.Map
+			o.TLSMapTypes = tc.MapTypes
+			o.tlsConfigOpts = tc
+		case "tls_watch_interval":
+			dur, err := time.ParseDuration(v.(string))
+			if err != nil {
+				err := &configErr{tk, fmt.Sprintf("error parsing tls_watch_interval: %v", err)}
+				errors = append(errors, err)
+				continue
+			}
+			o.TLSWatchInterval = dur
 		case "write_deadline":
 			wd, ok := v.(string)
 			if ok {
@@ -878,6 +1046,8 @@ func parseCluster(v interface{}, opts *Options, errors *[]error, warnings *[]err
 			opts.Cluster.TLSConfig = config
 			opts.Cluster.TLSTimeout = tlsopts.Timeout
 			opts.Cluster.TLSMap = tlsopts.Map
+			opts.Cluster.TLSMapTypes = tlsopts.MapTypes
+			opts.Cluster.tlsConfigOpts = tlsopts
 		case "cluster_advertise", "advertise":
 			opts.Cluster.Advertise = mv.(string)
 		case "no_advertise":
@@ -990,6 +1160,8 @@ func parseGateway(v interface{}, o *Options, errors *[]error, warnings *[]error)
 			o.Gateway.TLSConfig = config
 			o.Gateway.TLSTimeout = tlsopts.Timeout
 			o.Gateway.TLSMap = tlsopts.Map
+			o.Gateway.TLSMapTypes = tlsopts.MapTypes
+			o.Gateway.tlsConfigOpts = tlsopts
 		case "advertise":
 			o.Gateway.Advertise = mv.(string)
 		case "connect_retries":
@@ -1018,6 +1190,411 @@ func parseGateway(v interface{}, o *Options, errors *[]error, warnings *[]error)
 	return nil
 }
 
+// parseAuthFailures will parse the auth_failures block.
+func parseAuthFailures(v interface{}, opts *Options, errors *[]error, warnings *[]error) error {
+	tk, v := unwrapValue(v)
+	cm, ok := v.(map[string]interface{})
+	if !ok {
+		return &configErr{tk, fmt.Sprintf("Expected auth_failures to be a map, got %T", v)}
+	}
+	af := &opts.AuthFailures
+	for mk, mv := range cm {
+		tk, mv = unwrapValue(mv)
+		var dur *time.Duration
+		switch strings.ToLower(mk) {
+		case "max_failures", "max":
+			n, ok := mv.(int64)
+			if !ok || n < 0 {
+				*errors = append(*errors, &configErr{tk, fmt.Sprintf("Expected max_failures to be a positive number, got %v", mv)})
+				continue
+			}
+			af.MaxFailures = int(n)
+		case "window":
+			dur = &af.Window
+		case "delay":
+			dur = &af.Delay
+		case "max_delay":
+			dur = &af.MaxDelay
+		case "ban_time", "ban":
+			dur = &af.BanTime
+		case "max_ban_time", "max_ban":
+			dur = &af.MaxBanTime
+		default:
+			if !tk.IsUsedVariable() {
+				err := &unknownConfigFieldErr{
+					field: mk,
+					configErr: configErr{
+						token: tk,
+					},
+				}
+				*errors = append(*errors, err)
+			}
+			continue
+		}
+		if dur != nil {
+			ds, ok := mv.(string)
+			if !ok {
+				*errors = append(*errors, &configErr{tk, fmt.Sprintf("Expected %s to be a duration, got %v", mk, mv)})
+				continue
+			}
+			d, err := time.ParseDuration(ds)
+			if err != nil || d < 0 {
+				*errors = append(*errors, &configErr{tk, fmt.Sprintf("error parsing %s: %q", mk, ds)})
+				continue
+			}
+			*dur = d
+		}
+	}
+	return nil
+}
+
+// parseHTTPAuth will parse the http_auth block.
+func parseHTTPAuth(v interface{}, opts *Options, errors *[]error, warnings *[]error) error {
+	tk, v := unwrapValue(v)
+	cm, ok := v.(map[string]interface{})
+	if !ok {
+		return &configErr{tk, fmt.Sprintf("Expected http_auth to be a map, got %T", v)}
+	}
+	ha := &HTTPAuthOpts{}
+	for mk, mv := range cm {
+		tk, mv = unwrapValue(mv)
+		switch strings.ToLower(mk) {
+		case "public":
+			endpoints, err := parseHTTPEndpoints(tk)
+			if err != nil {
+				*errors = append(*errors, err)
+				continue
+			}
+			ha.Public = endpoints
+		case "verify":
+			ha.Verify = mv.(bool)
+		case "users":
+			users, err := parseHTTPUsers(tk, errors)
+			if err != nil {
+				*errors = append(*errors, err)
+				continue
+			}
+			ha.Users = users
+		default:
+			if !tk.IsUsedVariable() {
+				err := &unknownConfigFieldErr{
+					field: mk,
+					configErr: configErr{
+						token: tk,
+					},
+				}
+				*errors = append(*errors, err)
+			}
+		}
+	}
+	opts.HTTPAuth = ha
+	return nil
+}
+
+// parseHTTPUsers will parse the users of the http_auth block.
+func parseHTTPUsers(v interface{}, errors *[]error) ([]*HTTPUser, error) {
+	tk, v := unwrapValue(v)
+	uv, ok := v.([]interface{})
+	if !ok {
+		return nil, &configErr{tk, fmt.Sprintf("Expected users field to be an array, got %v", v)}
+	}
+	var users []*HTTPUser
+	for _, u := range uv {
+		tk, u = unwrapValue(u)
+		um, ok := u.(map[string]interface{})
+		if !ok {
+			*errors = append(*errors, &configErr{tk, fmt.Sprintf("Expected user entry to be a map/struct, got %v", u)})
+			continue
+		}
+		user := &HTTPUser{}
+		for k, v := range um {
+			tk, v := unwrapValue(v)
+			switch strings.ToLower(k) {
+			case "user", "username":
+				user.Username = v.(string)
+			case "pass", "password":
+				user.Password = v.(string)
+			case "token":
+				user.Token = v.(string)
+			case "cert", "certificate":
+				user.Certificate = v.(string)
+			case "endpoints":
+				endpoints, err := parseHTTPEndpoints(tk)
+				if err != nil {
+					*errors = append(*errors, err)
+					continue
+				}
+				user.Endpoints = endpoints
+			default:
+				if !tk.IsUsedVariable() {
+					err := &unknownConfigFieldErr{
+						field: k,
+						configErr: configErr{
+							token: tk,
+						},
+					}
+					*errors = append(*errors, err)
+				}
+			}
+		}
+		// A user is identified by exactly one of user, token or cert.
+		n := 0
+		for _, id := range []string{user.Username, user.Token, user.Certificate} {
+			if id != _EMPTY_ {
+				n++
+			}
+		}
+		switch {
+		case n != 1:
+			*errors = append(*errors, &configErr{tk, "HTTP user requires one of user, token or cert"})
+			continue
+		case user.Username != _EMPTY_ && user.Password == _EMPTY_:
+			*errors = append(*errors, &configErr{tk, fmt.Sprintf("HTTP user %q requires a password", user.Username)})
+			continue
+		case user.Username == _EMPTY_ && user.Password != _EMPTY_:
+			*errors = append(*errors, &configErr{tk, "HTTP user password requires a user"})
+			continue
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// parseHTTPEndpoints will parse an endpoint, or array of endpoints,
+// of the monitoring port.
+func parseHTTPEndpoints(v interface{}) ([]string, error) {
+	tk, v := unwrapValue(v)
+	var endpoints []string
+	switch vv := v.(type) {
+	case string:
+		endpoints = append(endpoints, vv)
+	case []interface{}:
+		for _, i := range vv {
+			tk, i := unwrapValue(i)
+			endpoint, ok := i.(string)
+			if !ok {
+				return nil, &configErr{tk, "Endpoint in array cannot be cast to string"}
+			}
+			endpoints = append(endpoints, endpoint)
+		}
+	default:
+		return nil, &configErr{tk, fmt.Sprintf("Expected an endpoint, or array of endpoints, got %T", v)}
+	}
+	for i, endpoint := range endpoints {
+		path, ok := monitorEndpoint(endpoint)
+		if !ok {
+			return nil, &configErr{tk, fmt.Sprintf("Unknown monitoring endpoint %q", endpoint)}
+		}
+		endpoints[i] = path
+	}
+	return endpoints, nil
+}
+
+// parsePermViolations will parse a permission_violations block.
+func parsePermViolations(v interface{}, errors *[]error) (*PermViolationOpts, error) {
+	tk, v := unwrapValue(v)
+	cm, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, &configErr{tk, fmt.Sprintf("Expected permission_violations to be a map, got %T", v)}
+	}
+	pv := &PermViolationOpts{}
+	for mk, mv := range cm {
+		tk, mv = unwrapValue(mv)
+		switch strings.ToLower(mk) {
+		case "max", "max_violations":
+			n, ok := mv.(int64)
+			if !ok || n < 0 {
+				*errors = append(*errors, &configErr{tk, fmt.Sprintf("Expected max to be a positive number, got %v", mv)})
+				continue
+			}
+			pv.Max = int(n)
+		case "window":
+			ds, ok := mv.(string)
+			if !ok {
+				*errors = append(*errors, &configErr{tk, fmt.Sprintf("Expected window to be a duration, got %v", mv)})
+				continue
+			}
+			d, err := time.ParseDuration(ds)
+			if err != nil || d < 0 {
+				*errors = append(*errors, &configErr{tk, fmt.Sprintf("error parsing window: %q", ds)})
+				continue
+			}
+			pv.Window = d
+		default:
+			if !tk.IsUsedVariable() {
+				err := &unknownConfigFieldErr{
+					field: mk,
+					configErr: configErr{
+						token: tk,
+					},
+				}
+				*errors = append(*errors, err)
+			}
+		}
+	}
+	return pv, nil
+}
+
+// parseHotSubjects will parse the hot_subjects block, which can also be
+// a boolean to enable tracking with the default settings.
+func parseHotSubjects(v interface{}, errors *[]error) (*HotSubjectsOpts, error) {
+	tk, v := unwrapValue(v)
+	hs := &HotSubjectsOpts{}
+	if enabled, ok := v.(bool); ok {
+		if enabled {
+			hs.Size = DEFAULT_HOT_SUBJECTS_SIZE
+		}
+		return hs, nil
+	}
+	cm, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, &configErr{tk, fmt.Sprintf("Expected hot_subjects to be a map or a boolean, got %T", v)}
+	}
+	hs.Size = DEFAULT_HOT_SUBJECTS_SIZE
+	for mk, mv := range cm {
+		tk, mv = unwrapValue(mv)
+		switch strings.ToLower(mk) {
+		case "size", "sampling", "depth":
+			n, ok := mv.(int64)
+			if !ok || n < 0 {
+				*errors = append(*errors, &configErr{tk, fmt.Sprintf("Expected %s to be a positive number, got %v", mk, mv)})
+				continue
+			}
+			switch strings.ToLower(mk) {
+			case "size":
+				hs.Size = int(n)
+			case "sampling":
+				hs.Sampling = int(n)
+			case "depth":
+				hs.Depth = int(n)
+			}
+		case "window":
+			ds, ok := mv.(string)
+			if !ok {
+				*errors = append(*errors, &configErr{tk, fmt.Sprintf("Expected window to be a duration, got %v", mv)})
+				continue
+			}
+			d, err := time.ParseDuration(ds)
+			if err != nil || d < 0 {
+				*errors = append(*errors, &configErr{tk, fmt.Sprintf("error parsing window: %q", ds)})
+				continue
+			}
+			hs.Window = d
+		default:
+			if !tk.IsUsedVariable() {
+				err := &unknownConfigFieldErr{
+					field: mk,
+					configErr: configErr{
+						token: tk,
+					},
+				}
+				*errors = append(*errors, err)
+			}
+		}
+	}
+	return hs, nil
+}
+
+// parseLatencyStats will parse the service_latency block.
+func parseLatencyStats(v interface{}, errors *[]error) (*LatencyStatsOpts, error) {
+	tk, v := unwrapValue(v)
+	cm, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, &configErr{tk, fmt.Sprintf("Expected service_latency to be a map, got %T", v)}
+	}
+	ls := &LatencyStatsOpts{}
+	for mk, mv := range cm {
+		tk, mv = unwrapValue(mv)
+		switch strings.ToLower(mk) {
+		case "interval":
+			ds, ok := mv.(string)
+			if !ok {
+				*errors = append(*errors, &configErr{tk, fmt.Sprintf("Expected interval to be a duration, got %v", mv)})
+				continue
+			}
+			d, err := time.ParseDuration(ds)
+			if err != nil || d < 0 {
+				*errors = append(*errors, &configErr{tk, fmt.Sprintf("error parsing interval: %q", ds)})
+				continue
+			}
+			ls.Interval = d
+		case "samples":
+			samples, ok := mv.(bool)
+			if !ok {
+				*errors = append(*errors, &configErr{tk, fmt.Sprintf("Expected samples to be a boolean, got %v", mv)})
+				continue
+			}
+			ls.NoSamples = !samples
+		default:
+			if !tk.IsUsedVariable() {
+				err := &unknownConfigFieldErr{
+					field: mk,
+					configErr: configErr{
+						token: tk,
+					},
+				}
+				*errors = append(*errors, err)
+			}
+		}
+	}
+	return ls, nil
+}
+
+// parseClosedConnsLog will parse the closed_connections_log block, which
+// can also be the name of the file.
+func parseClosedConnsLog(v interface{}, opts *Options, errors *[]error) error {
+	tk, v := unwrapValue(v)
+	cl := &opts.ClosedConnsLog
+	if file, ok := v.(string); ok {
+		cl.File = file
+		return nil
+	}
+	cm, ok := v.(map[string]interface{})
+	if !ok {
+		return &configErr{tk, fmt.Sprintf("Expected closed_connections_log to be a map or a file name, got %T", v)}
+	}
+	for mk, mv := range cm {
+		tk, mv = unwrapValue(mv)
+		switch strings.ToLower(mk) {
+		case "file":
+			file, ok := mv.(string)
+			if !ok {
+				*errors = append(*errors, &configErr{tk, fmt.Sprintf("Expected file to be a string, got %v", mv)})
+				continue
+			}
+			cl.File = file
+		case "max_size":
+			n, ok := mv.(int64)
+			if !ok || n < 0 {
+				*errors = append(*errors, &configErr{tk, fmt.Sprintf("Expected max_size to be a positive size, got %v", mv)})
+				continue
+			}
+			cl.MaxSize = n
+		case "max_files":
+			n, ok := mv.(int64)
+			if !ok || n < 0 {
+				*errors = append(*errors, &configErr{tk, fmt.Sprintf("Expected max_files to be a positive number, got %v", mv)})
+				continue
+			}
+			cl.MaxFiles = int(n)
+		default:
+			if !tk.IsUsedVariable() {
+				err := &unknownConfigFieldErr{
+					field: mk,
+					configErr: configErr{
+						token: tk,
+					},
+				}
+				*errors = append(*errors, err)
+			}
+		}
+	}
+	if cl.File == _EMPTY_ {
+		return &configErr{tk, "closed_connections_log requires a file"}
+	}
+	return nil
+}
+
 // parseLeafNodes will parse the leaf node config.
 func parseLeafNodes(v interface{}, opts *Options, errors *[]error, warnings *[]error) error {
 	tk, v := unwrapValue(v)
@@ -1080,6 +1657,9 @@ func parseLeafNodes(v interface{}, opts *Options, errors *[]error, warnings *[]e
 				continue
 			}
 			opts.LeafNode.TLSTimeout = tc.Timeout
+			opts.LeafNode.TLSMap = tc.Map
+			opts.LeafNode.TLSMapTypes = tc.MapTypes
+			opts.LeafNode.tlsConfigOpts = tc
 		case "leafnode_advertise", "advertise":
 			opts.LeafNode.Advertise = mv.(string)
 		case "no_advertise":
@@ -1402,6 +1982,7 @@ type importStream struct {
 	an  string
 	sub string
 	pre string
+	to  string
 }
 
 type importService struct {
@@ -1474,6 +2055,7 @@ func parseAccounts(v interface{}, opts *Options, errors *[]error, warnings *[]er
 			acc := NewAccount(aname)
 			opts.Accounts = append(opts.Accounts, acc)
 
+			var allowed, denied []string
 			for k, v := range mv {
 				tk, mv := unwrapValue(v)
 				switch strings.ToLower(k) {
@@ -1485,6 +2067,24 @@ func parseAccounts(v interface{}, opts *Options, errors *[]error, warnings *[]er
 						continue
 					}
 					acc.Nkey = nk
+				case "allowed_cidrs", "denied_cidrs":
+					cidrs, err := parseCIDRList(tk)
+					if err != nil {
+						*errors = append(*errors, err)
+						continue
+					}
+					if strings.ToLower(k) == "allowed_cidrs" {
+						allowed = cidrs
+					} else {
+						denied = cidrs
+					}
+				case "permission_violations":
+					pv, err := parsePermViolations(tk, errors)
+					if err != nil {
+						*errors = append(*errors, err)
+						continue
+					}
+					acc.pviol = pv
 				case "imports":
 					streams, services, err := parseAccountImports(tk, acc, errors, warnings)
 					if err != nil {
@@ -1540,6 +2140,8 @@ func parseAccounts(v interface{}, opts *Options, errors *[]error, warnings *[]er
 					}
 				}
 			}
+			// Lists have been validated already.
+			acc.srcFilter, _ = newSourceFilter(allowed, denied)
 		}
 	}
 	// Bail already if there are previous errors.
@@ -1614,7 +2216,13 @@ func parseAccounts(v interface{}, opts *Options, errors *[]error, warnings *[]er
 			*errors = append(*errors, &configErr{tk, msg})
 			continue
 		}
-		if err := stream.acc.AddStreamImport(ta, stream.sub, stream.pre); err != nil {
+		var err error
+		if stream.to != "" {
+			err = stream.acc.AddMappedStreamImport(ta, stream.sub, stream.to)
+		} else {
+			err = stream.acc.AddStreamImport(ta, stream.sub, stream.pre)
+		}
+		if err != nil {
 			msg := fmt.Sprintf("Error adding stream import %q: %v", stream.sub, err)
 			*errors = append(*errors, &configErr{tk, msg})
 			continue
@@ -1636,6 +2244,11 @@ func parseAccounts(v interface{}, opts *Options, errors *[]error, warnings *[]er
 			continue
 		}
 	}
+	// Requests would loop between the accounts of a cycle of service imports.
+	if cycle := findServiceImportCycle(serviceImportEdges(opts.Accounts), nil); cycle != nil {
+		msg := fmt.Sprintf("Service import cycle: %s", importCyclePath(cycle))
+		*errors = append(*errors, &configErr{tk, msg})
+	}
 
 	return nil
 }
@@ -1950,6 +2563,7 @@ func parseServiceLatency(root token, v interface{}) (*serviceLatency, error) {
 // e.g.
 //   {stream: {account: "synadia", subject:"public.synadia"}, prefix: "imports.synadia"}
 //   {stream: {account: "synadia", subject:"synadia.private.*"}}
+//   {stream: {account: "synadia", subject:"events.*.created"}, to: "synadia.$1.new"}
 //   {service: {account: "synadia", subject: "pub.special.request"}, to: "synadia.request"}
 func parseImportStreamOrService(v interface{}, errors, warnings *[]error) (*importStream, *importService, error) {
 	var (
@@ -1988,10 +2602,7 @@ func parseImportStreamOrService(v interface{}, errors, warnings *[]error) (*impo
 				*errors = append(*errors, err)
 				continue
 			}
-			curStream = &importStream{an: accountName, sub: subject}
-			if pre != "" {
-				curStream.pre = pre
-			}
+			curStream = &importStream{an: accountName, sub: subject, pre: pre, to: to}
 		case "service":
 			if curStream != nil {
 				err := &configErr{tk, fmt.Sprintf("Detected service but already saw a stream")}
@@ -2015,10 +2626,7 @@ func parseImportStreamOrService(v interface{}, errors, warnings *[]error) (*impo
 				*errors = append(*errors, err)
 				continue
 			}
-			curService = &importService{an: accountName, sub: subject}
-			if to != "" {
-				curService.to = to
-			}
+			curService = &importService{an: accountName, sub: subject, to: to}
 		case "prefix":
 			pre = mv.(string)
 			if curStream != nil {
@@ -2026,6 +2634,9 @@ func parseImportStreamOrService(v interface{}, errors, warnings *[]error) (*impo
 			}
 		case "to":
 			to = mv.(string)
+			if curStream != nil {
+				curStream.to = to
+			}
 			if curService != nil {
 				curService.to = to
 			}
@@ -2042,6 +2653,9 @@ func parseImportStreamOrService(v interface{}, errors, warnings *[]error) (*impo
 		}
 
 	}
+	if curStream != nil && curStream.pre != "" && curStream.to != "" {
+		return nil, nil, &configErr{tk, "Stream import can not have both a prefix and a destination"}
+	}
 	return curStream, curService, nil
 }
 
@@ -2140,10 +2754,12 @@ func parseUsers(mv interface{}, opts *Options, errors *[]error, warnings *[]erro
 		}
 
 		var (
-			user  = &User{}
-			nkey  = &NkeyUser{}
-			perms *Permissions
-			err   error
+			user    = &User{}
+			nkey    = &NkeyUser{}
+			perms   *Permissions
+			allowed []string
+			denied  []string
+			err     error
 		)
 		for k, v := range um {
 			// Also needs to unwrap first
@@ -2162,6 +2778,17 @@ func parseUsers(mv interface{}, opts *Options, errors *[]error, warnings *[]erro
 					*errors = append(*errors, err)
 					continue
 				}
+			case "allowed_cidrs", "denied_cidrs":
+				cidrs, err := parseCIDRList(tk)
+				if err != nil {
+					*errors = append(*errors, err)
+					continue
+				}
+				if strings.ToLower(k) == "allowed_cidrs" {
+					allowed = cidrs
+				} else {
+					denied = cidrs
+				}
 			default:
 				if !tk.IsUsedVariable() {
 					err := &unknownConfigFieldErr{
@@ -2185,6 +2812,9 @@ func parseUsers(mv interface{}, opts *Options, errors *[]error, warnings *[]erro
 			}
 		}
 
+		nkey.AllowedCIDRs, nkey.DeniedCIDRs = allowed, denied
+		user.AllowedCIDRs, user.DeniedCIDRs = allowed, denied
+
 		// Check to make sure we have at least an nkey or username <password> defined.
 		if nkey.Nkey == "" && user.Username == "" {
 			return nil, nil, &configErr{tk, fmt.Sprintf("User entry requires a user")}
@@ -2311,6 +2941,34 @@ func parseSubjects(v interface{}, errors, warnings *[]error) ([]string, error) {
 	return subjects, nil
 }
 
+// Helper function to parse CIDR singletons and/or arrays.
+// Bare IP addresses are accepted as well.
+func parseCIDRList(v interface{}) ([]string, error) {
+	tk, v := unwrapValue(v)
+
+	var cidrs []string
+	switch vv := v.(type) {
+	case string:
+		cidrs = append(cidrs, vv)
+	case []interface{}:
+		for _, i := range vv {
+			tk, i := unwrapValue(i)
+
+			cidr, ok := i.(string)
+			if !ok {
+				return nil, &configErr{tk, fmt.Sprintf("CIDR in array cannot be cast to string")}
+			}
+			cidrs = append(cidrs, cidr)
+		}
+	default:
+		return nil, &configErr{tk, fmt.Sprintf("Expected a CIDR, or array of CIDRs, got %T", v)}
+	}
+	if _, err := parseCIDRs(cidrs); err != nil {
+		return nil, &configErr{tk, err.Error()}
+	}
+	return cidrs, nil
+}
+
 // Helper function to parse a ResponsePermission.
 func parseAllowResponses(v interface{}, errors, warnings *[]error) *ResponsePermission {
 	tk, v := unwrapValue(v)
@@ -2497,6 +3155,20 @@ func parseTLS(v interface{}) (*TLSConfigOpts, error) {
 			}
 			tc.Verify = verify
 			tc.Map = verify
+		case "map_types":
+			ra, ok := mv.([]interface{})
+			if !ok || len(ra) == 0 {
+				return nil, &configErr{tk, fmt.Sprintf("error parsing tls config, expected 'map_types' to be a non empty array")}
+			}
+			tc.MapTypes = make([]string, 0, len(ra))
+			for _, r := range ra {
+				tk, r := unwrapValue(r)
+				mt, ok := r.(string)
+				if !ok || !isValidTLSMapType(strings.ToLower(mt)) {
+					return nil, &configErr{tk, fmt.Sprintf("error parsing tls config, unknown map type %v", r)}
+				}
+				tc.MapTypes = append(tc.MapTypes, strings.ToLower(mt))
+			}
 		case "cipher_suites":
 			ra := mv.([]interface{})
 			if len(ra) == 0 {
@@ -3145,6 +3817,7 @@ func overrideTLS(opts *Options) error {
 
 	var err error
 	opts.TLSConfig, err = GenTLSConfig(&tc)
+	opts.tlsConfigOpts = &tc
 	return err
 }
 
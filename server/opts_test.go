@@ -34,8 +34,10 @@ func checkOptionsEqual(t *testing.T, golden, opts *Options) {
 	// want to be compared.
 	goldenClone := golden.Clone()
 	goldenClone.inConfig, goldenClone.inCmdLine = nil, nil
+	goldenClone.tlsConfigOpts = nil
 	optsClone := opts.Clone()
 	optsClone.inConfig, optsClone.inCmdLine = nil, nil
+	optsClone.tlsConfigOpts = nil
 	if !reflect.DeepEqual(goldenClone, optsClone) {
 		t.Fatalf("Options are incorrect.\nexpected: %+v\ngot: %+v", goldenClone, optsClone)
 	}
@@ -1759,7 +1761,11 @@ func TestParsingGateways(t *testing.T) {
 	if opts.Gateway.TLSConfig == nil {
 		t.Fatalf("Expected TLSConfig, got none")
 	}
+	if opts.Gateway.tlsConfigOpts == nil {
+		t.Fatalf("Expected TLS files to be tracked")
+	}
 	opts.Gateway.TLSConfig = nil
+	opts.Gateway.tlsConfigOpts = nil
 	if !reflect.DeepEqual(&opts.Gateway, expected) {
 		t.Fatalf("Expected %v, got %v", expected, opts.Gateway)
 	}
@@ -2021,7 +2027,11 @@ func TestParsingLeafNodesListener(t *testing.T) {
 	if opts.LeafNode.TLSConfig == nil {
 		t.Fatalf("Expected TLSConfig, got none")
 	}
+	if opts.LeafNode.tlsConfigOpts == nil {
+		t.Fatalf("Expected TLS files to be tracked")
+	}
 	opts.LeafNode.TLSConfig = nil
+	opts.LeafNode.tlsConfigOpts = nil
 	if !reflect.DeepEqual(&opts.LeafNode, expected) {
 		t.Fatalf("Expected %v, got %v", expected, opts.LeafNode)
 	}
@@ -2412,3 +2422,136 @@ func TestExpandPath(t *testing.T) {
 		})
 	}
 }
+
+// Creates a config file with the given extension.
+func createConfFileWithExt(t *testing.T, ext string, content []byte) string {
+	t.Helper()
+	conf, err := ioutil.TempFile("", "*"+ext)
+	if err != nil {
+		t.Fatalf("Error creating conf file: %v", err)
+	}
+	fName := conf.Name()
+	conf.Close()
+	if err := ioutil.WriteFile(fName, content, 0666); err != nil {
+		os.Remove(fName)
+		t.Fatalf("Error writing conf file: %v", err)
+	}
+	return fName
+}
+
+func TestConfigFileFormats(t *testing.T) {
+	for _, test := range []struct {
+		ext     string
+		content string
+	}{
+		{".conf", `
+			port: 4567
+			max_payload: 2KB
+			cluster {
+				port: 6789
+				routes: ["nats://127.0.0.1:6790"]
+			}
+			accounts {
+				A { users: [{user: a, password: pwd}] }
+			}
+		`},
+		{".json", `{
+			"port": 4567,
+			"max_payload": 2048,
+			"cluster": {"port": 6789, "routes": ["nats://127.0.0.1:6790"]},
+			"accounts": {"A": {"users": [{"user": "a", "password": "pwd"}]}}
+		}`},
+		{".yaml", `
+port: 4567
+max_payload: 2KB
+cluster:
+  port: 6789
+  routes:
+    - nats://127.0.0.1:6790
+accounts:
+  A:
+    users:
+      - user: a
+        password: pwd
+`},
+	} {
+		t.Run(test.ext, func(t *testing.T) {
+			conf := createConfFileWithExt(t, test.ext, []byte(test.content))
+			defer os.Remove(conf)
+			opts, err := ProcessConfigFile(conf)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if opts.Port != 4567 || opts.MaxPayload != 2048 || opts.Cluster.Port != 6789 || len(opts.Routes) != 1 {
+				t.Fatalf("Unexpected options: %+v", opts)
+			}
+			if len(opts.Users) != 1 || opts.Users[0].Username != "a" || opts.Users[0].Account.Name != "A" {
+				t.Fatalf("Unexpected users: %+v", opts.Users)
+			}
+		})
+	}
+
+	// Errors are reported with the line of the field.
+	conf := createConfFileWithExt(t, ".yml", []byte("port: 4567\ncluster:\n  port: 6789\n  wat: true\n"))
+	defer os.Remove(conf)
+	_, err := ProcessConfigFile(conf)
+	if err == nil || !strings.Contains(err.Error(), conf+":4:3: unknown field \"wat\"") {
+		t.Fatalf("Expected unknown field error on line 4, got %v", err)
+	}
+}
+
+func TestConfigSecretsAndInterpolation(t *testing.T) {
+	pw := createConfFile(t, []byte("s3cr3t\n"))
+	defer os.Remove(pw)
+	os.Setenv("__NATS_TEST_HOST__", "127.0.0.1")
+	defer os.Unsetenv("__NATS_TEST_HOST__")
+
+	conf := createConfFile(t, []byte(fmt.Sprintf(`
+		host: $(__NATS_TEST_HOST__)
+		cluster {
+			port: -1
+			routes: ["nats://$(__NATS_TEST_HOST__):$(__NATS_TEST_PORT__:-6790)"]
+		}
+		authorization {
+			user: admin
+			password: $file(%q)
+		}
+	`, pw)))
+	defer os.Remove(conf)
+	opts, err := ProcessConfigFile(conf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.Host != "127.0.0.1" {
+		t.Fatalf("Unexpected host %q", opts.Host)
+	}
+	if len(opts.Routes) != 1 || opts.Routes[0].Host != "127.0.0.1:6790" {
+		t.Fatalf("Unexpected routes %v", opts.Routes)
+	}
+	if opts.Username != "admin" || opts.Password != "s3cr3t" {
+		t.Fatalf("Unexpected credentials %q", opts.Username)
+	}
+}
+
+func TestConfigSecretsNotInErrors(t *testing.T) {
+	pw := createConfFile(t, []byte("s3cr3t\n"))
+	defer os.Remove(pw)
+
+	conf := createConfFile(t, []byte(fmt.Sprintf(`
+		lame_duck_duration: $file(%q)
+		authorization {
+			permissions: $file(%q)
+		}
+	`, pw, pw)))
+	defer os.Remove(conf)
+	_, err := ProcessConfigFile(conf)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if strings.Contains(err.Error(), "s3cr3t") {
+		t.Fatalf("Secret found in error: %v", err)
+	}
+	if !strings.Contains(err.Error(), "lame_duck_duration") || !strings.Contains(err.Error(), "permissions") {
+		t.Fatalf("Expected errors for both fields, got: %v", err)
+	}
+}
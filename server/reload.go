@@ -173,6 +173,20 @@ func (t *tlsTimeoutOption) Apply(server *Server) {
 	server.Noticef("Reloaded: tls timeout = %v", t.newValue)
 }
 
+// tlsWatchIntervalOption implements the option interface for the
+// `tls_watch_interval` setting.
+type tlsWatchIntervalOption struct {
+	noopOption
+	newValue time.Duration
+}
+
+// Apply the setting by starting the watch loop if needed. The loop
+// picks up the new interval and stops by itself if disabled.
+func (t *tlsWatchIntervalOption) Apply(server *Server) {
+	server.startCertWatchLoop()
+	server.Noticef("Reloaded: tls_watch_interval = %v", t.newValue)
+}
+
 // authOption is a base struct that provides default option behaviors.
 type authOption struct {
 	noopOption
@@ -229,6 +243,19 @@ func (a *authTimeoutOption) Apply(server *Server) {
 	server.Noticef("Reloaded: authorization timeout = %v", a.newValue)
 }
 
+// tlsMapTypesOption implements the option interface for the tls `map_types`
+// setting.
+type tlsMapTypesOption struct {
+	authOption
+	newValue []string
+}
+
+// Apply is a no-op because authorization will be reloaded after options are
+// applied.
+func (t *tlsMapTypesOption) Apply(server *Server) {
+	server.Noticef("Reloaded: tls map types = %v", t.newValue)
+}
+
 // usersOption implements the option interface for the authorization `users`
 // setting.
 type usersOption struct {
@@ -369,6 +396,71 @@ func (m *maxConnOption) Apply(server *Server) {
 	server.Noticef("Reloaded: max_connections = %v", m.newValue)
 }
 
+// maxConnPerIPOption implements the option interface for the
+// `max_connections_per_ip` setting.
+type maxConnPerIPOption struct {
+	noopOption
+	newValue int
+}
+
+// Apply is a no-op because the limit is checked when a client connects.
+// Existing connections are not closed.
+func (m *maxConnPerIPOption) Apply(server *Server) {
+	server.Noticef("Reloaded: max_connections_per_ip = %v", m.newValue)
+}
+
+// authFailuresOption implements the option interface for the `auth_failures`
+// setting.
+type authFailuresOption struct {
+	noopOption
+	newValue AuthFailureOpts
+}
+
+// Apply is a no-op because the options are read on each authentication
+// failure. Failures and bans already recorded are kept.
+func (a *authFailuresOption) Apply(server *Server) {
+	server.Noticef("Reloaded: auth_failures = %+v", a.newValue)
+}
+
+// permViolationsOption implements the option interface for the
+// `permission_violations` setting.
+type permViolationsOption struct {
+	noopOption
+	newValue PermViolationOpts
+}
+
+// Apply is a no-op because the policy is read on each violation.
+func (p *permViolationsOption) Apply(server *Server) {
+	server.Noticef("Reloaded: permission_violations = %+v", p.newValue)
+}
+
+// latencyStatsOption implements the option interface for the
+// `service_latency` setting.
+type latencyStatsOption struct {
+	noopOption
+	newValue LatencyStatsOpts
+}
+
+// Apply restarts the timer publishing the aggregated latencies, since the
+// interval may have changed. Samples are checked on each tracked request.
+func (l *latencyStatsOption) Apply(server *Server) {
+	server.wrapChk(server.restartLatencyStatsTimer)()
+	server.Noticef("Reloaded: service_latency = %+v", l.newValue)
+}
+
+// httpAuthOption implements the option interface for the `http_auth`
+// setting.
+type httpAuthOption struct {
+	noopOption
+	newValue *HTTPAuthOpts
+}
+
+// Apply is a no-op because the options are read on each request to the
+// monitoring port, and on each TLS handshake.
+func (h *httpAuthOption) Apply(server *Server) {
+	server.Noticef("Reloaded: http_auth")
+}
+
 // pidFileOption implements the option interface for the `pid_file` setting.
 type pidFileOption struct {
 	noopOption
@@ -643,6 +735,9 @@ func (s *Server) reloadOptions(curOpts, newOpts *Options) error {
 	if err != nil {
 		return err
 	}
+	// Install the TLS certificate watches on the new options
+	// before they are made visible.
+	s.configureCertWatches(newOpts)
 	// Create a context that is used to pass special info that we may need
 	// while applying the new options.
 	ctx := reloadContext{oldClusterPerms: curOpts.Cluster.Permissions}
@@ -656,7 +751,9 @@ func (s *Server) reloadOptions(curOpts, newOpts *Options) error {
 // error.
 func (s *Server) diffOptions(newOpts *Options) ([]option, error) {
 	var (
-		oldConfig = reflect.ValueOf(s.getOpts()).Elem()
+		// The current TLS configurations hold the hooks of the
+		// certificate watches, which the new ones don't have yet.
+		oldConfig = reflect.ValueOf(withoutCertWatchHooks(s.getOpts())).Elem()
 		newConfig = reflect.ValueOf(newOpts).Elem()
 		diffOpts  = []option{}
 	)
@@ -692,6 +789,10 @@ func (s *Server) diffOptions(newOpts *Options) ([]option, error) {
 			diffOpts = append(diffOpts, &tlsOption{newValue: newValue.(*tls.Config)})
 		case "tlstimeout":
 			diffOpts = append(diffOpts, &tlsTimeoutOption{newValue: newValue.(float64)})
+		case "tlsmaptypes":
+			diffOpts = append(diffOpts, &tlsMapTypesOption{newValue: newValue.([]string)})
+		case "tlswatchinterval":
+			diffOpts = append(diffOpts, &tlsWatchIntervalOption{newValue: newValue.(time.Duration)})
 		case "username":
 			diffOpts = append(diffOpts, &usernameOption{})
 		case "password":
@@ -717,6 +818,19 @@ func (s *Server) diffOptions(newOpts *Options) ([]option, error) {
 			diffOpts = append(diffOpts, &routesOption{add: add, remove: remove})
 		case "maxconn":
 			diffOpts = append(diffOpts, &maxConnOption{newValue: newValue.(int)})
+		case "maxconnperip":
+			diffOpts = append(diffOpts, &maxConnPerIPOption{newValue: newValue.(int)})
+		case "authfailures":
+			diffOpts = append(diffOpts, &authFailuresOption{newValue: newValue.(AuthFailureOpts)})
+		case "httpauth":
+			if err := validateHTTPAuth(newOpts); err != nil {
+				return nil, err
+			}
+			diffOpts = append(diffOpts, &httpAuthOption{newValue: newValue.(*HTTPAuthOpts)})
+		case "latencystats":
+			diffOpts = append(diffOpts, &latencyStatsOption{newValue: newValue.(LatencyStatsOpts)})
+		case "permviolations":
+			diffOpts = append(diffOpts, &permViolationsOption{newValue: newValue.(PermViolationOpts)})
 		case "pidfile":
 			diffOpts = append(diffOpts, &pidFileOption{newValue: newValue.(string)})
 		case "portsfiledir":
@@ -3799,6 +3799,48 @@ func TestConfigReloadAndVarz(t *testing.T) {
 	}
 }
 
+func TestConfigReloadServiceLatency(t *testing.T) {
+	template := `
+		port: -1
+		system_account: SYS
+		accounts { SYS {} }
+		%s
+	`
+	conf := createConfFile(t, []byte(fmt.Sprintf(template, "")))
+	defer os.Remove(conf)
+	s, _ := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	timerSet := func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.sys.lstmr != nil
+	}
+	if timerSet() {
+		t.Fatal("Expected no latency stats timer")
+	}
+
+	changeCurrentConfigContentWithNewContent(t, conf, []byte(fmt.Sprintf(template,
+		`service_latency { interval: "1h", samples: false }`)))
+	if err := s.Reload(); err != nil {
+		t.Fatalf("Error during reload: %v", err)
+	}
+	if !timerSet() {
+		t.Fatal("Expected the latency stats timer to be started")
+	}
+	if ls := s.getOpts().LatencyStats; ls.Interval != time.Hour || !ls.NoSamples {
+		t.Fatalf("Unexpected latency stats options: %+v", ls)
+	}
+
+	changeCurrentConfigContentWithNewContent(t, conf, []byte(fmt.Sprintf(template, "")))
+	if err := s.Reload(); err != nil {
+		t.Fatalf("Error during reload: %v", err)
+	}
+	if timerSet() {
+		t.Fatal("Expected the latency stats timer to be stopped")
+	}
+}
+
 func TestConfigReloadConnectErrReports(t *testing.T) {
 	template := `
 		port: -1
@@ -3904,3 +3946,28 @@ func TestAuthReloadDoesNotBreakRouteInterest(t *testing.T) {
 	nc2.Publish("foo", nil)
 	checkForMsg()
 }
+
+func TestConfigReloadYAML(t *testing.T) {
+	conf := createConfFileWithExt(t, ".yaml", []byte("listen: 127.0.0.1:-1\nmax_payload: 1KB\n"))
+	defer os.Remove(conf)
+	opts, err := ProcessConfigFile(conf)
+	if err != nil {
+		t.Fatalf("Error processing config file: %v", err)
+	}
+	opts.NoLog, opts.NoSigs = true, true
+	s := RunServer(opts)
+	defer s.Shutdown()
+
+	changeCurrentConfigContentWithNewContent(t, conf, []byte("listen: 127.0.0.1:-1\nmax_payload: 2KB\n"))
+	if err := s.Reload(); err != nil {
+		t.Fatalf("Error reloading config: %v", err)
+	}
+	if mp := s.getOpts().MaxPayload; mp != 2048 {
+		t.Fatalf("Expected max payload to be reloaded to 2048, got %d", mp)
+	}
+
+	changeCurrentConfigContentWithNewContent(t, conf, []byte("listen: 127.0.0.1:-1\nmax_payload:\n  - 2KB\n bad\n"))
+	if err := s.Reload(); err == nil {
+		t.Fatal("Expected an error reloading an invalid config")
+	}
+}
@@ -13,6 +13,8 @@
 
 package server
 
+import "time"
+
 // We wrap to hold onto optional items for /connz.
 type closedClient struct {
 	ConnInfo
@@ -75,3 +77,15 @@ func (rb *closedRingBuffer) closedClients() []*closedClient {
 	}
 	return dup
 }
+
+// Returns the closed connections of the buffer that were closed within
+// the given time range.
+func (rb *closedRingBuffer) closedClientsInRange(from, to time.Time) []*closedClient {
+	var ccs []*closedClient
+	for _, cc := range rb.closedClients() {
+		if stoppedInRange(&cc.ConnInfo, from, to) {
+			ccs = append(ccs, cc)
+		}
+	}
+	return ccs
+}
@@ -270,6 +270,11 @@ func (c *client) processInboundRoutedMsg(msg []byte) {
 		c.Debugf("Unknown account %q for routed message on subject: %q", c.pa.account, c.pa.subject)
 		return
 	}
+	acc.addInbound(ROUTER, 1, int64(len(msg)-LEN_CR_LF))
+
+	if hs := c.srv.hotSubs; hs != nil {
+		c.sampleHotSubject(hs, acc, c.pa.subject, c.pa.size)
+	}
 
 	// Check to see if we need to map/route to another account.
 	if acc.imports.services != nil {
@@ -1095,7 +1100,7 @@ func (s *Server) createRoute(conn net.Conn, rURL *url.URL) *client {
 		}
 	}
 
-	c := &client{srv: s, nc: conn, opts: clientOpts{}, kind: ROUTER, msubs: -1, mpay: -1, route: r}
+	c := &client{srv: s, nc: conn, opts: clientOpts{}, kind: ROUTER, msubs: -1, mpay: -1, route: r, start: time.Now()}
 
 	// Grab server variables
 	s.mu.Lock()
@@ -1294,6 +1299,8 @@ func (s *Server) addRoute(c *client, info *Info) (bool, bool) {
 		// connections being dropped.
 		remote.route.retry = true
 		remote.mu.Unlock()
+	} else {
+		s.remoteConnectEvent(c)
 	}
 
 	return !exists, sendInfo
@@ -108,6 +108,8 @@ type Server struct {
 	activeAccounts   int32
 	accResolver      AccountResolver
 	clients          map[uint64]*client
+	clientsPerIP     map[string]int
+	authFails        authFailTracker
 	routes           map[uint64]*client
 	remotes          map[string]*client
 	leafs            map[uint64]*client
@@ -115,6 +117,8 @@ type Server struct {
 	nkeys            map[string]*NkeyUser
 	totalClients     uint64
 	closed           *closedRingBuffer
+	closedLog        *closedConnsLog
+	hotSubs          *hotSubjects
 	done             chan bool
 	start            time.Time
 	http             net.Listener
@@ -173,6 +177,11 @@ type Server struct {
 	// Trusted public operator keys.
 	trustedKeys []string
 
+	// Watches for TLS certificate files changes.
+	certWatchMu      sync.Mutex
+	certWatches      []*certWatch
+	certWatchRunning bool
+
 	// We use this to minimize mem copies for request to monitoring
 	// endpoint /varz (when it comes from http).
 	varzMu sync.Mutex
@@ -185,11 +194,13 @@ type Server struct {
 
 // Make sure all are 64bits for atomic use
 type stats struct {
-	inMsgs        int64
-	outMsgs       int64
-	inBytes       int64
-	outBytes      int64
-	slowConsumers int64
+	inMsgs         int64
+	outMsgs        int64
+	inBytes        int64
+	outBytes       int64
+	slowConsumers  int64
+	ipLimitRejects int64
+	srcAddrRejects int64
 }
 
 // New will setup a new server struct after parsing the options.
@@ -260,6 +271,10 @@ func NewServer(opts *Options) (*Server, error) {
 	// Used internally for quick look-ups.
 	s.clientConnectURLsMap = make(map[string]struct{})
 
+	// Install the TLS certificate watches, if enabled. This needs to be
+	// done before the gateway configurations are cloned.
+	s.configureCertWatches(opts)
+
 	// Call this even if there is no gateway defined. It will
 	// initialize the structure so we don't have to check for
 	// it to be nil or not in various places in the code.
@@ -281,10 +296,27 @@ func NewServer(opts *Options) (*Server, error) {
 
 	// For tracking clients
 	s.clients = make(map[uint64]*client)
+	s.clientsPerIP = make(map[string]int)
 
 	// For tracking closed clients.
 	s.closed = newClosedRingBuffer(opts.MaxClosedClients)
 
+	// For persisting closed clients, nil if disabled.
+	closedLog, err := newClosedConnsLog(&opts.ClosedConnsLog)
+	if err != nil {
+		return nil, err
+	}
+	if closedLog != nil {
+		if err := closedLog.load(s.closed); err != nil {
+			closedLog.close()
+			return nil, fmt.Errorf("error loading closed connections log: %v", err)
+		}
+		s.closedLog = closedLog
+	}
+
+	// For tracking hot subjects, nil if disabled.
+	s.hotSubs = newHotSubjects(&opts.HotSubjects)
+
 	// For tracking connections that are not yet registered
 	// in s.routes, but for which readLoop has started.
 	s.grTmpClients = make(map[uint64]*client)
@@ -369,6 +401,10 @@ func validateOptions(o *Options) error {
 	if err := validateLeafNode(o); err != nil {
 		return err
 	}
+	// Check that the monitoring port can authenticate its users.
+	if err := validateHTTPAuth(o); err != nil {
+		return err
+	}
 	// Check that gateway is properly configured. Returns no error
 	// if there is no gateway defined.
 	return validateGatewayOptions(o)
@@ -453,6 +489,11 @@ func (s *Server) configureAccounts() error {
 		return true
 	})
 
+	// Requests would loop between the accounts of a cycle of service imports.
+	if cycle := findServiceImportCycle(s.serviceImportEdges(), nil); cycle != nil {
+		return fmt.Errorf("service import cycle: %s", importCyclePath(cycle))
+	}
+
 	// Check for configured account resolvers.
 	if err := s.configureResolver(); err != nil {
 		return err
@@ -709,8 +750,7 @@ func (s *Server) SetSystemAccount(accName string) error {
 	if err != nil {
 		return err
 	}
-	acc := s.buildInternalAccount(ac)
-	acc.claimJWT = jwt
+	acc := s.buildInternalAccount(ac, jwt)
 	s.registerAccount(acc)
 
 	return s.setSystemAccount(acc)
@@ -782,6 +822,12 @@ func (s *Server) setSystemAccount(acc *Account) error {
 	// Register with the account.
 	s.sys.client.registerWithAccount(acc)
 
+	// Let accounts import their own advisories.
+	s.accounts.Range(func(k, v interface{}) bool {
+		v.(*Account).addSystemImports(acc)
+		return true
+	})
+
 	// Start our internal loop to serialize outbound messages.
 	// We do our own wg here since we will stop first during shutdown.
 	go s.internalSendLoop(&s.sys.wg)
@@ -795,6 +841,9 @@ func (s *Server) setSystemAccount(acc *Account) error {
 	// Send out statsz updates periodically.
 	s.wrapChk(s.startStatszTimer)()
 
+	// Send out the aggregated service latencies periodically.
+	s.wrapChk(s.startLatencyStatsTimer)()
+
 	return nil
 }
 
@@ -859,6 +908,9 @@ func (s *Server) registerAccountNoLock(acc *Account) {
 	}
 	acc.srv = s
 	acc.mu.Unlock()
+	if s.sys != nil {
+		acc.addSystemImports(s.sys.account)
+	}
 	s.accounts.Store(acc.Name, acc)
 	s.tmpAccounts.Delete(acc.Name)
 	s.enableAccountTracking(acc)
@@ -932,7 +984,7 @@ func (s *Server) updateAccountWithClaimJWT(acc *Account, claimJWT string) error
 	accClaims, _, err := s.verifyAccountClaims(claimJWT)
 	if err == nil && accClaims != nil {
 		acc.claimJWT = claimJWT
-		s.updateAccountClaims(acc, accClaims)
+		s.updateAccountClaimsWithRoles(acc, accClaims, s.signingKeyRoles(accClaims, claimJWT))
 		return nil
 	}
 	return err
@@ -1004,8 +1056,7 @@ func (s *Server) fetchAccount(name string) (*Account, error) {
 			}
 			return acc, nil
 		}
-		acc := s.buildInternalAccount(accClaims)
-		acc.claimJWT = claimJWT
+		acc := s.buildInternalAccount(accClaims, claimJWT)
 		s.registerAccount(acc)
 		return acc, nil
 	}
@@ -1079,6 +1130,9 @@ func (s *Server) Start() {
 		}
 	}
 
+	// Start watching for TLS certificate changes if needed.
+	s.startCertWatchLoop()
+
 	// Start up gateway if needed. Do this before starting the routes, because
 	// we want to resolve the gateway host:port so that this information can
 	// be sent to other routes.
@@ -1239,6 +1293,10 @@ func (s *Server) Shutdown() {
 	// Wait for go routines to be done.
 	s.grWG.Wait()
 
+	if s.closedLog != nil {
+		s.closedLog.close()
+	}
+
 	if opts.PortsFileDir != _EMPTY_ {
 		s.deletePortsFile(opts.PortsFileDir)
 	}
@@ -1452,6 +1510,10 @@ const (
 	LeafzPath    = "/leafz"
 	SubszPath    = "/subsz"
 	StackszPath  = "/stacksz"
+	BanzPath     = "/banz"
+	AccountzPath = "/accountz"
+	HealthzPath  = "/healthz"
+	MetricsPath  = "/metrics"
 )
 
 // Start the monitoring server
@@ -1467,6 +1529,10 @@ func (s *Server) startMonitoring(secure bool) error {
 		RoutezPath:   0,
 		GatewayzPath: 0,
 		SubszPath:    0,
+		BanzPath:     0,
+		AccountzPath: 0,
+		HealthzPath:  0,
+		MetricsPath:  0,
 	}
 
 	var (
@@ -1487,6 +1553,9 @@ func (s *Server) startMonitoring(secure bool) error {
 		hp = net.JoinHostPort(opts.HTTPHost, strconv.Itoa(port))
 		config := opts.TLSConfig.Clone()
 		config.ClientAuth = tls.NoClientCert
+		// Client certificates are required or not depending on the
+		// current http_auth options.
+		config.GetConfigForClient = s.monitorTLSConfig
 		httpListener, err = tls.Listen("tcp", hp, config)
 
 	} else {
@@ -1525,18 +1594,27 @@ func (s *Server) startMonitoring(secure bool) error {
 	mux.HandleFunc("/subscriptionsz", s.HandleSubsz)
 	// Stacksz
 	mux.HandleFunc(StackszPath, s.HandleStacksz)
+	// Banz
+	mux.HandleFunc(BanzPath, s.HandleBanz)
+	// Accountz
+	mux.HandleFunc(AccountzPath, s.HandleAccountz)
+	// Healthz
+	mux.HandleFunc(HealthzPath, s.HandleHealthz)
+	// Metrics
+	mux.HandleFunc(MetricsPath, s.HandleMetrics)
 
 	// Do not set a WriteTimeout because it could cause cURL/browser
 	// to return empty response or unable to display page if the
 	// server needs more time to build the response.
+	handler := s.httpAuthHandler(mux)
 	srv := &http.Server{
 		Addr:           hp,
-		Handler:        mux,
+		Handler:        handler,
 		MaxHeaderBytes: 1 << 20,
 	}
 	s.mu.Lock()
 	s.http = httpListener
-	s.httpHandler = mux
+	s.httpHandler = handler
 	s.monitoringServer = srv
 	s.mu.Unlock()
 
@@ -1624,6 +1702,15 @@ func (s *Server) createClient(conn net.Conn) *client {
 	// Unlock to register
 	c.mu.Unlock()
 
+	// Reject connections from a source address that is banned because
+	// of repeated authentication failures.
+	if c.host != _EMPTY_ {
+		if _, banned := s.isAuthBanned(authBanIP, c.host); banned {
+			c.authBanned()
+			return nil
+		}
+	}
+
 	// Register with the server.
 	s.mu.Lock()
 	// If server is not running, Shutdown() may have already gathered the
@@ -1642,7 +1729,17 @@ func (s *Server) createClient(conn net.Conn) *client {
 		c.maxConnExceeded()
 		return nil
 	}
+	// Same for the connections coming from this client's address.
+	// Host is set in initClient() and does not change.
+	if opts.MaxConnPerIP > 0 && c.host != _EMPTY_ && s.clientsPerIP[c.host] >= opts.MaxConnPerIP {
+		s.mu.Unlock()
+		c.maxConnPerIPExceeded()
+		return nil
+	}
 	s.clients[c.cid] = c
+	if c.host != _EMPTY_ {
+		s.clientsPerIP[c.host]++
+	}
 	s.mu.Unlock()
 
 	// Re-Grab lock
@@ -1745,7 +1842,15 @@ func (s *Server) saveClosedClient(c *client, nc net.Conn, reason ClosedState) {
 	if s.closed != nil {
 		s.closed.append(cc)
 	}
+	closedLog := s.closedLog
 	s.mu.Unlock()
+
+	// Persist if configured.
+	if closedLog != nil {
+		if err := closedLog.append(cc); err != nil {
+			s.Errorf("Error persisting closed connection: %v", err)
+		}
+	}
 }
 
 // Adds the given array of urls to the server's INFO.ClientConnectURLs
@@ -1855,6 +1960,13 @@ func (s *Server) removeClient(c *client) {
 		c.mu.Unlock()
 
 		s.mu.Lock()
+		if _, ok := s.clients[cid]; ok && c.host != _EMPTY_ {
+			if n := s.clientsPerIP[c.host] - 1; n > 0 {
+				s.clientsPerIP[c.host] = n
+			} else {
+				delete(s.clientsPerIP, c.host)
+			}
+		}
 		delete(s.clients, cid)
 		if updateProtoInfoCount {
 			s.cproto--
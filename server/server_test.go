@@ -1544,3 +1544,60 @@ func TestReconnectErrorReports(t *testing.T) {
 		})
 	}
 }
+
+func TestMaxConnectionsPerIP(t *testing.T) {
+	opts := DefaultOptions()
+	opts.MaxConnPerIP = 2
+	s := RunServer(opts)
+	defer s.Shutdown()
+
+	url := fmt.Sprintf("nats://%s:%d", opts.Host, opts.Port)
+	nc1, err := nats.Connect(url)
+	if err != nil {
+		t.Fatalf("Error on connect: %v", err)
+	}
+	defer nc1.Close()
+	nc2, err := nats.Connect(url)
+	if err != nil {
+		t.Fatalf("Error on connect: %v", err)
+	}
+	defer nc2.Close()
+
+	nc3, err := nats.Connect(url)
+	if err == nil {
+		nc3.Close()
+		t.Fatal("Expected third connection from same address to fail")
+	}
+	checkClientsCount(t, s, 2)
+
+	v, _ := s.Varz(nil)
+	if v.MaxConnPerIP != 2 {
+		t.Fatalf("Expected max_connections_per_ip to be 2, got %v", v.MaxConnPerIP)
+	}
+	if v.ConnPerIPRejects != 1 {
+		t.Fatalf("Expected 1 rejection, got %v", v.ConnPerIPRejects)
+	}
+	c, _ := s.Connz(&ConnzOptions{State: ConnClosed})
+	if len(c.Conns) != 1 || c.Conns[0].Reason != MaxConnectionsPerIPExceeded.String() {
+		t.Fatalf("Unexpected closed connections: %+v", c.Conns)
+	}
+
+	// Once a connection is closed, a new one is accepted.
+	nc1.Close()
+	checkClientsCount(t, s, 1)
+	nc3, err = nats.Connect(url)
+	if err != nil {
+		t.Fatalf("Error on connect: %v", err)
+	}
+	nc3.Close()
+
+	checkFor(t, time.Second, 15*time.Millisecond, func() error {
+		s.mu.Lock()
+		n := s.clientsPerIP["127.0.0.1"]
+		s.mu.Unlock()
+		if n != 1 {
+			return fmt.Errorf("Expected 1 connection tracked for address, got %v", n)
+		}
+		return nil
+	})
+}
@@ -30,6 +30,8 @@ import (
 const (
 	pwc   = '*'
 	fwc   = '>'
+	pwcs  = "*"
+	fwcs  = ">"
 	tsep  = "."
 	btsep = '.'
 )
@@ -0,0 +1,236 @@
+// Copyright 2019 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/nats-io/jwt"
+)
+
+// Subjects in permissions can contain templates that are expanded for
+// each connection, for instance "tenant.{{name()}}.>". Supported are:
+//
+//	{{name()}}             name of the user
+//	{{subject()}}          nkey of the user, or name if there is none
+//	{{account-name()}}     name of the account
+//	{{account-subject()}}  nkey of the account, or name if there is none
+//	{{tag(key)}}           values of the "key:value" tags of the user JWT
+//	{{account-tag(key)}}   values of the "key:value" tags of the account JWT
+//
+// A template with multiple values, such as a tag set more than once,
+// expands to one subject per value.
+var permTemplateRE = regexp.MustCompile(`{{\s*([a-z-]+)\(\s*([^()]*?)\s*\)\s*}}`)
+
+// templateValues holds the values templates are expanded with.
+type templateValues struct {
+	name       string
+	subject    string
+	accName    string
+	accSubject string
+	tags       jwt.TagList
+	accTags    jwt.TagList
+}
+
+// Returns the values for the template function with the given argument.
+func (tv *templateValues) lookup(fn, arg string) ([]string, error) {
+	one := func(v string) ([]string, error) {
+		if v == _EMPTY_ {
+			return nil, fmt.Errorf("no value for %s()", fn)
+		}
+		return []string{v}, nil
+	}
+	tagValues := func(tags jwt.TagList) ([]string, error) {
+		if arg == _EMPTY_ {
+			return nil, fmt.Errorf("%s() requires a tag name", fn)
+		}
+		// Tags are lower cased by the JWT library.
+		prefix := strings.ToLower(arg) + ":"
+		var values []string
+		for _, t := range tags {
+			if strings.HasPrefix(t, prefix) && len(t) > len(prefix) {
+				values = append(values, t[len(prefix):])
+			}
+		}
+		if len(values) == 0 {
+			return nil, fmt.Errorf("no tag %q for %s()", arg, fn)
+		}
+		return values, nil
+	}
+	switch fn {
+	case "name":
+		return one(tv.name)
+	case "subject":
+		return one(tv.subject)
+	case "account-name":
+		return one(tv.accName)
+	case "account-subject":
+		return one(tv.accSubject)
+	case "tag":
+		return tagValues(tv.tags)
+	case "account-tag":
+		return tagValues(tv.accTags)
+	}
+	return nil, fmt.Errorf("unknown template function %s()", fn)
+}
+
+// hasPermTemplate returns true if the subject contains a template.
+func hasPermTemplate(subject string) bool {
+	return strings.Contains(subject, "{{")
+}
+
+// expandPermTemplate returns the subjects resulting from the expansion of
+// the templates in the given subject. Values must be valid subject tokens,
+// so that they can't widen the permission with wildcards or extra tokens.
+func expandPermTemplate(subject string, tv *templateValues) ([]string, error) {
+	if !hasPermTemplate(subject) {
+		return []string{subject}, nil
+	}
+	matches := permTemplateRE.FindAllStringSubmatchIndex(subject, -1)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("invalid template in %q", subject)
+	}
+	results := []string{_EMPTY_}
+	last := 0
+	for _, m := range matches {
+		values, err := tv.lookup(subject[m[2]:m[3]], subject[m[4]:m[5]])
+		if err != nil {
+			return nil, fmt.Errorf("unable to expand %q: %v", subject, err)
+		}
+		for _, v := range values {
+			if v == _EMPTY_ || strings.ContainsAny(v, ".*> \t\r\n") {
+				return nil, fmt.Errorf("unable to expand %q: value %q is not a valid subject token", subject, v)
+			}
+		}
+		prefix := subject[last:m[0]]
+		expanded := make([]string, 0, len(results)*len(values))
+		for _, r := range results {
+			for _, v := range values {
+				expanded = append(expanded, r+prefix+v)
+			}
+		}
+		results = expanded
+		last = m[1]
+	}
+	for i := range results {
+		results[i] += subject[last:]
+		if hasPermTemplate(results[i]) {
+			return nil, fmt.Errorf("invalid template in %q", subject)
+		}
+	}
+	return results, nil
+}
+
+// expandPermTemplates expands the templates of a list of subjects. Subjects
+// that fail to expand are returned separately.
+func expandPermTemplates(subjects []string, tv *templateValues) ([]string, []error) {
+	if subjects == nil {
+		return nil, nil
+	}
+	var errs []error
+	expanded := make([]string, 0, len(subjects))
+	for _, subject := range subjects {
+		values, err := expandPermTemplate(subject, tv)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		expanded = append(expanded, values...)
+	}
+	return expanded, errs
+}
+
+// hasTemplates returns true if any subject of the permissions contains
+// a template.
+func (p *Permissions) hasTemplates() bool {
+	if p == nil {
+		return false
+	}
+	for _, sp := range []*SubjectPermission{p.Publish, p.Subscribe} {
+		if sp == nil {
+			continue
+		}
+		for _, subjects := range [][]string{sp.Allow, sp.Deny} {
+			for _, subject := range subjects {
+				if hasPermTemplate(subject) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// expandTemplates returns a copy of the permissions with the templates
+// expanded. Allowed subjects that can't be expanded are dropped, while
+// denied subjects that can't be expanded deny everything, so that a
+// failure never grants more than intended. The returned errors list the
+// subjects that could not be expanded.
+func (p *Permissions) expandTemplates(tv *templateValues) (*Permissions, []error) {
+	if !p.hasTemplates() {
+		return p, nil
+	}
+	var errs []error
+	expand := func(sp *SubjectPermission) *SubjectPermission {
+		if sp == nil {
+			return nil
+		}
+		var aerrs, derrs []error
+		nsp := &SubjectPermission{}
+		nsp.Allow, aerrs = expandPermTemplates(sp.Allow, tv)
+		nsp.Deny, derrs = expandPermTemplates(sp.Deny, tv)
+		// An empty allow list would allow everything for subscriptions.
+		if len(derrs) > 0 || (len(sp.Allow) > 0 && len(nsp.Allow) == 0) {
+			nsp.Deny = append(nsp.Deny, ">")
+		}
+		errs = append(errs, aerrs...)
+		errs = append(errs, derrs...)
+		return nsp
+	}
+	np := p.clone()
+	np.Publish = expand(p.Publish)
+	np.Subscribe = expand(p.Subscribe)
+	return np, errs
+}
+
+// templateValues returns the values used to expand permission templates
+// for this client.
+// Lock should be held.
+func (c *client) templateValues() *templateValues {
+	tv := &templateValues{name: c.opts.Username, subject: c.opts.Username}
+	if c.user != nil {
+		tv.subject = c.user.Nkey
+		tv.name = c.user.Nkey
+		if c.user.name != _EMPTY_ {
+			tv.name = c.user.name
+		}
+		tv.tags = c.user.tags
+	}
+	if acc := c.acc; acc != nil {
+		acc.mu.RLock()
+		tv.accName = acc.Name
+		tv.accSubject = acc.Name
+		if acc.Nkey != _EMPTY_ {
+			tv.accSubject = acc.Nkey
+		}
+		if acc.nameTag != _EMPTY_ {
+			tv.accName = acc.nameTag
+		}
+		tv.accTags = acc.tags
+		acc.mu.RUnlock()
+	}
+	return tv
+}
@@ -0,0 +1,172 @@
+// Copyright 2019 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/nats-io/jwt"
+)
+
+func TestExpandPermTemplate(t *testing.T) {
+	tv := &templateValues{
+		name:       "alice",
+		subject:    "UABC",
+		accName:    "acme",
+		accSubject: "AABC",
+		tags:       jwt.TagList{"dept:eng", "dept:ops", "bad:a.b", "other"},
+		accTags:    jwt.TagList{"region:eu"},
+	}
+	for _, test := range []struct {
+		subject  string
+		expected []string
+	}{
+		{"foo.bar", []string{"foo.bar"}},
+		{"user.{{name()}}.>", []string{"user.alice.>"}},
+		{"user.{{ subject() }}", []string{"user.UABC"}},
+		{"{{account-name()}}.{{account-subject()}}", []string{"acme.AABC"}},
+		{"{{account-tag(region)}}.{{tag(DEPT)}}.*", []string{"eu.eng.*", "eu.ops.*"}},
+		{"{{tag(dept)}}.{{tag(dept)}}", []string{"eng.eng", "eng.ops", "ops.eng", "ops.ops"}},
+	} {
+		values, err := expandPermTemplate(test.subject, tv)
+		if err != nil {
+			t.Fatalf("Error expanding %q: %v", test.subject, err)
+		}
+		if !reflect.DeepEqual(values, test.expected) {
+			t.Fatalf("Expected %q to expand to %q, got %q", test.subject, test.expected, values)
+		}
+	}
+	for _, subject := range []string{
+		"{{unknown()}}",
+		"{{name}}",
+		"{{tag()}}",
+		"{{tag(missing)}}",
+		"{{tag(bad)}}",
+		"{{account-tag(dept)}}",
+	} {
+		if values, err := expandPermTemplate(subject, tv); err == nil {
+			t.Fatalf("Expected error expanding %q, got %q", subject, values)
+		}
+	}
+	// Values must not be able to widen the permission.
+	tv.name = "*"
+	if _, err := expandPermTemplate("user.{{name()}}", tv); err == nil {
+		t.Fatal("Expected error for wildcard value")
+	}
+}
+
+func TestPermissionTemplatesExpandFailures(t *testing.T) {
+	p := &Permissions{
+		Publish: &SubjectPermission{
+			Allow: []string{"foo", "{{tag(missing)}}"},
+		},
+		Subscribe: &SubjectPermission{
+			Allow: []string{"{{tag(missing)}}"},
+			Deny:  []string{"{{name()}}"},
+		},
+	}
+	np, errs := p.expandTemplates(&templateValues{})
+	if len(errs) != 3 {
+		t.Fatalf("Expected 3 errors, got %v", errs)
+	}
+	if !reflect.DeepEqual(np.Publish.Allow, []string{"foo"}) || len(np.Publish.Deny) != 0 {
+		t.Fatalf("Unexpected publish permissions: %+v", np.Publish)
+	}
+	// Failures in a deny list, or an allow list left empty, deny everything.
+	if len(np.Subscribe.Allow) != 0 || !reflect.DeepEqual(np.Subscribe.Deny, []string{">"}) {
+		t.Fatalf("Unexpected subscribe permissions: %+v", np.Subscribe)
+	}
+	// The original permissions are untouched.
+	if p.Publish.Allow[1] != "{{tag(missing)}}" {
+		t.Fatalf("Original permissions were modified: %+v", p.Publish)
+	}
+}
+
+func TestPermissionTemplatesConfigUsers(t *testing.T) {
+	conf := createConfFile(t, []byte(`
+		listen: "127.0.0.1:-1"
+		accounts {
+			ACME {
+				users [
+					{user: alice, password: pwd, permissions: {
+						publish: "tenant.{{account-name()}}.{{name()}}.>"
+						subscribe: {allow: "_INBOX.>", deny: "_INBOX.{{name()}}.private"}
+					}}
+				]
+			}
+		}
+	`))
+	defer os.Remove(conf)
+	s, _ := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	c, _, _ := newClientForServer(s)
+	defer c.nc.Close()
+	c.opts.Username = "alice"
+	c.opts.Password = "pwd"
+	if !s.checkAuthentication(c) {
+		t.Fatal("Expected authentication to succeed")
+	}
+	if !c.pubAllowed("tenant.ACME.alice.foo") {
+		t.Fatal("Expected publish to be allowed")
+	}
+	if c.pubAllowed("tenant.ACME.bob.foo") || c.pubAllowed("tenant.{{account-name()}}.alice.foo") {
+		t.Fatal("Expected publish to be denied")
+	}
+	if !c.canSubscribe("_INBOX.foo") || c.canSubscribe("_INBOX.alice.private") {
+		t.Fatal("Unexpected subscribe permissions")
+	}
+	c.mu.Lock()
+	darray := c.darray
+	c.mu.Unlock()
+	if !reflect.DeepEqual(darray, []string{"_INBOX.alice.private"}) {
+		t.Fatalf("Expected expanded deny array, got %q", darray)
+	}
+	// The user's permissions are not modified.
+	s.mu.Lock()
+	allow := s.users["alice"].Permissions.Publish.Allow
+	s.mu.Unlock()
+	if allow[0] != "tenant.{{account-name()}}.{{name()}}.>" {
+		t.Fatalf("User permissions were modified: %q", allow)
+	}
+}
+
+func TestPermissionTemplatesJWT(t *testing.T) {
+	nac := newJWTTestAccountClaims()
+	nac.Name = "acme"
+	nac.Tags.Add("region:eu")
+	nuc := newJWTTestUserClaims()
+	nuc.Name = "alice"
+	nuc.Tags.Add("dept:eng")
+	nuc.Tags.Add("dept:ops")
+	nuc.Permissions.Pub.Allow.Add("{{account-name()}}.{{account-tag(region)}}.{{name()}}")
+	nuc.Permissions.Sub.Allow.Add("dept.{{tag(dept)}}.>")
+
+	s, _, c, _ := setupJWTTestWithClaims(t, nac, nuc, "+OK")
+	defer s.Shutdown()
+
+	if !c.pubAllowed("acme.eu.alice") || c.pubAllowed("acme.eu.bob") {
+		t.Fatal("Unexpected publish permissions")
+	}
+	for _, subject := range []string{"dept.eng.foo", "dept.ops.foo"} {
+		if !c.canSubscribe(subject) {
+			t.Fatalf("Expected subscribe on %q to be allowed", subject)
+		}
+	}
+	if c.canSubscribe("dept.sales.foo") {
+		t.Fatal("Expected subscribe to be denied")
+	}
+}
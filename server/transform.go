@@ -0,0 +1,220 @@
+// Copyright 2020 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"strconv"
+	"strings"
+)
+
+// subjectTransform maps subjects matching a source subject into a
+// destination template. Tokens of the template in the form `$n` are
+// replaced by the token matched by the n-th `*` wildcard of the source.
+// A trailing `>` in the source has to be present in the template and
+// carries over the remaining tokens. For instance `events.*.created`
+// mapped to `partner.$1.new` transforms `events.22.created` into
+// `partner.22.new`.
+type subjectTransform struct {
+	src     string
+	dest    string
+	stokens []string
+	dtokens []string
+	// For each destination token, the index of the source token whose
+	// value it takes, or -1 for a literal.
+	dmap []int
+	// The destination as a subject, with the references replaced by `*`.
+	pattern string
+	fwc     bool
+}
+
+// newSubjectTransform validates the destination template against the
+// source subject and returns the transform.
+func newSubjectTransform(src, dest string) (*subjectTransform, error) {
+	if !IsValidSubject(src) || !IsValidSubject(dest) {
+		return nil, ErrInvalidSubject
+	}
+	tr := &subjectTransform{
+		src:     src,
+		dest:    dest,
+		stokens: strings.Split(src, tsep),
+		dtokens: strings.Split(dest, tsep),
+	}
+	// Collect the positions of the source wildcards.
+	var wcs []int
+	for i, t := range tr.stokens {
+		if t == pwcs {
+			wcs = append(wcs, i)
+		} else if t == fwcs {
+			tr.fwc = true
+		}
+	}
+	ptokens := make([]string, len(tr.dtokens))
+	tr.dmap = make([]int, len(tr.dtokens))
+	for i, t := range tr.dtokens {
+		tr.dmap[i] = -1
+		ptokens[i] = t
+		switch {
+		case t == fwcs:
+			if !tr.fwc {
+				return nil, ErrBadSubjectTransform
+			}
+			tr.dmap[i] = len(tr.stokens) - 1
+		case t == pwcs:
+			return nil, ErrInvalidSubject
+		default:
+			// Tokens such as $SYS are not references.
+			n := subjectReference(t)
+			if n < 0 {
+				continue
+			}
+			if n > len(wcs) {
+				return nil, ErrBadSubjectTransform
+			}
+			tr.dmap[i] = wcs[n-1]
+			ptokens[i] = pwcs
+		}
+	}
+	if tr.fwc && tr.dtokens[len(tr.dtokens)-1] != fwcs {
+		return nil, ErrBadSubjectTransform
+	}
+	tr.pattern = strings.Join(ptokens, tsep)
+	return tr, nil
+}
+
+// Returns the position n of the source wildcard referenced by a
+// template token `$n`, or -1 if the token is a literal.
+func subjectReference(token string) int {
+	if len(token) < 2 || token[0] != '$' {
+		return -1
+	}
+	n, err := strconv.Atoi(token[1:])
+	if err != nil || n < 1 {
+		return -1
+	}
+	return n
+}
+
+// hasSubjectReferences returns whether the subject is a template
+// referencing wildcards with `$n` tokens.
+func hasSubjectReferences(subject string) bool {
+	for _, t := range strings.Split(subject, tsep) {
+		if subjectReference(t) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// isReversible returns whether every wildcard of the source is referenced
+// in the template, in which case any literal subject matching the pattern
+// maps back to a literal source subject.
+func (tr *subjectTransform) isReversible() bool {
+	for i, t := range tr.stokens {
+		if t != pwcs && t != fwcs {
+			continue
+		}
+		found := false
+		for _, j := range tr.dmap {
+			if j == i {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// transform maps a subject matching the source into the destination.
+func (tr *subjectTransform) transform(subject string) string {
+	tokens := strings.Split(subject, tsep)
+	if len(tokens) < len(tr.stokens) || (!tr.fwc && len(tokens) != len(tr.stokens)) {
+		return subject
+	}
+	var b strings.Builder
+	b.Grow(len(tr.dest) + len(subject))
+	for i, t := range tr.dtokens {
+		if i > 0 {
+			b.WriteByte(btsep)
+		}
+		switch j := tr.dmap[i]; {
+		case j < 0:
+			b.WriteString(t)
+		case t == fwcs:
+			b.WriteString(strings.Join(tokens[j:], tsep))
+		default:
+			b.WriteString(tokens[j])
+		}
+	}
+	return b.String()
+}
+
+// reverse maps a subject of the destination space, which may contain
+// wildcards, back into the source space. Source wildcards that are not
+// resolved by the subject are left as wildcards. Returns false if no
+// subject of the destination space can match the given subject.
+func (tr *subjectTransform) reverse(subject string) (string, bool) {
+	tokens := strings.Split(subject, tsep)
+	vals := make([]string, len(tr.stokens))
+	n := len(tr.dtokens)
+	if tr.fwc {
+		n--
+	}
+	covered := false
+	for i := 0; i < n; i++ {
+		if i >= len(tokens) {
+			return _EMPTY_, false
+		}
+		t := tokens[i]
+		if t == fwcs {
+			covered = true
+			break
+		}
+		j := tr.dmap[i]
+		if j < 0 {
+			if t != pwcs && t != tr.dtokens[i] {
+				return _EMPTY_, false
+			}
+			continue
+		}
+		if t == pwcs {
+			continue
+		}
+		if vals[j] != _EMPTY_ && vals[j] != t {
+			return _EMPTY_, false
+		}
+		vals[j] = t
+	}
+	if !covered {
+		if tr.fwc {
+			if len(tokens) <= n {
+				return _EMPTY_, false
+			}
+			vals[len(vals)-1] = strings.Join(tokens[n:], tsep)
+		} else if len(tokens) != n {
+			return _EMPTY_, false
+		}
+	}
+	rtokens := make([]string, len(tr.stokens))
+	for i, t := range tr.stokens {
+		if (t == pwcs || t == fwcs) && vals[i] != _EMPTY_ {
+			rtokens[i] = vals[i]
+		} else {
+			rtokens[i] = t
+		}
+	}
+	return strings.Join(rtokens, tsep), true
+}
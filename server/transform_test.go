@@ -0,0 +1,95 @@
+// Copyright 2020 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"testing"
+)
+
+func TestSubjectTransformErrors(t *testing.T) {
+	for _, test := range []struct {
+		src, dest string
+		err       error
+	}{
+		{"foo.*", "bar.*", ErrInvalidSubject},
+		{"foo..*", "bar.$1", ErrInvalidSubject},
+		{"foo.*", "bar.$2", ErrBadSubjectTransform},
+		{"foo.>", "bar.$1", ErrBadSubjectTransform},
+		{"foo.*", "bar.>", ErrBadSubjectTransform},
+	} {
+		if _, err := newSubjectTransform(test.src, test.dest); err != test.err {
+			t.Fatalf("Expected error %v for %q to %q, got %v", test.err, test.src, test.dest, err)
+		}
+	}
+}
+
+func TestSubjectTransform(t *testing.T) {
+	for _, test := range []struct {
+		src, dest, pattern string
+		subject, result    string
+	}{
+		{"events.*.created", "partner.$1.new", "partner.*.new", "events.22.created", "partner.22.new"},
+		{"a.*.*", "b.$2.$1", "b.*.*", "a.x.y", "b.y.x"},
+		{"a.*.*", "b.$2", "b.*", "a.x.y", "b.y"},
+		{"a.*.*", "$SYS.$1.$1", "$SYS.*.*", "a.x.y", "$SYS.x.x"},
+		{"a.*.>", "b.$1.>", "b.*.>", "a.x.y.z", "b.x.y.z"},
+	} {
+		tr, err := newSubjectTransform(test.src, test.dest)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q to %q: %v", test.src, test.dest, err)
+		}
+		if tr.pattern != test.pattern {
+			t.Fatalf("Expected pattern %q, got %q", test.pattern, tr.pattern)
+		}
+		if res := tr.transform(test.subject); res != test.result {
+			t.Fatalf("Expected %q to be transformed into %q, got %q", test.subject, test.result, res)
+		}
+	}
+}
+
+func TestSubjectTransformReverse(t *testing.T) {
+	for _, test := range []struct {
+		src, dest string
+		subject   string
+		result    string
+		ok        bool
+	}{
+		{"events.*.created", "partner.$1.new", "partner.22.new", "events.22.created", true},
+		{"events.*.created", "partner.$1.new", "partner.*.new", "events.*.created", true},
+		{"events.*.created", "partner.$1.new", "partner.>", "events.*.created", true},
+		{"events.*.created", "partner.$1.new", "*.22.*", "events.22.created", true},
+		{"events.*.created", "partner.$1.new", "partner.22.old", "", false},
+		{"events.*.created", "partner.$1.new", "partner.22", "", false},
+		{"a.*.*", "b.$2.$1", "b.y.x", "a.x.y", true},
+		{"a.*.*", "b.$2", "b.y", "a.*.y", true},
+		{"a.*", "b.$1.$1", "b.x.*", "a.x", true},
+		{"a.*", "b.$1.$1", "b.x.y", "", false},
+		{"a.*.>", "b.$1.>", "b.x.y.z", "a.x.y.z", true},
+		{"a.*.>", "b.$1.>", "b.>", "a.*.>", true},
+		{"a.*.>", "b.$1.>", "b.x", "", false},
+	} {
+		tr, err := newSubjectTransform(test.src, test.dest)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q to %q: %v", test.src, test.dest, err)
+		}
+		res, ok := tr.reverse(test.subject)
+		if ok != test.ok || res != test.result {
+			t.Fatalf("Expected %q to be reversed into %q (%v), got %q (%v)", test.subject, test.result, test.ok, res, ok)
+		}
+	}
+	tr, _ := newSubjectTransform("a.*.*", "b.$2")
+	if tr.isReversible() {
+		t.Fatalf("Expected transform dropping a token to not be reversible")
+	}
+}
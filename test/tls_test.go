@@ -537,6 +537,79 @@ Loop:
 	}
 }
 
+func TestTLSClientCertificateURIBasedAuth(t *testing.T) {
+	srv, opts := RunServerWithConfig("./configs/tls_cert_uri.conf")
+	defer srv.Shutdown()
+	nurl := fmt.Sprintf("tls://%s:%d", opts.Host, opts.Port)
+
+	connect := func(name, cert, key string) (*nats.Conn, error) {
+		return nats.Connect(nurl,
+			nats.Name(name),
+			nats.ClientCert(cert, key),
+			nats.RootCAs("./configs/certs/spiffe/ca.pem"))
+	}
+
+	// URI: spiffe://example.org/ns/prod/sa/billing
+	nca, err := connect("a", "./configs/certs/spiffe/client-a.pem", "./configs/certs/spiffe/client-a-key.pem")
+	if err != nil {
+		t.Fatalf("Expected to connect, got %v", err)
+	}
+	defer nca.Close()
+
+	// URI: spiffe://example.org/ns/dev/sa/billing
+	ncb, err := connect("b", "./configs/certs/spiffe/client-b.pem", "./configs/certs/spiffe/client-b-key.pem")
+	if err != nil {
+		t.Fatalf("Expected to connect, got %v", err)
+	}
+	defer ncb.Close()
+
+	// URI: spiffe://other.org/ns/prod/sa/billing, which does not match any user.
+	if ncc, err := connect("c", "./configs/certs/spiffe/client-c.pem", "./configs/certs/spiffe/client-c-key.pem"); err == nil {
+		ncc.Close()
+		t.Fatal("Expected connection to fail")
+	}
+
+	connz, err := srv.Connz(&server.ConnzOptions{Username: true})
+	if err != nil {
+		t.Fatalf("Error getting connz: %v", err)
+	}
+	if len(connz.Conns) != 2 {
+		t.Fatalf("Expected 2 connections, got %v", len(connz.Conns))
+	}
+	expected := map[string][2]string{
+		"a": {"spiffe://example.org/ns/prod/sa/billing", "PROD"},
+		"b": {"spiffe://example.org/ns/dev/sa/billing", "DEV"},
+	}
+	for _, ci := range connz.Conns {
+		e := expected[ci.Name]
+		if ci.AuthorizedUser != e[0] || ci.Account != e[1] {
+			t.Fatalf("Expected connection %q to be user %q in account %q, got %q in %q",
+				ci.Name, e[0], e[1], ci.AuthorizedUser, ci.Account)
+		}
+	}
+}
+
+func TestTLSRoutesCertificateURIBasedAuth(t *testing.T) {
+	optsA := LoadConfig("./configs/tls_cert_uri_routes.conf")
+	optsA.Host = "127.0.0.1"
+	optsA.Port = -1
+	optsA.Cluster.Host = optsA.Host
+	optsA.Cluster.Port = -1
+	srvA := RunServer(optsA)
+	defer srvA.Shutdown()
+
+	optsB := LoadConfig("./configs/tls_cert_uri_routes.conf")
+	optsB.Host = "127.0.0.1"
+	optsB.Port = -1
+	optsB.Cluster.Host = optsB.Host
+	optsB.Cluster.Port = -1
+	optsB.Routes = server.RoutesFromStr(fmt.Sprintf("nats://localhost:%d", srvA.ClusterAddr().Port))
+	srvB := RunServer(optsB)
+	defer srvB.Shutdown()
+
+	checkClusterFormed(t, srvA, srvB)
+}
+
 func TestTLSRoutesCertificateCNBasedAuth(t *testing.T) {
 	// Base config for the servers
 	optsA := LoadConfig("./configs/tls_cert_cn_routes.conf")